@@ -0,0 +1,143 @@
+// Package batch collects the blocks of a file changed by a single index
+// operation, so that they can be written with one file.FileMgr.WriteBatch
+// and a crash never leaves the operation half done.
+package batch
+
+import (
+	"errors"
+
+	"simpledb-in-golang/file"
+)
+
+// Block is a decoded block of a file.
+type Block interface {
+	// Number returns the number of the block within its file.
+	Number() int
+	// Encode writes the block into p.
+	Encode(p *file.Page) error
+}
+
+// Batch caches the blocks read by an operation and tracks those it
+// changes, allocates and frees. It is not safe for concurrent use.
+type Batch[T Block] struct {
+	fm        *file.FileMgr
+	filename  string
+	read      func(blknum int) (T, error)
+	blocks    map[int]T
+	dirty     map[int]bool
+	pages     map[file.BlockId]*file.Page
+	allocated []int
+	freed     []int
+}
+
+// New returns an empty batch over filename, whose blocks are read with read.
+func New[T Block](fm *file.FileMgr, filename string, read func(blknum int) (T, error)) *Batch[T] {
+	return &Batch[T]{
+		fm:       fm,
+		filename: filename,
+		read:     read,
+		blocks:   make(map[int]T),
+		dirty:    make(map[int]bool),
+		pages:    make(map[file.BlockId]*file.Page),
+	}
+}
+
+// Get returns the block blknum, reading it on first use.
+func (b *Batch[T]) Get(blknum int) (T, error) {
+	if blk, ok := b.blocks[blknum]; ok {
+		return blk, nil
+	}
+	blk, err := b.read(blknum)
+	if err != nil {
+		return blk, err
+	}
+	b.blocks[blknum] = blk
+	return blk, nil
+}
+
+// Alloc allocates a block of the file and returns the new block built by
+// init from its number. The allocation takes effect at once; Abort undoes
+// it if the batch is not written.
+func (b *Batch[T]) Alloc(init func(blknum int) T) (T, error) {
+	id, err := b.fm.Allocate(b.filename)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	b.allocated = append(b.allocated, id.Number())
+	blk := init(id.Number())
+	b.blocks[blk.Number()] = blk
+	b.dirty[blk.Number()] = true
+	return blk, nil
+}
+
+// Touch marks a block as changed.
+func (b *Batch[T]) Touch(blk T) { b.dirty[blk.Number()] = true }
+
+// Free releases a block once the batch is written.
+func (b *Batch[T]) Free(blk T) {
+	delete(b.dirty, blk.Number())
+	delete(b.blocks, blk.Number())
+	b.freed = append(b.freed, blk.Number())
+}
+
+// SetPage adds a page that is not a decoded block, such as a meta block,
+// to the batch.
+func (b *Batch[T]) SetPage(id file.BlockId, p *file.Page) { b.pages[id] = p }
+
+// Forget drops the cached blocks, none of which may have changed.
+func (b *Batch[T]) Forget() { clear(b.blocks) }
+
+// Commit writes the batch and releases the freed blocks.
+func (b *Batch[T]) Commit() error {
+	if err := b.Write(); err != nil {
+		return err
+	}
+	return b.Release()
+}
+
+// Write encodes the changed blocks and writes them with the other pages
+// in one atomic batch.
+func (b *Batch[T]) Write() error {
+	for blknum := range b.dirty {
+		p := file.NewPage(b.fm.BlockSize())
+		if err := b.blocks[blknum].Encode(p); err != nil {
+			return err
+		}
+		b.pages[file.NewBlockId(b.filename, blknum)] = p
+	}
+	err := b.fm.WriteBatch(b.pages)
+	if !errors.Is(err, file.ErrBatchNotCommitted) {
+		// The batch is written, now or by the next WriteBatch, and with it
+		// the blocks it allocated.
+		b.allocated = nil
+	}
+	return err
+}
+
+// Abort returns the blocks allocated by the batch to the file manager,
+// unless the batch has been written. Operations defer it so that a
+// failure does not leak blocks.
+func (b *Batch[T]) Abort() error {
+	for len(b.allocated) > 0 {
+		blknum := b.allocated[0]
+		if err := b.fm.Free(file.NewBlockId(b.filename, blknum)); err != nil {
+			return err
+		}
+		b.allocated = b.allocated[1:]
+	}
+	return nil
+}
+
+// Release returns the freed blocks to the file manager. It must only be
+// called once the batch is written, since the old blocks may still be
+// referenced until then.
+func (b *Batch[T]) Release() error {
+	for _, blknum := range b.freed {
+		if err := b.fm.Free(file.NewBlockId(b.filename, blknum)); err != nil {
+			return err
+		}
+	}
+	b.freed = nil
+	return nil
+}
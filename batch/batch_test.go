@@ -0,0 +1,169 @@
+package batch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+// counter is a block holding a single int.
+type counter struct {
+	blknum int
+	n      int
+}
+
+func (c *counter) Number() int { return c.blknum }
+
+func (c *counter) Encode(p *file.Page) error { return p.SetInt(0, c.n) }
+
+func newCounterBatch(fm *file.FileMgr) *Batch[*counter] {
+	return New(fm, "c.dat", func(blknum int) (*counter, error) {
+		p := file.NewPage(fm.BlockSize())
+		if err := fm.Read(file.NewBlockId("c.dat", blknum), p); err != nil {
+			return nil, err
+		}
+		n, err := p.GetInt(0)
+		return &counter{blknum: blknum, n: n}, err
+	})
+}
+
+func TestBatch_Commit(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_batch_commit")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 64)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+
+	b := newCounterBatch(fm)
+	var blocks []*counter
+	for i := range 3 {
+		c, err := b.Alloc(func(blknum int) *counter { return &counter{blknum: blknum, n: 10 + i} })
+		if err != nil {
+			t.Fatalf("Alloc() error = %v", err)
+		}
+		blocks = append(blocks, c)
+	}
+	meta := file.NewPage(fm.BlockSize())
+	_ = meta.SetInt(0, 99)
+	b.SetPage(file.NewBlockId("meta.dat", 0), meta)
+	if err := b.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	// A later batch changes one block and frees another.
+	b = newCounterBatch(fm)
+	c, err := b.Get(blocks[0].blknum)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if c.n != 10 {
+		t.Errorf("Get() = %d, want 10", c.n)
+	}
+	c.n = 20
+	b.Touch(c)
+	gone, err := b.Get(blocks[1].blknum)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	b.Free(gone)
+	if err := b.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		blk  file.BlockId
+		want int
+	}{
+		{"touched", file.NewBlockId("c.dat", blocks[0].blknum), 20},
+		{"untouched", file.NewBlockId("c.dat", blocks[2].blknum), 12},
+		{"extra page", file.NewBlockId("meta.dat", 0), 99},
+	}
+	for _, tt := range tests {
+		p := file.NewPage(fm.BlockSize())
+		if err := fm.Read(tt.blk, p); err != nil {
+			t.Fatalf("%s: Read() error = %v", tt.name, err)
+		}
+		if got, _ := p.GetInt(0); got != tt.want {
+			t.Errorf("%s: block = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	blk, err := fm.Allocate("c.dat")
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if blk.Number() != blocks[1].blknum {
+		t.Errorf("Allocate() = %d, want the freed block %d", blk.Number(), blocks[1].blknum)
+	}
+}
+
+func TestBatch_GetError(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_batch_get")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 64, file.WithStrictReads())
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+
+	b := newCounterBatch(fm)
+	if _, err := b.Get(5); !errors.Is(err, file.ErrBlockNotAllocated) {
+		t.Errorf("Get(missing) error = %v, want ErrBlockNotAllocated", err)
+	}
+}
+
+func TestBatch_Abort(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_batch_abort")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 64)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+
+	b := newCounterBatch(fm)
+	c, err := b.Alloc(func(blknum int) *counter { return &counter{blknum: blknum} })
+	if err != nil {
+		t.Fatalf("Alloc() error = %v", err)
+	}
+	if err := b.Abort(); err != nil {
+		t.Fatalf("Abort() error = %v", err)
+	}
+	blk, err := fm.Allocate("c.dat")
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if blk.Number() != c.blknum {
+		t.Errorf("Allocate() after Abort() = %d, want the aborted block %d", blk.Number(), c.blknum)
+	}
+
+	// Once written, the allocations stand.
+	b = newCounterBatch(fm)
+	c, err = b.Alloc(func(blknum int) *counter { return &counter{blknum: blknum, n: 5} })
+	if err != nil {
+		t.Fatalf("Alloc() error = %v", err)
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err := b.Abort(); err != nil {
+		t.Fatalf("Abort() error = %v", err)
+	}
+	if blk, _ := fm.Allocate("c.dat"); blk.Number() == c.blknum {
+		t.Errorf("Allocate() after a written batch returned its block %d", c.blknum)
+	}
+}
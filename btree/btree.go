@@ -0,0 +1,496 @@
+// Package btree implements a disk-resident B+tree index mapping keys to
+// opaque byte-slice values. Every node is a block of a single file,
+// accessed through file.FileMgr and encoded with file.Page.
+package btree
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"simpledb-in-golang/batch"
+	"simpledb-in-golang/file"
+)
+
+// metaMagic identifies block 0 of a tree file.
+const metaMagic = 0x42547265 // "BTre"
+
+// Tree is a B+tree stored in one file. Block 0 holds the key kind and the
+// root block number; every other block is a node. A key may have any
+// number of values; once they no longer fit in the leaf entry they are
+// moved to a chain of overflow blocks.
+//
+// Each Insert and Delete is written with FileMgr.WriteBatch, so a crash
+// never leaves a half-split or half-merged tree behind.
+type Tree struct {
+	fm       *file.FileMgr
+	filename string
+	keyKind  int
+
+	mu   sync.Mutex
+	root int
+}
+
+// Open opens the tree stored in filename, creating an empty tree with keys
+// of the given kind if the file is empty.
+func Open(fm *file.FileMgr, filename string, keyKind int) (*Tree, error) {
+	if keyKind < IntKey || keyKind > BytesKey {
+		return nil, fmt.Errorf("btree: unknown key kind %d", keyKind)
+	}
+	if maxEntry(fm.BlockSize()) < 16 {
+		return nil, fmt.Errorf("btree: block size %d is too small", fm.BlockSize())
+	}
+	t := &Tree{fm: fm, filename: filename, keyKind: keyKind}
+	n, err := fm.Length(filename)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return t, t.create()
+	}
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(file.NewBlockId(filename, 0), p); err != nil {
+		return nil, err
+	}
+	magic, _ := p.GetInt(0)
+	kind, _ := p.GetInt(4)
+	root, _ := p.GetInt(8)
+	if magic != metaMagic {
+		return nil, fmt.Errorf("btree: %s is not a B-tree file", filename)
+	}
+	if kind != keyKind {
+		return nil, fmt.Errorf("btree: %s has key kind %d, not %d", filename, kind, keyKind)
+	}
+	t.root = root
+	return t, nil
+}
+
+// create writes the meta block and an empty root leaf.
+func (t *Tree) create() (err error) {
+	meta, err := t.fm.Append(t.filename)
+	if err != nil {
+		return err
+	}
+	if meta.Number() != 0 {
+		return fmt.Errorf("btree: %s is not empty", t.filename)
+	}
+	s := t.newSession()
+	defer func() { err = errors.Join(err, s.Abort()) }()
+	root, err := s.alloc(leafNode)
+	if err != nil {
+		return err
+	}
+	s.root = root.blknum
+	return s.commit()
+}
+
+// Insert adds val to the values of key. The blocks allocated by an insert
+// that fails are freed again.
+func (t *Tree) Insert(key Key, val []byte) (err error) {
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	if file.MaxLength(len(val)) > t.fm.BlockSize()-nodeHeaderSize {
+		return fmt.Errorf("btree: value of %d bytes is too large", len(val))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.newSession()
+	defer func() { err = errors.Join(err, s.Abort()) }()
+	sep, right, err := s.insert(s.root, key, val)
+	if err != nil {
+		return err
+	}
+	if right >= 0 {
+		root, err := s.alloc(internalNode)
+		if err != nil {
+			return err
+		}
+		root.keys = []Key{sep}
+		root.children = []int{s.root, right}
+		s.root = root.blknum
+	}
+	return s.commit()
+}
+
+// Lookup returns the values of key, or nil if the key is absent.
+func (t *Tree) Lookup(key Key) ([][]byte, error) {
+	if err := t.checkKey(key); err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.newSession()
+	n, err := s.findLeaf(key)
+	if err != nil {
+		return nil, err
+	}
+	i, found := n.search(key)
+	if !found {
+		return nil, nil
+	}
+	return s.values(n, i)
+}
+
+// Delete removes one occurrence of val from the values of key and reports
+// whether it was present. A key is removed with its last value.
+func (t *Tree) Delete(key Key, val []byte) (bool, error) {
+	if err := t.checkKey(key); err != nil {
+		return false, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.newSession()
+	found, err := s.delete(s.root, key, val)
+	if err != nil || !found {
+		return false, err
+	}
+	// Collapse a root left with a single child.
+	root, err := s.Get(s.root)
+	if err != nil {
+		return false, err
+	}
+	if root.kind == internalNode && len(root.keys) == 0 {
+		s.root = root.children[0]
+		s.Free(root)
+	}
+	return true, s.commit()
+}
+
+// checkKey returns an error if key cannot be stored in the tree.
+func (t *Tree) checkKey(key Key) error {
+	if key.Kind() != t.keyKind {
+		return fmt.Errorf("btree: key %s has kind %d, tree has %d", key, key.Kind(), t.keyKind)
+	}
+	if key.size()+8 > maxEntry(t.fm.BlockSize()) {
+		return fmt.Errorf("btree: key %s is too large", key)
+	}
+	return nil
+}
+
+// readNode reads and decodes the node stored in block blknum.
+func (t *Tree) readNode(blknum int) (*node, error) {
+	p := file.NewPage(t.fm.BlockSize())
+	if err := t.fm.Read(file.NewBlockId(t.filename, blknum), p); err != nil {
+		return nil, err
+	}
+	return decodeNode(p, blknum, t.keyKind)
+}
+
+// maxEntry returns the largest leaf entry a node may hold, which
+// guarantees that every split leaves at least two entries on each side.
+func maxEntry(blocksize int) int {
+	return (blocksize - nodeHeaderSize) / 4
+}
+
+// session collects the nodes changed by a single operation, so that they
+// can be written in one atomic batch.
+type session struct {
+	*batch.Batch[*node]
+	t    *Tree
+	root int
+}
+
+func (t *Tree) newSession() *session {
+	return &session{Batch: batch.New(t.fm, t.filename, t.readNode), t: t, root: t.root}
+}
+
+// alloc returns a new empty node of the given kind.
+func (s *session) alloc(kind int) (*node, error) {
+	return s.Alloc(func(blknum int) *node { return &node{blknum: blknum, kind: kind, next: -1} })
+}
+
+// commit writes the changed nodes and the meta block atomically, then
+// returns the freed blocks to the file manager.
+func (s *session) commit() error {
+	if s.root != s.t.root {
+		p := file.NewPage(s.t.fm.BlockSize())
+		_ = p.SetInt(0, metaMagic)
+		_ = p.SetInt(4, s.t.keyKind)
+		_ = p.SetInt(8, s.root)
+		s.SetPage(file.NewBlockId(s.t.filename, 0), p)
+	}
+	if err := s.Write(); err != nil {
+		return err
+	}
+	s.t.root = s.root
+	return s.Release()
+}
+
+// findLeaf returns the leaf that would hold key.
+func (s *session) findLeaf(key Key) (*node, error) {
+	n, err := s.Get(s.root)
+	if err != nil {
+		return nil, err
+	}
+	for n.kind == internalNode {
+		if n, err = s.Get(n.children[n.childIndex(key)]); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// values returns the values of the i-th entry of leaf n.
+func (s *session) values(n *node, i int) ([][]byte, error) {
+	vals := slices.Clone(n.vals[i])
+	for blknum := n.overflow[i]; blknum >= 0; {
+		o, err := s.Get(blknum)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, o.items...)
+		blknum = o.next
+	}
+	return vals, nil
+}
+
+// insert adds val under key to the subtree rooted at blknum. If the root
+// of the subtree splits, it returns the separator key and the new right
+// sibling; otherwise right is -1.
+func (s *session) insert(blknum int, key Key, val []byte) (sep Key, right int, err error) {
+	n, err := s.Get(blknum)
+	if err != nil {
+		return Key{}, -1, err
+	}
+	if n.kind == leafNode {
+		if err := s.insertLeaf(n, key, val); err != nil {
+			return Key{}, -1, err
+		}
+	} else {
+		i := n.childIndex(key)
+		csep, cright, err := s.insert(n.children[i], key, val)
+		if err != nil || cright < 0 {
+			return Key{}, -1, err
+		}
+		n.keys = slices.Insert(n.keys, i, csep)
+		n.children = slices.Insert(n.children, i+1, cright)
+		s.Touch(n)
+	}
+	if n.size() <= s.t.fm.BlockSize() {
+		return Key{}, -1, nil
+	}
+	r, err := s.alloc(n.kind)
+	if err != nil {
+		return Key{}, -1, err
+	}
+	return s.split(n, r), r.blknum, nil
+}
+
+// insertLeaf adds val under key to leaf n, moving the values of the entry
+// to an overflow chain once the entry grows too large.
+func (s *session) insertLeaf(n *node, key Key, val []byte) error {
+	s.Touch(n)
+	i, found := n.search(key)
+	if !found {
+		n.keys = slices.Insert(n.keys, i, key)
+		n.vals = slices.Insert(n.vals, i, [][]byte{val})
+		n.overflow = slices.Insert(n.overflow, i, -1)
+	} else if n.overflow[i] >= 0 {
+		head, err := s.pushOverflow(n.overflow[i], val)
+		if err != nil {
+			return err
+		}
+		n.overflow[i] = head
+		return nil
+	} else {
+		n.vals[i] = append(n.vals[i], val)
+	}
+	if n.entrySize(i) <= maxEntry(s.t.fm.BlockSize()) {
+		return nil
+	}
+	head := -1
+	for _, v := range n.vals[i] {
+		var err error
+		if head, err = s.pushOverflow(head, v); err != nil {
+			return err
+		}
+	}
+	n.vals[i] = nil
+	n.overflow[i] = head
+	return nil
+}
+
+// pushOverflow adds val to the overflow chain starting at head and returns
+// the new head of the chain.
+func (s *session) pushOverflow(head int, val []byte) (int, error) {
+	if head >= 0 {
+		o, err := s.Get(head)
+		if err != nil {
+			return -1, err
+		}
+		if o.size()+file.MaxLength(len(val)) <= s.t.fm.BlockSize() {
+			o.items = append(o.items, val)
+			s.Touch(o)
+			return head, nil
+		}
+	}
+	o, err := s.alloc(overflowNode)
+	if err != nil {
+		return -1, err
+	}
+	o.items = [][]byte{val}
+	o.next = head
+	return o.blknum, nil
+}
+
+// split moves the upper half of n, by encoded size, into the empty node r
+// and returns the key separating them.
+func (s *session) split(n, r *node) Key {
+	s.Touch(n)
+	s.Touch(r)
+	if n.kind == leafNode {
+		m := splitPoint(n, len(n.keys))
+		r.keys = slices.Clone(n.keys[m:])
+		r.vals = slices.Clone(n.vals[m:])
+		r.overflow = slices.Clone(n.overflow[m:])
+		n.keys, n.vals, n.overflow = n.keys[:m], n.vals[:m], n.overflow[:m]
+		r.next = n.next
+		n.next = r.blknum
+		return r.keys[0]
+	}
+	// The key at the split point moves up to the parent.
+	m := splitPoint(n, len(n.keys)-1)
+	sep := n.keys[m]
+	r.keys = slices.Clone(n.keys[m+1:])
+	r.children = slices.Clone(n.children[m+1:])
+	n.keys, n.children = n.keys[:m], n.children[:m+1]
+	return sep
+}
+
+// splitPoint returns the index of the first entry of n whose preceding
+// entries hold at least half of the node's bytes, clamped to [1, limit].
+func splitPoint(n *node, limit int) int {
+	total := n.size()
+	sz := nodeHeaderSize
+	m := 0
+	for m < len(n.keys) && sz < total/2 {
+		if n.kind == leafNode {
+			sz += n.entrySize(m)
+		} else {
+			sz += n.keys[m].size() + 4
+		}
+		m++
+	}
+	return max(1, min(m, limit))
+}
+
+// delete removes one occurrence of val under key from the subtree rooted
+// at blknum, rebalancing any child left less than half full.
+func (s *session) delete(blknum int, key Key, val []byte) (bool, error) {
+	n, err := s.Get(blknum)
+	if err != nil {
+		return false, err
+	}
+	if n.kind == leafNode {
+		return s.deleteLeaf(n, key, val)
+	}
+	i := n.childIndex(key)
+	found, err := s.delete(n.children[i], key, val)
+	if err != nil || !found {
+		return found, err
+	}
+	child, err := s.Get(n.children[i])
+	if err != nil {
+		return false, err
+	}
+	if child.size() < s.t.fm.BlockSize()/2 {
+		return true, s.rebalance(n, i)
+	}
+	return true, nil
+}
+
+// deleteLeaf removes one occurrence of val under key from leaf n.
+func (s *session) deleteLeaf(n *node, key Key, val []byte) (bool, error) {
+	i, found := n.search(key)
+	if !found {
+		return false, nil
+	}
+	if j := slices.IndexFunc(n.vals[i], func(v []byte) bool { return bytes.Equal(v, val) }); j >= 0 {
+		n.vals[i] = slices.Delete(n.vals[i], j, j+1)
+	} else if ok, err := s.deleteOverflow(n, i, val); err != nil || !ok {
+		return false, err
+	}
+	s.Touch(n)
+	if len(n.vals[i]) == 0 && n.overflow[i] < 0 {
+		n.keys = slices.Delete(n.keys, i, i+1)
+		n.vals = slices.Delete(n.vals, i, i+1)
+		n.overflow = slices.Delete(n.overflow, i, i+1)
+	}
+	return true, nil
+}
+
+// deleteOverflow removes one occurrence of val from the overflow chain of
+// the i-th entry of leaf n, releasing any block left empty.
+func (s *session) deleteOverflow(n *node, i int, val []byte) (bool, error) {
+	var prev *node
+	for blknum := n.overflow[i]; blknum >= 0; {
+		o, err := s.Get(blknum)
+		if err != nil {
+			return false, err
+		}
+		j := slices.IndexFunc(o.items, func(v []byte) bool { return bytes.Equal(v, val) })
+		if j < 0 {
+			prev, blknum = o, o.next
+			continue
+		}
+		o.items = slices.Delete(o.items, j, j+1)
+		if len(o.items) > 0 {
+			s.Touch(o)
+		} else if prev == nil {
+			n.overflow[i] = o.next
+			s.Free(o)
+		} else {
+			prev.next = o.next
+			s.Touch(prev)
+			s.Free(o)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// rebalance fixes the underfull i-th child of internal node n by merging
+// it with a sibling, or, when the two do not fit in one block, by sharing
+// their entries evenly.
+func (s *session) rebalance(n *node, i int) error {
+	if len(n.children) < 2 {
+		return nil
+	}
+	if i == len(n.children)-1 {
+		i--
+	}
+	left, err := s.Get(n.children[i])
+	if err != nil {
+		return err
+	}
+	right, err := s.Get(n.children[i+1])
+	if err != nil {
+		return err
+	}
+	s.Touch(n)
+	s.Touch(left)
+
+	// Gather both siblings in left, pulling the separator down between
+	// internal nodes.
+	if left.kind == leafNode {
+		left.keys = append(left.keys, right.keys...)
+		left.vals = append(left.vals, right.vals...)
+		left.overflow = append(left.overflow, right.overflow...)
+		left.next = right.next
+	} else {
+		left.keys = append(append(left.keys, n.keys[i]), right.keys...)
+		left.children = append(left.children, right.children...)
+	}
+	if left.size() <= s.t.fm.BlockSize() {
+		n.keys = slices.Delete(n.keys, i, i+1)
+		n.children = slices.Delete(n.children, i+1, i+2)
+		s.Free(right)
+		return nil
+	}
+	*right = node{blknum: right.blknum, kind: right.kind, next: -1}
+	n.keys[i] = s.split(left, right)
+	return nil
+}
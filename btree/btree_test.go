@@ -0,0 +1,380 @@
+package btree
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/file/filetest"
+)
+
+// checkTree verifies ordering, fill limits and leaf links, and returns
+// the number of entries in the leaves.
+func checkTree(t *testing.T, tr *Tree) int {
+	t.Helper()
+	s := tr.newSession()
+	bs := tr.fm.BlockSize()
+	var leaves []int
+	var walk func(blknum int, lo, hi Key)
+	walk = func(blknum int, lo, hi Key) {
+		n, err := s.Get(blknum)
+		if err != nil {
+			t.Fatalf("reading block %d: %v", blknum, err)
+		}
+		if n.size() > bs {
+			t.Fatalf("block %d holds %d bytes, more than %d", blknum, n.size(), bs)
+		}
+		for i, k := range n.keys {
+			if i > 0 && n.keys[i-1].Compare(k) >= 0 {
+				t.Fatalf("block %d keys out of order: %v", blknum, n.keys)
+			}
+			if lo.Kind() != 0 && k.Compare(lo) < 0 || hi.Kind() != 0 && k.Compare(hi) >= 0 {
+				t.Fatalf("block %d key %v outside [%v, %v)", blknum, k, lo, hi)
+			}
+		}
+		if n.kind == leafNode {
+			leaves = append(leaves, blknum)
+			return
+		}
+		for i, c := range n.children {
+			clo, chi := lo, hi
+			if i > 0 {
+				clo = n.keys[i-1]
+			}
+			if i < len(n.keys) {
+				chi = n.keys[i]
+			}
+			walk(c, clo, chi)
+		}
+	}
+	walk(tr.root, Key{}, Key{})
+
+	entries := 0
+	for i, blknum := range leaves {
+		n, _ := s.Get(blknum)
+		entries += len(n.keys)
+		want := -1
+		if i+1 < len(leaves) {
+			want = leaves[i+1]
+		}
+		if n.next != want {
+			t.Fatalf("leaf %d next = %d, want %d", blknum, n.next, want)
+		}
+	}
+	return entries
+}
+
+func newTestTree(t *testing.T, dir string, blocksize, keyKind int) (*file.FileMgr, *Tree) {
+	t.Helper()
+	fm, err := file.NewFileMgr(dir, blocksize)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	tr, err := Open(fm, "idx.bt", keyKind)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return fm, tr
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_btree_open")
+	defer os.RemoveAll(testDir)
+
+	fm, tr := newTestTree(t, testDir, 128, IntKey)
+	if err := tr.Insert(NewIntKey(1), []byte("a")); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		kind    int
+		wantErr bool
+	}{
+		{"same kind", IntKey, false},
+		{"other kind", StringKey, true},
+		{"unknown kind", 9, true},
+	}
+	for _, tt := range tests {
+		_, err := Open(fm, "idx.bt", tt.kind)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Open() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if _, err := fm.Append("other.tbl"); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if _, err := Open(fm, "other.tbl", IntKey); err == nil {
+		t.Errorf("Open(non-tree file) error = nil, want error")
+	}
+
+	small, err := file.NewFileMgr(filepath.Join(testDir, "small"), 64)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	if _, err := Open(small, "idx.bt", IntKey); err == nil {
+		t.Errorf("Open() with 64-byte blocks error = nil, want error")
+	}
+}
+
+func TestTree_InsertLookup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		kind int
+		key  func(i int) Key
+	}{
+		{"int", IntKey, func(i int) Key { return NewIntKey(i - 250) }},
+		{"string", StringKey, func(i int) Key { return NewStringKey(fmt.Sprintf("key%05d", i)) }},
+		{"bytes", BytesKey, func(i int) Key { return NewBytesKey([]byte{byte(i >> 8), byte(i)}) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_btree_insert_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			_, tr := newTestTree(t, testDir, 128, tt.kind)
+			const n = 500
+			for _, i := range rand.New(rand.NewSource(1)).Perm(n) {
+				if err := tr.Insert(tt.key(i), []byte(fmt.Sprint(i))); err != nil {
+					t.Fatalf("Insert(%d) error = %v", i, err)
+				}
+			}
+			if got := checkTree(t, tr); got != n {
+				t.Errorf("tree holds %d keys, want %d", got, n)
+			}
+			root, _ := tr.readNode(tr.root)
+			if root.kind != internalNode {
+				t.Errorf("root kind = %d after %d inserts, want internal", root.kind, n)
+			}
+			for i := range n {
+				vals, err := tr.Lookup(tt.key(i))
+				if err != nil {
+					t.Fatalf("Lookup(%d) error = %v", i, err)
+				}
+				if len(vals) != 1 || string(vals[0]) != fmt.Sprint(i) {
+					t.Errorf("Lookup(%d) = %q, want [%d]", i, vals, i)
+				}
+			}
+			if vals, err := tr.Lookup(tt.key(n)); err != nil || vals != nil {
+				t.Errorf("Lookup(absent) = %q, %v, want nil, nil", vals, err)
+			}
+		})
+	}
+}
+
+func TestTree_Persist(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_btree_persist")
+	defer os.RemoveAll(testDir)
+
+	_, tr := newTestTree(t, testDir, 128, StringKey)
+	for i := range 200 {
+		if err := tr.Insert(NewStringKey(fmt.Sprintf("k%03d", i)), []byte{byte(i)}); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	_, tr2 := newTestTree(t, testDir, 128, StringKey)
+	if tr2.root != tr.root {
+		t.Errorf("reopened root = %d, want %d", tr2.root, tr.root)
+	}
+	if got := checkTree(t, tr2); got != 200 {
+		t.Errorf("reopened tree holds %d keys, want 200", got)
+	}
+	vals, err := tr2.Lookup(NewStringKey("k123"))
+	if err != nil || len(vals) != 1 || vals[0][0] != 123 {
+		t.Errorf("Lookup(k123) = %v, %v, want [[123]]", vals, err)
+	}
+}
+
+func TestTree_DuplicatesOverflow(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_btree_overflow")
+	defer os.RemoveAll(testDir)
+
+	fm, tr := newTestTree(t, testDir, 128, IntKey)
+	var want [][]byte
+	for i := range 100 {
+		v := []byte(fmt.Sprintf("value-%03d", i))
+		want = append(want, v)
+		if err := tr.Insert(NewIntKey(7), v); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+		if err := tr.Insert(NewIntKey(i+10), v); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+	checkTree(t, tr)
+
+	leaf, _ := tr.newSession().findLeaf(NewIntKey(7))
+	i, _ := leaf.search(NewIntKey(7))
+	if leaf.overflow[i] < 0 || len(leaf.vals[i]) != 0 {
+		t.Errorf("key 7 has %d inline values and overflow %d, want values in overflow chain", len(leaf.vals[i]), leaf.overflow[i])
+	}
+
+	vals, err := tr.Lookup(NewIntKey(7))
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	sortBytes := func(b [][]byte) { slices.SortFunc(b, func(x, y []byte) int { return slices.Compare(x, y) }) }
+	sortBytes(vals)
+	if !slices.EqualFunc(vals, want, slices.Equal) {
+		t.Errorf("Lookup(7) returned %d values, want %d", len(vals), len(want))
+	}
+
+	// Removing every value frees the whole chain for reuse.
+	before, _ := fm.Length("idx.bt")
+	for _, v := range want {
+		if ok, err := tr.Delete(NewIntKey(7), v); err != nil || !ok {
+			t.Fatalf("Delete(7, %s) = %v, %v, want true, nil", v, ok, err)
+		}
+	}
+	if vals, _ := tr.Lookup(NewIntKey(7)); vals != nil {
+		t.Errorf("Lookup(7) after deleting all values = %q, want nil", vals)
+	}
+	for i := range 100 {
+		if err := tr.Insert(NewIntKey(8), []byte(fmt.Sprintf("again-%03d", i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+	if after, _ := fm.Length("idx.bt"); after > before {
+		t.Errorf("file grew from %d to %d blocks, want freed blocks reused", before, after)
+	}
+	checkTree(t, tr)
+}
+
+func TestTree_FailedInsertFreesBlocks(t *testing.T) {
+	t.Parallel()
+
+	insertAll := func(t *testing.T, failFirst bool) (*file.FileMgr, *Tree) {
+		s := filetest.NewFaultStorage(1)
+		fm, err := file.NewFileMgr("", 128, file.WithStorage(s))
+		if err != nil {
+			t.Fatalf("NewFileMgr() failed: %v", err)
+		}
+		tr, err := Open(fm, "idx.bt", IntKey)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		for i := range 200 {
+			key, val := NewIntKey(i), []byte(fmt.Sprint(i))
+			if failFirst {
+				// Fail the batch before it commits, after any splits
+				// have allocated their blocks.
+				s.Inject(filetest.Fault{Op: filetest.OpWriteAt, Name: "redo.dat", N: 1, Err: syscall.EIO})
+				if err := tr.Insert(key, val); !errors.Is(err, syscall.EIO) {
+					t.Fatalf("Insert(%d) with failing batch error = %v, want EIO", i, err)
+				}
+				s.ClearFaults()
+			}
+			if err := tr.Insert(key, val); err != nil {
+				t.Fatalf("Insert(%d) error = %v", i, err)
+			}
+		}
+		return fm, tr
+	}
+
+	fm, tr := insertAll(t, false)
+	defer fm.Close()
+	failed, failedTree := insertAll(t, true)
+	defer failed.Close()
+	if got := checkTree(t, failedTree); got != 200 {
+		t.Errorf("tree holds %d entries, want 200", got)
+	}
+	want, _ := fm.Length("idx.bt")
+	if got, _ := failed.Length("idx.bt"); got != want {
+		t.Errorf("file has %d blocks after failed inserts, want %d as without them", got, want)
+	}
+	if got := checkTree(t, tr); got != 200 {
+		t.Errorf("control tree holds %d entries, want 200", got)
+	}
+}
+
+func TestTree_Delete(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_btree_delete")
+	defer os.RemoveAll(testDir)
+
+	_, tr := newTestTree(t, testDir, 128, IntKey)
+	const n = 400
+	for i := range n {
+		if err := tr.Insert(NewIntKey(i), []byte{byte(i)}); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	if ok, err := tr.Delete(NewIntKey(n+1), []byte{0}); err != nil || ok {
+		t.Errorf("Delete(absent key) = %v, %v, want false, nil", ok, err)
+	}
+	if ok, err := tr.Delete(NewIntKey(3), []byte{99}); err != nil || ok {
+		t.Errorf("Delete(absent value) = %v, %v, want false, nil", ok, err)
+	}
+
+	order := rand.New(rand.NewSource(2)).Perm(n)
+	for j, i := range order {
+		if ok, err := tr.Delete(NewIntKey(i), []byte{byte(i)}); err != nil || !ok {
+			t.Fatalf("Delete(%d) = %v, %v, want true, nil", i, ok, err)
+		}
+		if j%50 == 0 {
+			if got := checkTree(t, tr); got != n-j-1 {
+				t.Fatalf("tree holds %d keys after %d deletes, want %d", got, j+1, n-j-1)
+			}
+			vals, err := tr.Lookup(NewIntKey(order[n-1]))
+			if err != nil || len(vals) != 1 {
+				t.Fatalf("Lookup(remaining) = %v, %v", vals, err)
+			}
+		}
+	}
+	if got := checkTree(t, tr); got != 0 {
+		t.Errorf("tree holds %d keys after deleting all, want 0", got)
+	}
+	root, _ := tr.readNode(tr.root)
+	if root.kind != leafNode {
+		t.Errorf("root kind = %d after deleting all, want leaf", root.kind)
+	}
+}
+
+func TestTree_InvalidArguments(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_btree_invalid")
+	defer os.RemoveAll(testDir)
+
+	_, tr := newTestTree(t, testDir, 128, StringKey)
+	tests := []struct {
+		name string
+		key  Key
+		val  []byte
+	}{
+		{"wrong kind", NewIntKey(1), nil},
+		{"unbounded key", Key{}, nil},
+		{"key too large", NewStringKey(string(make([]byte, 40))), nil},
+		{"value too large", NewStringKey("k"), make([]byte, 120)},
+	}
+	for _, tt := range tests {
+		if err := tr.Insert(tt.key, tt.val); err == nil {
+			t.Errorf("%s: Insert() error = nil, want error", tt.name)
+		}
+	}
+	if _, err := tr.Lookup(NewIntKey(1)); err == nil {
+		t.Errorf("Lookup(wrong kind) error = nil, want error")
+	}
+	if _, err := tr.Delete(NewIntKey(1), nil); err == nil {
+		t.Errorf("Delete(wrong kind) error = nil, want error")
+	}
+}
@@ -0,0 +1,99 @@
+package btree
+
+import "fmt"
+
+// Iterator walks the values of a key range in key order. The tree must
+// not be modified while an iterator is in use.
+type Iterator struct {
+	s    *session
+	hi   Key
+	leaf *node
+	pos  int
+	vals [][]byte
+	vpos int
+}
+
+// Scan returns an iterator over the values whose keys lie in [lo, hi].
+// A zero Key leaves that end of the range unbounded.
+func (t *Tree) Scan(lo, hi Key) (*Iterator, error) {
+	for _, k := range []Key{lo, hi} {
+		if k.Kind() != 0 {
+			if err := t.checkKey(k); err != nil {
+				return nil, err
+			}
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.newSession()
+	n, err := s.Get(s.root)
+	if err != nil {
+		return nil, err
+	}
+	for n.kind == internalNode {
+		child := n.children[0]
+		if lo.Kind() != 0 {
+			child = n.children[n.childIndex(lo)]
+		}
+		if n, err = s.Get(child); err != nil {
+			return nil, err
+		}
+	}
+	if n.kind != leafNode {
+		return nil, fmt.Errorf("btree: block %d is not a leaf", n.blknum)
+	}
+	pos := 0
+	if lo.Kind() != 0 {
+		pos, _ = n.search(lo)
+	}
+	return &Iterator{s: s, hi: hi, leaf: n, pos: pos - 1}, nil
+}
+
+// Next advances to the next value and reports whether there is one.
+func (it *Iterator) Next() (bool, error) {
+	if it.leaf == nil {
+		return false, nil
+	}
+	if it.vpos+1 < len(it.vals) {
+		it.vpos++
+		return true, nil
+	}
+	t := it.s.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for {
+		it.pos++
+		if it.pos >= len(it.leaf.keys) {
+			if it.leaf.next < 0 {
+				it.leaf = nil
+				return false, nil
+			}
+			// Drop nodes already visited; the iterator only looks forward.
+			it.s.Forget()
+			n, err := it.s.Get(it.leaf.next)
+			if err != nil {
+				return false, err
+			}
+			it.leaf, it.pos = n, -1
+			continue
+		}
+		if it.hi.Kind() != 0 && it.leaf.keys[it.pos].Compare(it.hi) > 0 {
+			it.leaf = nil
+			return false, nil
+		}
+		vals, err := it.s.values(it.leaf, it.pos)
+		if err != nil {
+			return false, err
+		}
+		if len(vals) > 0 {
+			it.vals, it.vpos = vals, 0
+			return true, nil
+		}
+	}
+}
+
+// Key returns the key of the current value.
+func (it *Iterator) Key() Key { return it.leaf.keys[it.pos] }
+
+// Value returns the current value.
+func (it *Iterator) Value() []byte { return it.vals[it.vpos] }
@@ -0,0 +1,84 @@
+package btree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTree_Scan(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_btree_scan")
+	defer os.RemoveAll(testDir)
+
+	_, tr := newTestTree(t, testDir, 128, IntKey)
+	// Even keys 0..398, key 100 with many duplicates in an overflow chain.
+	for i := 0; i < 400; i += 2 {
+		if err := tr.Insert(NewIntKey(i), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+	for range 30 {
+		if err := tr.Insert(NewIntKey(100), []byte("dup")); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		lo, hi    Key
+		wantFirst int
+		wantLast  int
+		wantCount int
+	}{
+		{"all", Key{}, Key{}, 0, 398, 200 + 30},
+		{"bounded inclusive", NewIntKey(10), NewIntKey(20), 10, 20, 6},
+		{"bounds between keys", NewIntKey(11), NewIntKey(19), 12, 18, 4},
+		{"lower only", NewIntKey(390), Key{}, 390, 398, 5},
+		{"upper only", Key{}, NewIntKey(4), 0, 4, 3},
+		{"duplicates", NewIntKey(100), NewIntKey(100), 100, 100, 31},
+		{"empty", NewIntKey(500), Key{}, 0, 0, 0},
+		{"inverted", NewIntKey(20), NewIntKey(10), 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it, err := tr.Scan(tt.lo, tt.hi)
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			var keys []int
+			for {
+				ok, err := it.Next()
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if !ok {
+					break
+				}
+				keys = append(keys, it.Key().Int())
+				if v := string(it.Value()); v != "dup" && v != fmt.Sprint(it.Key().Int()) {
+					t.Errorf("Value() = %q for key %v", v, it.Key())
+				}
+			}
+			if len(keys) != tt.wantCount {
+				t.Fatalf("Scan() returned %d values, want %d", len(keys), tt.wantCount)
+			}
+			if !slices.IsSorted(keys) {
+				t.Errorf("Scan() keys not in order: %v", keys)
+			}
+			if len(keys) > 0 && (keys[0] != tt.wantFirst || keys[len(keys)-1] != tt.wantLast) {
+				t.Errorf("Scan() range = [%d, %d], want [%d, %d]", keys[0], keys[len(keys)-1], tt.wantFirst, tt.wantLast)
+			}
+			if ok, _ := it.Next(); ok {
+				t.Errorf("Next() after end = true, want false")
+			}
+		})
+	}
+
+	if _, err := tr.Scan(NewStringKey("a"), Key{}); err == nil {
+		t.Errorf("Scan(wrong kind) error = nil, want error")
+	}
+}
@@ -0,0 +1,135 @@
+package btree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"simpledb-in-golang/file"
+)
+
+// Key kinds. Every key of a tree has the kind given when the tree was created.
+const (
+	IntKey = iota + 1
+	StringKey
+	BytesKey
+)
+
+// Key is an index key: a 32-bit int, a string or a byte slice.
+// The zero Key is used as an unbounded end of a range.
+type Key struct {
+	kind int
+	i    int
+	s    string
+	b    []byte
+}
+
+// NewIntKey creates an int key. Values are stored as 32-bit integers.
+func NewIntKey(v int) Key { return Key{kind: IntKey, i: int(int32(v))} }
+
+// NewStringKey creates a string key.
+func NewStringKey(s string) Key { return Key{kind: StringKey, s: s} }
+
+// NewBytesKey creates a byte-slice key.
+func NewBytesKey(b []byte) Key { return Key{kind: BytesKey, b: bytes.Clone(b)} }
+
+// Kind returns the kind of the key, or 0 for the zero Key.
+func (k Key) Kind() int { return k.kind }
+
+// Int returns the value of an int key.
+func (k Key) Int() int { return k.i }
+
+// Str returns the value of a string key.
+func (k Key) Str() string { return k.s }
+
+// Bytes returns the value of a byte-slice key.
+func (k Key) Bytes() []byte { return bytes.Clone(k.b) }
+
+// Compare returns -1, 0 or +1 depending on whether k sorts before, equal to
+// or after o. Both keys must have the same kind.
+func (k Key) Compare(o Key) int {
+	switch k.kind {
+	case IntKey:
+		switch {
+		case k.i < o.i:
+			return -1
+		case k.i > o.i:
+			return 1
+		}
+		return 0
+	case StringKey:
+		return strings.Compare(k.s, o.s)
+	default:
+		return bytes.Compare(k.b, o.b)
+	}
+}
+
+// String returns a string representation of the key.
+func (k Key) String() string {
+	switch k.kind {
+	case IntKey:
+		return strconv.Itoa(k.i)
+	case StringKey:
+		return strconv.Quote(k.s)
+	case BytesKey:
+		return "0x" + hex.EncodeToString(k.b)
+	default:
+		return "<unbounded>"
+	}
+}
+
+// size returns the number of bytes needed to store the key in a page.
+func (k Key) size() int {
+	switch k.kind {
+	case IntKey:
+		return 4
+	case StringKey:
+		return file.MaxLength(len(k.s))
+	default:
+		return file.MaxLength(len(k.b))
+	}
+}
+
+// setKey stores a key of the given kind at offset and returns the offset following it.
+func setKey(p *file.Page, offset int, k Key) (int, error) {
+	var err error
+	switch k.kind {
+	case IntKey:
+		err = p.SetInt(offset, k.i)
+	case StringKey:
+		err = p.SetString(offset, k.s)
+	default:
+		err = p.SetBytes(offset, k.b)
+	}
+	return offset + k.size(), err
+}
+
+// getKey reads a key of the given kind at offset and returns the offset following it.
+func getKey(p *file.Page, offset, kind int) (Key, int, error) {
+	var k Key
+	switch kind {
+	case IntKey:
+		v, err := p.GetInt(offset)
+		if err != nil {
+			return k, 0, err
+		}
+		k = NewIntKey(v)
+	case StringKey:
+		s, err := p.GetString(offset)
+		if err != nil {
+			return k, 0, err
+		}
+		k = NewStringKey(s)
+	case BytesKey:
+		b, err := p.GetBytes(offset)
+		if err != nil {
+			return k, 0, err
+		}
+		k = Key{kind: BytesKey, b: b}
+	default:
+		return k, 0, fmt.Errorf("unknown key kind %d", kind)
+	}
+	return k, offset + k.size(), nil
+}
@@ -0,0 +1,87 @@
+package btree
+
+import (
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+func TestKey_Compare(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a, b Key
+		want int
+	}{
+		{"int less", NewIntKey(-5), NewIntKey(3), -1},
+		{"int equal", NewIntKey(7), NewIntKey(7), 0},
+		{"int greater", NewIntKey(8), NewIntKey(7), 1},
+		{"string less", NewStringKey("abc"), NewStringKey("abd"), -1},
+		{"string prefix", NewStringKey("ab"), NewStringKey("abc"), -1},
+		{"string equal", NewStringKey("x"), NewStringKey("x"), 0},
+		{"bytes greater", NewBytesKey([]byte{2}), NewBytesKey([]byte{1, 9}), 1},
+		{"bytes empty", NewBytesKey(nil), NewBytesKey([]byte{0}), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.a.Compare(tt.b); got != tt.want {
+				t.Errorf("Compare(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKey_SetGet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		key  Key
+	}{
+		{"int", NewIntKey(-42)},
+		{"string", NewStringKey("hello")},
+		{"empty string", NewStringKey("")},
+		{"bytes", NewBytesKey([]byte{0, 1, 255})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := file.NewPage(64)
+			end, err := setKey(p, 10, tt.key)
+			if err != nil {
+				t.Fatalf("setKey() error = %v", err)
+			}
+			got, gotEnd, err := getKey(p, 10, tt.key.Kind())
+			if err != nil {
+				t.Fatalf("getKey() error = %v", err)
+			}
+			if got.Compare(tt.key) != 0 {
+				t.Errorf("getKey() = %v, want %v", got, tt.key)
+			}
+			if gotEnd != end || end != 10+tt.key.size() {
+				t.Errorf("offsets = %d, %d, want %d", end, gotEnd, 10+tt.key.size())
+			}
+		})
+	}
+}
+
+func TestKey_String(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key  Key
+		want string
+	}{
+		{NewIntKey(12), "12"},
+		{NewStringKey("a b"), `"a b"`},
+		{NewBytesKey([]byte{0xab, 0x01}), "0xab01"},
+		{Key{}, "<unbounded>"},
+	}
+	for _, tt := range tests {
+		if got := tt.key.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
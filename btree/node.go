@@ -0,0 +1,246 @@
+package btree
+
+import (
+	"fmt"
+
+	"simpledb-in-golang/file"
+)
+
+// Node kinds, stored as the first int of every node block.
+const (
+	leafNode = iota + 1
+	internalNode
+	overflowNode
+)
+
+// nodeHeaderSize is the size of the kind, count and next fields that start every node block.
+const nodeHeaderSize = 12
+
+// node is the decoded contents of a tree block.
+//
+// A leaf holds distinct keys in order; each key has its values either
+// inline or, once they no longer fit, in a chain of overflow blocks
+// starting at overflow. next links the leaf to its right sibling.
+//
+// An internal node holds keys k1..kn and children c0..cn; child ci covers
+// the keys k with k(i) <= k < k(i+1).
+//
+// An overflow block holds the values of a single leaf key; next links to
+// the following block of the chain.
+type node struct {
+	blknum int
+	kind   int
+	next   int
+
+	keys []Key
+
+	// leaf
+	vals     [][][]byte
+	overflow []int
+
+	// internal
+	children []int
+
+	// overflow
+	items [][]byte
+}
+
+// size returns the number of bytes needed to encode the node.
+func (n *node) size() int {
+	sz := nodeHeaderSize
+	switch n.kind {
+	case leafNode:
+		for i := range n.keys {
+			sz += n.entrySize(i)
+		}
+	case internalNode:
+		sz += 4
+		for _, k := range n.keys {
+			sz += k.size() + 4
+		}
+	case overflowNode:
+		for _, v := range n.items {
+			sz += file.MaxLength(len(v))
+		}
+	}
+	return sz
+}
+
+// entrySize returns the encoded size of the i-th entry of a leaf:
+// the key, the overflow block, the value count and the inline values.
+func (n *node) entrySize(i int) int {
+	sz := n.keys[i].size() + 8
+	for _, v := range n.vals[i] {
+		sz += file.MaxLength(len(v))
+	}
+	return sz
+}
+
+// search returns the position of the first key that is >= k, and whether it equals k.
+func (n *node) search(k Key) (int, bool) {
+	lo, hi := 0, len(n.keys)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if n.keys[mid].Compare(k) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, lo < len(n.keys) && n.keys[lo].Compare(k) == 0
+}
+
+// childIndex returns the index of the child of an internal node covering k.
+func (n *node) childIndex(k Key) int {
+	i, found := n.search(k)
+	if found {
+		i++
+	}
+	return i
+}
+
+// Number returns the block number of the node.
+func (n *node) Number() int { return n.blknum }
+
+// Encode writes the node into p.
+func (n *node) Encode(p *file.Page) error {
+	clear(p.Buffer())
+	count := len(n.keys)
+	if n.kind == overflowNode {
+		count = len(n.items)
+	}
+	if err := p.SetInt(0, n.kind); err != nil {
+		return err
+	}
+	if err := p.SetInt(4, count); err != nil {
+		return err
+	}
+	if err := p.SetInt(8, n.next); err != nil {
+		return err
+	}
+	pos := nodeHeaderSize
+	var err error
+	switch n.kind {
+	case leafNode:
+		for i, k := range n.keys {
+			if pos, err = setKey(p, pos, k); err != nil {
+				return err
+			}
+			if err := p.SetInt(pos, n.overflow[i]); err != nil {
+				return err
+			}
+			if err := p.SetInt(pos+4, len(n.vals[i])); err != nil {
+				return err
+			}
+			pos += 8
+			for _, v := range n.vals[i] {
+				if err := p.SetBytes(pos, v); err != nil {
+					return err
+				}
+				pos += file.MaxLength(len(v))
+			}
+		}
+	case internalNode:
+		if err := p.SetInt(pos, n.children[0]); err != nil {
+			return err
+		}
+		pos += 4
+		for i, k := range n.keys {
+			if pos, err = setKey(p, pos, k); err != nil {
+				return err
+			}
+			if err := p.SetInt(pos, n.children[i+1]); err != nil {
+				return err
+			}
+			pos += 4
+		}
+	case overflowNode:
+		for _, v := range n.items {
+			if err := p.SetBytes(pos, v); err != nil {
+				return err
+			}
+			pos += file.MaxLength(len(v))
+		}
+	}
+	return nil
+}
+
+// decodeNode reads the node stored in p, whose keys have the given kind.
+func decodeNode(p *file.Page, blknum, keyKind int) (*node, error) {
+	kind, err := p.GetInt(0)
+	if err != nil {
+		return nil, err
+	}
+	count, err := p.GetInt(4)
+	if err != nil {
+		return nil, err
+	}
+	next, err := p.GetInt(8)
+	if err != nil {
+		return nil, err
+	}
+	n := &node{blknum: blknum, kind: kind, next: int(int32(next))}
+	pos := nodeHeaderSize
+	switch kind {
+	case leafNode:
+		for range count {
+			var k Key
+			if k, pos, err = getKey(p, pos, keyKind); err != nil {
+				return nil, err
+			}
+			ovf, err := p.GetInt(pos)
+			if err != nil {
+				return nil, err
+			}
+			nvals, err := p.GetInt(pos + 4)
+			if err != nil {
+				return nil, err
+			}
+			pos += 8
+			vals := make([][]byte, 0, nvals)
+			for range nvals {
+				v, err := p.GetBytes(pos)
+				if err != nil {
+					return nil, err
+				}
+				vals = append(vals, v)
+				pos += file.MaxLength(len(v))
+			}
+			n.keys = append(n.keys, k)
+			n.overflow = append(n.overflow, int(int32(ovf)))
+			n.vals = append(n.vals, vals)
+		}
+	case internalNode:
+		c0, err := p.GetInt(pos)
+		if err != nil {
+			return nil, err
+		}
+		n.children = append(n.children, c0)
+		pos += 4
+		for range count {
+			var k Key
+			if k, pos, err = getKey(p, pos, keyKind); err != nil {
+				return nil, err
+			}
+			c, err := p.GetInt(pos)
+			if err != nil {
+				return nil, err
+			}
+			pos += 4
+			n.keys = append(n.keys, k)
+			n.children = append(n.children, c)
+		}
+	case overflowNode:
+		for range count {
+			v, err := p.GetBytes(pos)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, v)
+			pos += file.MaxLength(len(v))
+		}
+	default:
+		return nil, fmt.Errorf("block %d is not a tree node (kind %d)", blknum, kind)
+	}
+	return n, nil
+}
@@ -0,0 +1,84 @@
+package btree
+
+import (
+	"reflect"
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+func TestNode_EncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		n    *node
+	}{
+		{"empty leaf", &node{blknum: 1, kind: leafNode, next: -1}},
+		{"leaf", &node{
+			blknum:   2,
+			kind:     leafNode,
+			next:     5,
+			keys:     []Key{NewStringKey("a"), NewStringKey("bb")},
+			vals:     [][][]byte{{[]byte("x"), []byte("yz")}, {}},
+			overflow: []int{-1, 9},
+		}},
+		{"internal", &node{
+			blknum:   3,
+			kind:     internalNode,
+			next:     -1,
+			keys:     []Key{NewStringKey("m"), NewStringKey("t")},
+			children: []int{4, 6, 8},
+		}},
+		{"overflow", &node{
+			blknum: 4,
+			kind:   overflowNode,
+			next:   -1,
+			items:  [][]byte{[]byte("one"), {}, []byte("three")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := file.NewPage(200)
+			if err := tt.n.Encode(p); err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			got, err := decodeNode(p, tt.n.blknum, StringKey)
+			if err != nil {
+				t.Fatalf("decodeNode() error = %v", err)
+			}
+			if got.size() != tt.n.size() {
+				t.Errorf("size() = %d, want %d", got.size(), tt.n.size())
+			}
+			if got.kind != tt.n.kind || got.next != tt.n.next || len(got.keys) != len(tt.n.keys) {
+				t.Fatalf("decodeNode() = %+v, want %+v", got, tt.n)
+			}
+			for i := range got.keys {
+				if got.keys[i].Compare(tt.n.keys[i]) != 0 {
+					t.Errorf("key %d = %v, want %v", i, got.keys[i], tt.n.keys[i])
+				}
+			}
+			if !reflect.DeepEqual(got.children, tt.n.children) || !reflect.DeepEqual(got.overflow, tt.n.overflow) {
+				t.Errorf("pointers = %v %v, want %v %v", got.children, got.overflow, tt.n.children, tt.n.overflow)
+			}
+			for i := range tt.n.vals {
+				if len(got.vals[i]) != len(tt.n.vals[i]) {
+					t.Errorf("vals[%d] = %q, want %q", i, got.vals[i], tt.n.vals[i])
+				}
+			}
+			if len(got.items) != len(tt.n.items) {
+				t.Errorf("items = %q, want %q", got.items, tt.n.items)
+			}
+		})
+	}
+}
+
+func TestNode_DecodeRejectsGarbage(t *testing.T) {
+	t.Parallel()
+
+	p := file.NewPage(64)
+	if _, err := decodeNode(p, 1, IntKey); err == nil {
+		t.Errorf("decodeNode(zero block) error = nil, want error")
+	}
+}
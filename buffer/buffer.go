@@ -0,0 +1,102 @@
+package buffer
+
+import (
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/log"
+)
+
+// Buffer wraps a page and stores information about its status,
+// such as the assigned block, the pin count, and whether the
+// contents have been modified (and if so, by which transaction).
+type Buffer struct {
+	fm       *file.FileMgr
+	lm       *log.LogMgr
+	contents *file.Page
+	blk      file.BlockId
+	assigned bool
+	pins     int
+	txnum    int
+	lsn      int
+}
+
+// NewBuffer creates an unassigned buffer whose page matches the file manager's block size.
+// lm may be nil, as for NewBufferMgr.
+func NewBuffer(fm *file.FileMgr, lm *log.LogMgr) *Buffer {
+	return &Buffer{
+		fm:       fm,
+		lm:       lm,
+		contents: file.NewPage(fm.BlockSize()),
+		txnum:    -1,
+		lsn:      -1,
+	}
+}
+
+// Contents returns the page held by the buffer.
+func (b *Buffer) Contents() *file.Page { return b.contents }
+
+// Block returns the block assigned to the buffer.
+func (b *Buffer) Block() file.BlockId { return b.blk }
+
+// SetModified marks the buffer as dirty on behalf of the specified transaction.
+// A non-negative lsn records the log record describing the change, which must
+// be flushed before the page itself is written.
+func (b *Buffer) SetModified(txnum, lsn int) {
+	b.txnum = txnum
+	if lsn >= 0 {
+		b.lsn = lsn
+	}
+}
+
+// IsPinned returns true if the buffer currently has a non-zero pin count.
+func (b *Buffer) IsPinned() bool { return b.pins > 0 }
+
+// ModifyingTx returns the transaction that last modified the buffer, or -1 if it is clean.
+func (b *Buffer) ModifyingTx() int { return b.txnum }
+
+// assignToBlock reads the contents of the specified block into the buffer,
+// writing out the previous contents first if they were modified.
+func (b *Buffer) assignToBlock(blk file.BlockId) error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	if err := b.fm.Read(blk, b.contents); err != nil {
+		b.assigned = false
+		return err
+	}
+	b.blk = blk
+	b.assigned = true
+	b.pins = 0
+	return nil
+}
+
+// flush writes the buffer to its disk block if it is dirty,
+// forcing the corresponding log record to disk first, if there is a log.
+func (b *Buffer) flush() error {
+	if b.txnum < 0 {
+		return nil
+	}
+	if b.lsn >= 0 && b.lm != nil {
+		if err := b.lm.Flush(b.lsn); err != nil {
+			return err
+		}
+	}
+	if err := b.fm.Write(b.blk, b.contents); err != nil {
+		return err
+	}
+	b.txnum = -1
+	b.lsn = -1
+	return nil
+}
+
+// pin increases the buffer's pin count.
+func (b *Buffer) pin() { b.pins++ }
+
+// unpin decreases the buffer's pin count. It fails if the buffer is not
+// pinned, which would leave the count negative.
+func (b *Buffer) unpin() error {
+	if b.pins == 0 {
+		return ErrNotPinned
+	}
+	b.pins--
+	return nil
+}
@@ -0,0 +1,152 @@
+package buffer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/log"
+)
+
+// ErrBufferAbort is returned by Pin when no buffer became available within the wait timeout.
+var ErrBufferAbort = errors.New("buffer: timed out waiting for an available buffer")
+
+// ErrNotPinned is returned by Unpin when the buffer is not pinned.
+var ErrNotPinned = errors.New("buffer: unpinning a buffer that is not pinned")
+
+// BufferMgr manages the pinning and unpinning of buffers to blocks.
+type BufferMgr struct {
+	bufferpool   []*Buffer
+	numAvailable int
+	maxWait      time.Duration
+
+	mu sync.Mutex
+	// avail is closed (and replaced) whenever a buffer becomes unpinned,
+	// waking every client blocked in Pin.
+	avail chan struct{}
+}
+
+// NewBufferMgr creates a buffer manager having the specified number of buffer slots.
+// Pin waits at most maxWait for a buffer to become available. lm may be
+// nil when changes to the buffers are not logged; modified pages are then
+// written without forcing the log first.
+func NewBufferMgr(fm *file.FileMgr, lm *log.LogMgr, numbuffs int, maxWait time.Duration) *BufferMgr {
+	pool := make([]*Buffer, numbuffs)
+	for i := range pool {
+		pool[i] = NewBuffer(fm, lm)
+	}
+	return &BufferMgr{
+		bufferpool:   pool,
+		numAvailable: numbuffs,
+		maxWait:      maxWait,
+		avail:        make(chan struct{}),
+	}
+}
+
+// Available returns the number of unpinned buffers.
+func (bm *BufferMgr) Available() int {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.numAvailable
+}
+
+// FlushAll writes every modified buffer to disk.
+func (bm *BufferMgr) FlushAll() error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	for _, buff := range bm.bufferpool {
+		if err := buff.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unpin decreases the pin count of the specified buffer.
+// If the count reaches zero, waiting clients are notified.
+// Unpinning a buffer that is not pinned returns ErrNotPinned.
+func (bm *BufferMgr) Unpin(buff *Buffer) error {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if err := buff.unpin(); err != nil {
+		return err
+	}
+	if !buff.IsPinned() {
+		bm.numAvailable++
+		close(bm.avail)
+		bm.avail = make(chan struct{})
+	}
+	return nil
+}
+
+// Pin pins a buffer to the specified block, waiting until a buffer becomes
+// available if every buffer is pinned. If none is available within the
+// configured timeout, ErrBufferAbort is returned.
+func (bm *BufferMgr) Pin(blk file.BlockId) (*Buffer, error) {
+	deadline := time.Now().Add(bm.maxWait)
+	bm.mu.Lock()
+	for {
+		buff, err := bm.tryToPin(blk)
+		if err != nil || buff != nil {
+			bm.mu.Unlock()
+			return buff, err
+		}
+		wait := bm.avail
+		bm.mu.Unlock()
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, ErrBufferAbort
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-wait:
+			timer.Stop()
+		case <-timer.C:
+			return nil, ErrBufferAbort
+		}
+		bm.mu.Lock()
+	}
+}
+
+// tryToPin pins a buffer to the specified block. If a buffer is already
+// assigned to that block it is reused; otherwise an unpinned buffer is
+// chosen. Returns nil if no buffer is available. The caller must hold bm.mu.
+func (bm *BufferMgr) tryToPin(blk file.BlockId) (*Buffer, error) {
+	buff := bm.findExistingBuffer(blk)
+	if buff == nil {
+		buff = bm.chooseUnpinnedBuffer()
+		if buff == nil {
+			return nil, nil
+		}
+		if err := buff.assignToBlock(blk); err != nil {
+			return nil, err
+		}
+	}
+	if !buff.IsPinned() {
+		bm.numAvailable--
+	}
+	buff.pin()
+	return buff, nil
+}
+
+// findExistingBuffer returns the buffer assigned to the specified block, if any.
+func (bm *BufferMgr) findExistingBuffer(blk file.BlockId) *Buffer {
+	for _, buff := range bm.bufferpool {
+		if buff.assigned && buff.Block() == blk {
+			return buff
+		}
+	}
+	return nil
+}
+
+// chooseUnpinnedBuffer returns the first unpinned buffer, using a naive replacement strategy.
+func (bm *BufferMgr) chooseUnpinnedBuffer() *Buffer {
+	for _, buff := range bm.bufferpool {
+		if !buff.IsPinned() {
+			return buff
+		}
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+package buffer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/log"
+)
+
+func newTestBufferMgr(t *testing.T, dir string, numbuffs int, maxWait time.Duration) (*file.FileMgr, *BufferMgr) {
+	t.Helper()
+
+	fm, err := file.NewFileMgr(dir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	lm, err := log.NewLogMgr(fm, "buffertest.log")
+	if err != nil {
+		t.Fatalf("NewLogMgr() failed: %v", err)
+	}
+	for range 10 {
+		if _, err := fm.Append("testfile"); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+	return fm, NewBufferMgr(fm, lm, numbuffs, maxWait)
+}
+
+func TestBufferMgr_Pin(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_buffermgr_pin")
+	defer os.RemoveAll(testDir)
+
+	_, bm := newTestBufferMgr(t, testDir, 3, 50*time.Millisecond)
+
+	buff := make([]*Buffer, 6)
+	var err error
+	for i := range 3 {
+		if buff[i], err = bm.Pin(file.NewBlockId("testfile", i)); err != nil {
+			t.Fatalf("Pin(%d) error = %v", i, err)
+		}
+	}
+	if bm.Available() != 0 {
+		t.Errorf("Available() = %v, want 0", bm.Available())
+	}
+
+	// Pinning an already-pinned block reuses its buffer.
+	again, err := bm.Pin(file.NewBlockId("testfile", 0))
+	if err != nil {
+		t.Fatalf("Pin(0) again error = %v", err)
+	}
+	if again != buff[0] {
+		t.Errorf("Pin(0) again returned a different buffer")
+	}
+	bm.Unpin(again)
+
+	if err := bm.Unpin(buff[1]); err != nil {
+		t.Fatalf("Unpin() error = %v", err)
+	}
+	if bm.Available() != 1 {
+		t.Errorf("Available() after unpin = %v, want 1", bm.Available())
+	}
+	// An unpinned buffer cannot be unpinned again, nor counted twice.
+	if err := bm.Unpin(buff[1]); !errors.Is(err, ErrNotPinned) {
+		t.Errorf("Unpin() of an unpinned buffer error = %v, want %v", err, ErrNotPinned)
+	}
+	if bm.Available() != 1 {
+		t.Errorf("Available() after a second unpin = %v, want 1", bm.Available())
+	}
+	if buff[3], err = bm.Pin(file.NewBlockId("testfile", 3)); err != nil {
+		t.Fatalf("Pin(3) error = %v", err)
+	}
+
+	_, err = bm.Pin(file.NewBlockId("testfile", 4))
+	if !errors.Is(err, ErrBufferAbort) {
+		t.Errorf("Pin() with no free buffer error = %v, want %v", err, ErrBufferAbort)
+	}
+}
+
+func TestBufferMgr_Pin_WaitsForUnpin(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_buffermgr_wait")
+	defer os.RemoveAll(testDir)
+
+	_, bm := newTestBufferMgr(t, testDir, 1, 5*time.Second)
+
+	held, err := bm.Pin(file.NewBlockId("testfile", 0))
+	if err != nil {
+		t.Fatalf("Pin(0) error = %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		buff, err := bm.Pin(file.NewBlockId("testfile", 1))
+		if err == nil && buff.Block().Number() != 1 {
+			err = errors.New("pinned wrong block")
+		}
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	bm.Unpin(held)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("waiting Pin() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("waiting Pin() was not woken by Unpin")
+	}
+}
+
+func TestBufferMgr_FlushAll(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_buffermgr_flushall")
+	defer os.RemoveAll(testDir)
+
+	fm, bm := newTestBufferMgr(t, testDir, 3, 50*time.Millisecond)
+
+	for i := range 3 {
+		buff, err := bm.Pin(file.NewBlockId("testfile", i))
+		if err != nil {
+			t.Fatalf("Pin(%d) error = %v", i, err)
+		}
+		if err := buff.Contents().SetInt(0, 100+i); err != nil {
+			t.Fatalf("SetInt() failed: %v", err)
+		}
+		buff.SetModified(1, -1)
+		bm.Unpin(buff)
+	}
+
+	if err := bm.FlushAll(); err != nil {
+		t.Fatalf("FlushAll() error = %v", err)
+	}
+
+	p := file.NewPage(fm.BlockSize())
+	for i := range 3 {
+		if err := fm.Read(file.NewBlockId("testfile", i), p); err != nil {
+			t.Fatalf("Read() failed: %v", err)
+		}
+		if got, _ := p.GetInt(0); got != 100+i {
+			t.Errorf("block %d = %v, want %v", i, got, 100+i)
+		}
+	}
+}
+
+func TestBufferMgr_WithoutLog(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_buffermgr_nolog")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	blk, err := fm.Append("testfile")
+	if err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	bm := NewBufferMgr(fm, nil, 1, 50*time.Millisecond)
+
+	buff, err := bm.Pin(blk)
+	if err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+	if err := buff.Contents().SetInt(0, 7); err != nil {
+		t.Fatalf("SetInt() failed: %v", err)
+	}
+	buff.SetModified(1, 3)
+	if err := bm.Unpin(buff); err != nil {
+		t.Fatalf("Unpin() error = %v", err)
+	}
+	if err := bm.FlushAll(); err != nil {
+		t.Fatalf("FlushAll() error = %v", err)
+	}
+
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(blk, p); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got, _ := p.GetInt(0); got != 7 {
+		t.Errorf("block = %v, want 7", got)
+	}
+}
+
+func TestBufferMgr_ReplacementWritesDirtyPage(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_buffermgr_replace")
+	defer os.RemoveAll(testDir)
+
+	fm, bm := newTestBufferMgr(t, testDir, 1, 50*time.Millisecond)
+
+	buff, err := bm.Pin(file.NewBlockId("testfile", 2))
+	if err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+	if err := buff.Contents().SetString(40, "dirty"); err != nil {
+		t.Fatalf("SetString() failed: %v", err)
+	}
+	buff.SetModified(1, -1)
+	bm.Unpin(buff)
+
+	// Reassigning the only buffer must write the dirty page first.
+	if _, err := bm.Pin(file.NewBlockId("testfile", 5)); err != nil {
+		t.Fatalf("Pin() error = %v", err)
+	}
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(file.NewBlockId("testfile", 2), p); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got, _ := p.GetString(40); got != "dirty" {
+		t.Errorf("replaced block contents = %q, want %q", got, "dirty")
+	}
+}
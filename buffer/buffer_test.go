@@ -0,0 +1,131 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/log"
+)
+
+func TestBuffer_SetModified(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			txnum int
+			lsn   int
+		}
+		wants struct {
+			modifyingTx int
+			lsn         int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "with log record",
+			args:  args{txnum: 1, lsn: 7},
+			wants: wants{modifyingTx: 1, lsn: 7},
+		},
+		{
+			name:  "without log record",
+			args:  args{txnum: 2, lsn: -1},
+			wants: wants{modifyingTx: 2, lsn: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_buffer_modified_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := file.NewFileMgr(testDir, 400)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			lm, err := log.NewLogMgr(fm, "buffertest.log")
+			if err != nil {
+				t.Fatalf("NewLogMgr() failed: %v", err)
+			}
+
+			buff := NewBuffer(fm, lm)
+			if buff.ModifyingTx() != -1 {
+				t.Errorf("new buffer ModifyingTx() = %v, want -1", buff.ModifyingTx())
+			}
+
+			buff.SetModified(tt.args.txnum, tt.args.lsn)
+			if buff.ModifyingTx() != tt.wants.modifyingTx {
+				t.Errorf("ModifyingTx() = %v, want %v", buff.ModifyingTx(), tt.wants.modifyingTx)
+			}
+			if buff.lsn != tt.wants.lsn {
+				t.Errorf("lsn = %v, want %v", buff.lsn, tt.wants.lsn)
+			}
+		})
+	}
+}
+
+func TestBuffer_Flush(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_buffer_flush")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	lm, err := log.NewLogMgr(fm, "buffertest.log")
+	if err != nil {
+		t.Fatalf("NewLogMgr() failed: %v", err)
+	}
+	blk, err := fm.Append("testfile")
+	if err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+
+	buff := NewBuffer(fm, lm)
+	if err := buff.assignToBlock(blk); err != nil {
+		t.Fatalf("assignToBlock() error = %v", err)
+	}
+	if err := buff.Contents().SetInt(80, 123); err != nil {
+		t.Fatalf("SetInt() failed: %v", err)
+	}
+
+	// A clean buffer is never written back.
+	if err := buff.flush(); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(blk, p); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got, _ := p.GetInt(80); got != 0 {
+		t.Errorf("clean flush wrote %v, want 0", got)
+	}
+
+	lsn, err := lm.Append([]byte("change"))
+	if err != nil {
+		t.Fatalf("log Append() failed: %v", err)
+	}
+	buff.SetModified(1, lsn)
+	if err := buff.flush(); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	if err := fm.Read(blk, p); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got, _ := p.GetInt(80); got != 123 {
+		t.Errorf("dirty flush wrote %v, want 123", got)
+	}
+	if buff.ModifyingTx() != -1 {
+		t.Errorf("ModifyingTx() after flush = %v, want -1", buff.ModifyingTx())
+	}
+}
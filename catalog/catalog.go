@@ -0,0 +1,235 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"simpledb-in-golang/file"
+)
+
+// CatalogFile is the reserved file inside the FileMgr directory holding the catalog.
+const CatalogFile = "catalog.dat"
+
+// Column types, matching the file.Page accessors used to store them.
+const (
+	Integer = iota + 1 // GetInt/SetInt
+	Varchar            // GetString/SetString, at most Length characters
+	Blob               // GetBytes/SetBytes, at most Length bytes
+)
+
+// Column describes one column of a data file.
+type Column struct {
+	Name   string
+	Type   int
+	Length int
+}
+
+// Entry describes a data file: its logical name, the file holding it,
+// its column schema, when it was registered and who owns it.
+type Entry struct {
+	Name     string
+	FileName string
+	Columns  []Column
+	Created  time.Time
+	Owner    string
+}
+
+// Catalog records the purpose and schema of every data file. It is kept in
+// memory and rewritten as a whole after each change; the rewrite uses
+// FileMgr.WriteBatch, so every change is atomic even across a crash.
+type Catalog struct {
+	fm *file.FileMgr
+
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// Open loads the catalog of the FileMgr directory, creating an empty one
+// if there is no catalog yet.
+func Open(fm *file.FileMgr) (*Catalog, error) {
+	c := &Catalog{fm: fm, entries: make(map[string]Entry)}
+	n, err := fm.Length(CatalogFile)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		if err := c.save(c.entries); err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Register adds an entry for a new data file. If Created is zero it is set
+// to the current time.
+func (c *Catalog) Register(e Entry) error {
+	if err := validate(e, c.fm.BlockSize()); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[e.Name]; ok {
+		return fmt.Errorf("Register: %s is already registered", e.Name)
+	}
+	if e.Created.IsZero() {
+		e.Created = time.Now()
+	}
+	return c.apply(func(m map[string]Entry) { m[e.Name] = copyEntry(e) })
+}
+
+// Lookup returns the entry with the specified logical name.
+func (c *Catalog) Lookup(name string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[name]
+	return copyEntry(e), ok
+}
+
+// Alter replaces the file name, columns and owner of an existing entry.
+// The creation time is preserved.
+func (c *Catalog) Alter(e Entry) error {
+	if err := validate(e, c.fm.BlockSize()); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, ok := c.entries[e.Name]
+	if !ok {
+		return fmt.Errorf("Alter: %s is not registered", e.Name)
+	}
+	e.Created = old.Created
+	return c.apply(func(m map[string]Entry) { m[e.Name] = copyEntry(e) })
+}
+
+// Drop removes the entry with the specified logical name.
+// The data file itself is left untouched.
+func (c *Catalog) Drop(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[name]; !ok {
+		return fmt.Errorf("Drop: %s is not registered", name)
+	}
+	return c.apply(func(m map[string]Entry) { delete(m, name) })
+}
+
+// Entries returns every entry, ordered by logical name.
+func (c *Catalog) Entries() []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Entry, 0, len(c.entries))
+	for _, e := range c.entries {
+		out = append(out, copyEntry(e))
+	}
+	slices.SortFunc(out, func(a, b Entry) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// apply makes a modified copy of the entries, saves it and, only if that
+// succeeds, makes it current. The caller must hold c.mu.
+func (c *Catalog) apply(change func(map[string]Entry)) error {
+	next := make(map[string]Entry, len(c.entries)+1)
+	for k, v := range c.entries {
+		next[k] = v
+	}
+	change(next)
+	if err := c.save(next); err != nil {
+		return err
+	}
+	c.entries = next
+	return nil
+}
+
+// save atomically writes entries to the catalog file. The encoded catalog
+// is a 4-byte length followed by the data, spread over consecutive blocks.
+func (c *Catalog) save(entries map[string]Entry) error {
+	data := encode(entries)
+	blocksize := c.fm.BlockSize()
+	buf := make([]byte, 4+len(data))
+	p := file.NewPageFromBytes(buf)
+	if err := p.SetBytes(0, data); err != nil {
+		return err
+	}
+	pages := make(map[file.BlockId]*file.Page)
+	for i := 0; i*blocksize < len(buf); i++ {
+		blkpage := file.NewPage(blocksize)
+		copy(blkpage.Buffer(), buf[i*blocksize:])
+		pages[file.NewBlockId(CatalogFile, i)] = blkpage
+	}
+	return c.fm.WriteBatch(pages)
+}
+
+// load reads the catalog file into memory.
+func (c *Catalog) load() error {
+	blocksize := c.fm.BlockSize()
+	p := file.NewPage(blocksize)
+	if err := c.fm.Read(file.NewBlockId(CatalogFile, 0), p); err != nil {
+		return err
+	}
+	size, err := p.GetInt(0)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 0, 4+size)
+	buf = append(buf, p.Buffer()...)
+	for i := 1; len(buf) < 4+size; i++ {
+		if err := c.fm.Read(file.NewBlockId(CatalogFile, i), p); err != nil {
+			return err
+		}
+		buf = append(buf, p.Buffer()...)
+	}
+	data, err := file.NewPageFromBytes(buf).GetBytes(0)
+	if err != nil {
+		return err
+	}
+	entries, err := decode(data)
+	if err != nil {
+		return err
+	}
+	c.entries = entries
+	return nil
+}
+
+// validate checks that an entry is well formed and that every column value
+// fits in a block of the given size.
+func validate(e Entry, blocksize int) error {
+	if e.Name == "" {
+		return errors.New("catalog: entry has no name")
+	}
+	if e.FileName == "" {
+		return fmt.Errorf("catalog: entry %s has no file name", e.Name)
+	}
+	seen := make(map[string]bool)
+	for _, col := range e.Columns {
+		if col.Name == "" {
+			return fmt.Errorf("catalog: entry %s has an unnamed column", e.Name)
+		}
+		if seen[col.Name] {
+			return fmt.Errorf("catalog: entry %s has duplicate column %s", e.Name, col.Name)
+		}
+		seen[col.Name] = true
+		switch col.Type {
+		case Integer:
+		case Varchar, Blob:
+			if col.Length <= 0 || file.MaxLength(col.Length) > blocksize {
+				return fmt.Errorf("catalog: column %s.%s has invalid length %d", e.Name, col.Name, col.Length)
+			}
+		default:
+			return fmt.Errorf("catalog: column %s.%s has unknown type %d", e.Name, col.Name, col.Type)
+		}
+	}
+	return nil
+}
+
+// copyEntry returns an entry that shares no memory with e.
+func copyEntry(e Entry) Entry {
+	e.Columns = slices.Clone(e.Columns)
+	return e
+}
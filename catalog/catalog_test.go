@@ -0,0 +1,235 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"simpledb-in-golang/file"
+)
+
+func studentEntry() Entry {
+	return Entry{
+		Name:     "student",
+		FileName: "student.tbl",
+		Columns: []Column{
+			{Name: "id", Type: Integer},
+			{Name: "name", Type: Varchar, Length: 20},
+			{Name: "photo", Type: Blob, Length: 100},
+		},
+		Owner: "registrar",
+	}
+}
+
+func TestOpen_Bootstrap(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_catalog_bootstrap")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	if !fm.IsNew() {
+		t.Fatalf("IsNew() = false, want true")
+	}
+	c, err := Open(fm)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if n := len(c.Entries()); n != 0 {
+		t.Errorf("Entries() on new database = %v entries, want 0", n)
+	}
+	if n, _ := fm.Length(CatalogFile); n == 0 {
+		t.Errorf("catalog file was not created")
+	}
+
+	// A second Open of the still new database loads the catalog rather
+	// than bootstrapping an empty one over it.
+	if err := c.Register(studentEntry()); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	c2, err := Open(fm)
+	if err != nil {
+		t.Fatalf("Open() again error = %v", err)
+	}
+	if _, ok := c2.Lookup("student"); !ok {
+		t.Errorf("Lookup() after opening again ok = false, want true")
+	}
+}
+
+func TestCatalog_RegisterLookupPersist(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_catalog_register")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	c, err := Open(fm)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	before := time.Now()
+	if err := c.Register(studentEntry()); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	// Enough entries to spill the catalog over several blocks.
+	for i := range 20 {
+		e := studentEntry()
+		e.Name = "copy" + string(rune('a'+i))
+		e.FileName = e.Name + ".tbl"
+		if err := c.Register(e); err != nil {
+			t.Fatalf("Register(%s) error = %v", e.Name, err)
+		}
+	}
+	if err := c.Register(studentEntry()); err == nil {
+		t.Errorf("Register() duplicate error = nil, want error")
+	}
+
+	got, ok := c.Lookup("student")
+	if !ok {
+		t.Fatalf("Lookup() ok = false, want true")
+	}
+	if got.Created.Before(before.Add(-time.Second)) {
+		t.Errorf("Created = %v, want about %v", got.Created, before)
+	}
+	got.Columns[0].Name = "mutated"
+	if again, _ := c.Lookup("student"); again.Columns[0].Name != "id" {
+		t.Errorf("Lookup() result shares memory with the catalog")
+	}
+
+	// Reopen and check that everything survived.
+	fm2, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() reopen failed: %v", err)
+	}
+	c2, err := Open(fm2)
+	if err != nil {
+		t.Fatalf("Open() reopen error = %v", err)
+	}
+	if n := len(c2.Entries()); n != 21 {
+		t.Errorf("Entries() after reopen = %v entries, want 21", n)
+	}
+	reloaded, ok := c2.Lookup("student")
+	if !ok {
+		t.Fatalf("Lookup() after reopen ok = false, want true")
+	}
+	want := studentEntry()
+	if reloaded.FileName != want.FileName || reloaded.Owner != want.Owner ||
+		!slices.Equal(reloaded.Columns, want.Columns) {
+		t.Errorf("Lookup() after reopen = %+v, want %+v", reloaded, want)
+	}
+	orig, _ := c.Lookup("student")
+	if !reloaded.Created.Equal(orig.Created) {
+		t.Errorf("Created after reopen = %v, want %v", reloaded.Created, orig.Created)
+	}
+}
+
+func TestCatalog_AlterDrop(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_catalog_alter")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	c, err := Open(fm)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := c.Register(studentEntry()); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	orig, _ := c.Lookup("student")
+
+	altered := studentEntry()
+	altered.Columns = append(altered.Columns, Column{Name: "year", Type: Integer})
+	altered.Owner = "admissions"
+	altered.Created = time.Unix(0, 0)
+	if err := c.Alter(altered); err != nil {
+		t.Fatalf("Alter() error = %v", err)
+	}
+	got, _ := c.Lookup("student")
+	if len(got.Columns) != 4 || got.Owner != "admissions" {
+		t.Errorf("Lookup() after Alter() = %+v", got)
+	}
+	if !got.Created.Equal(orig.Created) {
+		t.Errorf("Alter() changed Created to %v, want %v", got.Created, orig.Created)
+	}
+
+	missing := studentEntry()
+	missing.Name = "missing"
+	if err := c.Alter(missing); err == nil {
+		t.Errorf("Alter() of unregistered entry error = nil, want error")
+	}
+
+	if err := c.Drop("student"); err != nil {
+		t.Fatalf("Drop() error = %v", err)
+	}
+	if _, ok := c.Lookup("student"); ok {
+		t.Errorf("Lookup() after Drop() ok = true, want false")
+	}
+	if err := c.Drop("student"); err == nil {
+		t.Errorf("Drop() twice error = nil, want error")
+	}
+
+	fm2, _ := file.NewFileMgr(testDir, 400)
+	c2, err := Open(fm2)
+	if err != nil {
+		t.Fatalf("Open() reopen error = %v", err)
+	}
+	if _, ok := c2.Lookup("student"); ok {
+		t.Errorf("dropped entry present after reopen")
+	}
+}
+
+func TestCatalog_Register_Invalid(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_catalog_invalid")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	c, err := Open(fm)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Entry)
+	}{
+		{"no name", func(e *Entry) { e.Name = "" }},
+		{"no file name", func(e *Entry) { e.FileName = "" }},
+		{"unnamed column", func(e *Entry) { e.Columns[0].Name = "" }},
+		{"duplicate column", func(e *Entry) { e.Columns[1].Name = "id" }},
+		{"unknown type", func(e *Entry) { e.Columns[0].Type = 42 }},
+		{"zero length varchar", func(e *Entry) { e.Columns[1].Length = 0 }},
+		{"blob larger than a block", func(e *Entry) { e.Columns[2].Length = 400 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := studentEntry()
+			tt.modify(&e)
+			if err := c.Register(e); err == nil {
+				t.Errorf("Register() error = nil, want error")
+			}
+		})
+	}
+	if n := len(c.Entries()); n != 0 {
+		t.Errorf("Entries() = %v entries after invalid registrations, want 0", n)
+	}
+}
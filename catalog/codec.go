@@ -0,0 +1,129 @@
+package catalog
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"simpledb-in-golang/file"
+)
+
+// encode serializes the entries, ordered by name, as an entry count followed by
+// name, file name, creation time, owner, column count and (name, type, length)
+// for each column.
+func encode(entries map[string]Entry) []byte {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, strings.Compare)
+
+	size := 4
+	for _, name := range names {
+		e := entries[name]
+		size += file.MaxLength(len(e.Name)) + file.MaxLength(len(e.FileName)) +
+			file.MaxLength(len(formatTime(e.Created))) + file.MaxLength(len(e.Owner)) + 4
+		for _, col := range e.Columns {
+			size += file.MaxLength(len(col.Name)) + 8
+		}
+	}
+
+	w := &writer{p: file.NewPage(size)}
+	w.int(len(names))
+	for _, name := range names {
+		e := entries[name]
+		w.string(e.Name)
+		w.string(e.FileName)
+		w.string(formatTime(e.Created))
+		w.string(e.Owner)
+		w.int(len(e.Columns))
+		for _, col := range e.Columns {
+			w.string(col.Name)
+			w.int(col.Type)
+			w.int(col.Length)
+		}
+	}
+	return w.p.Buffer()
+}
+
+// decode parses the output of encode.
+func decode(b []byte) (map[string]Entry, error) {
+	r := &reader{p: file.NewPageFromBytes(b)}
+	entries := make(map[string]Entry)
+	n := r.int()
+	for range n {
+		var e Entry
+		e.Name = r.string()
+		e.FileName = r.string()
+		created := r.string()
+		e.Owner = r.string()
+		ncols := r.int()
+		for range ncols {
+			if r.err != nil {
+				break
+			}
+			col := Column{Name: r.string()}
+			col.Type = r.int()
+			col.Length = r.int()
+			e.Columns = append(e.Columns, col)
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		t, err := time.Parse(time.RFC3339Nano, created)
+		if err != nil {
+			return nil, err
+		}
+		e.Created = t
+		entries[e.Name] = e
+	}
+	return entries, r.err
+}
+
+// formatTime renders a creation time for storage.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// writer appends values to a page that is known to be large enough.
+type writer struct {
+	p   *file.Page
+	pos int
+}
+
+func (w *writer) int(v int) {
+	w.p.SetInt(w.pos, v)
+	w.pos += 4
+}
+
+func (w *writer) string(s string) {
+	w.p.SetString(w.pos, s)
+	w.pos += file.MaxLength(len(s))
+}
+
+// reader reads consecutive values from a page, remembering the first error.
+type reader struct {
+	p   *file.Page
+	pos int
+	err error
+}
+
+func (r *reader) int() int {
+	if r.err != nil {
+		return 0
+	}
+	v, err := r.p.GetInt(r.pos)
+	r.err = err
+	r.pos += 4
+	return v
+}
+
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	s, err := r.p.GetString(r.pos)
+	r.err = err
+	r.pos += file.MaxLength(len(s))
+	return s
+}
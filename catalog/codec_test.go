@@ -0,0 +1,68 @@
+package catalog
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2024, 5, 1, 12, 30, 0, 123, time.UTC)
+
+	tests := []struct {
+		name    string
+		entries map[string]Entry
+	}{
+		{
+			name:    "empty catalog",
+			entries: map[string]Entry{},
+		},
+		{
+			name: "entry without columns",
+			entries: map[string]Entry{
+				"log": {Name: "log", FileName: "app.log", Created: created},
+			},
+		},
+		{
+			name: "several entries",
+			entries: map[string]Entry{
+				"a": {Name: "a", FileName: "a.tbl", Created: created, Owner: "x",
+					Columns: []Column{{Name: "id", Type: Integer}}},
+				"b": {Name: "b", FileName: "b.tbl", Created: created.Add(time.Hour),
+					Columns: []Column{{Name: "s", Type: Varchar, Length: 8}, {Name: "b", Type: Blob, Length: 16}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := decode(encode(tt.entries))
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+			if len(got) != len(tt.entries) {
+				t.Fatalf("decode() = %v entries, want %v", len(got), len(tt.entries))
+			}
+			for name, want := range tt.entries {
+				e := got[name]
+				if e.Name != want.Name || e.FileName != want.FileName || e.Owner != want.Owner ||
+					!e.Created.Equal(want.Created) || !slices.Equal(e.Columns, want.Columns) {
+					t.Errorf("decode()[%s] = %+v, want %+v", name, e, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecode_Truncated(t *testing.T) {
+	t.Parallel()
+
+	b := encode(map[string]Entry{"a": {Name: "a", FileName: "a.tbl", Created: time.Now()}})
+	if _, err := decode(b[:len(b)-6]); err == nil {
+		t.Errorf("decode() of truncated data error = nil, want error")
+	}
+}
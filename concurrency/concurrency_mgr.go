@@ -0,0 +1,62 @@
+package concurrency
+
+import (
+	"simpledb-in-golang/file"
+)
+
+// ConcurrencyMgr tracks the locks held by a single owner, such as a unit
+// of work, and implements strict two-phase locking: locks are acquired as
+// needed and are only given back, all at once, by Release.
+type ConcurrencyMgr struct {
+	locktbl *LockTable
+	locks   map[file.BlockId]string
+}
+
+// NewConcurrencyMgr creates a lock owner that takes its locks from locktbl.
+func NewConcurrencyMgr(locktbl *LockTable) *ConcurrencyMgr {
+	return &ConcurrencyMgr{
+		locktbl: locktbl,
+		locks:   make(map[file.BlockId]string),
+	}
+}
+
+// SLock obtains a shared lock on the block, if the owner doesn't already hold a lock on it.
+func (cm *ConcurrencyMgr) SLock(blk file.BlockId) error {
+	if _, ok := cm.locks[blk]; ok {
+		return nil
+	}
+	if err := cm.locktbl.SLock(blk); err != nil {
+		return err
+	}
+	cm.locks[blk] = "S"
+	return nil
+}
+
+// XLock obtains an exclusive lock on the block, if the owner doesn't already
+// hold one. A shared lock is obtained first, and then upgraded.
+func (cm *ConcurrencyMgr) XLock(blk file.BlockId) error {
+	if cm.hasXLock(blk) {
+		return nil
+	}
+	if err := cm.SLock(blk); err != nil {
+		return err
+	}
+	if err := cm.locktbl.xLock(blk); err != nil {
+		return err
+	}
+	cm.locks[blk] = "X"
+	return nil
+}
+
+// Release gives back every lock held by the owner.
+func (cm *ConcurrencyMgr) Release() {
+	for blk := range cm.locks {
+		cm.locktbl.Unlock(blk)
+	}
+	clear(cm.locks)
+}
+
+// hasXLock reports whether the owner holds an exclusive lock on blk.
+func (cm *ConcurrencyMgr) hasXLock(blk file.BlockId) bool {
+	return cm.locks[blk] == "X"
+}
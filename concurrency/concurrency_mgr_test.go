@@ -0,0 +1,58 @@
+package concurrency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"simpledb-in-golang/file"
+)
+
+func TestConcurrencyMgr_Locks(t *testing.T) {
+	t.Parallel()
+
+	blk1 := file.NewBlockId("test.db", 1)
+	blk2 := file.NewBlockId("test.db", 2)
+
+	lt := NewLockTable(20 * time.Millisecond)
+	a := NewConcurrencyMgr(lt)
+	b := NewConcurrencyMgr(lt)
+
+	if err := a.SLock(blk1); err != nil {
+		t.Fatalf("a.SLock(blk1) error = %v", err)
+	}
+	if err := b.SLock(blk1); err != nil {
+		t.Fatalf("b.SLock(blk1) error = %v", err)
+	}
+	// Repeated requests by the same owner are no-ops.
+	if err := a.SLock(blk1); err != nil {
+		t.Fatalf("a.SLock(blk1) again error = %v", err)
+	}
+
+	if err := a.XLock(blk2); err != nil {
+		t.Fatalf("a.XLock(blk2) error = %v", err)
+	}
+	if err := a.XLock(blk2); err != nil {
+		t.Fatalf("a.XLock(blk2) again error = %v", err)
+	}
+	if err := b.SLock(blk2); !errors.Is(err, ErrLockAbort) {
+		t.Errorf("b.SLock(blk2) error = %v, want %v", err, ErrLockAbort)
+	}
+	if err := a.XLock(blk1); !errors.Is(err, ErrLockAbort) {
+		t.Errorf("a.XLock(blk1) error = %v, want %v", err, ErrLockAbort)
+	}
+
+	// Releasing everything held by b lets a upgrade its shared lock.
+	b.Release()
+	if err := a.XLock(blk1); err != nil {
+		t.Errorf("a.XLock(blk1) after b.Release() error = %v", err)
+	}
+
+	a.Release()
+	if len(lt.locks) != 0 {
+		t.Errorf("lock table after Release() = %v, want empty", lt.locks)
+	}
+	if err := b.XLock(blk1); err != nil {
+		t.Errorf("b.XLock(blk1) after a.Release() error = %v", err)
+	}
+}
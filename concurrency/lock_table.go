@@ -0,0 +1,115 @@
+package concurrency
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"simpledb-in-golang/file"
+)
+
+// ErrLockAbort is returned when a lock could not be granted within the wait timeout.
+// The caller is expected to abort its unit of work and release its locks.
+var ErrLockAbort = errors.New("concurrency: timed out waiting for a lock")
+
+// LockTable provides methods to lock and unlock blocks. The value stored
+// for a block is -1 if it is exclusively locked, or the number of shared
+// locks otherwise. Requests that cannot be granted wait until the lock is
+// released or the timeout expires. Exclusive locks are obtained through
+// ConcurrencyMgr.XLock.
+type LockTable struct {
+	maxWait time.Duration
+
+	mu    sync.Mutex
+	locks map[file.BlockId]int
+	// released is closed (and replaced) whenever a lock is released,
+	// waking every waiting client so it can retry.
+	released chan struct{}
+}
+
+// NewLockTable creates a lock table whose requests wait at most maxWait.
+func NewLockTable(maxWait time.Duration) *LockTable {
+	return &LockTable{
+		maxWait:  maxWait,
+		locks:    make(map[file.BlockId]int),
+		released: make(chan struct{}),
+	}
+}
+
+// SLock grants a shared lock on the specified block, waiting while
+// another client holds an exclusive lock on it.
+func (lt *LockTable) SLock(blk file.BlockId) error {
+	return lt.acquire(func() bool {
+		if lt.hasXLock(blk) {
+			return false
+		}
+		lt.locks[blk]++
+		return true
+	})
+}
+
+// xLock upgrades the caller's shared lock on the specified block to an
+// exclusive lock, waiting while other clients hold shared locks on it.
+// The table does not track owners, so it cannot check the upgrade
+// itself; only ConcurrencyMgr calls xLock, once it holds the shared lock.
+func (lt *LockTable) xLock(blk file.BlockId) error {
+	return lt.acquire(func() bool {
+		if lt.hasOtherSLocks(blk) {
+			return false
+		}
+		lt.locks[blk] = -1
+		return true
+	})
+}
+
+// Unlock releases one lock on the specified block and wakes waiting clients.
+func (lt *LockTable) Unlock(blk file.BlockId) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	if val := lt.locks[blk]; val > 1 {
+		lt.locks[blk]--
+	} else {
+		delete(lt.locks, blk)
+	}
+	close(lt.released)
+	lt.released = make(chan struct{})
+}
+
+// acquire calls grant, which reports whether the lock could be taken,
+// until it succeeds or the wait timeout expires.
+func (lt *LockTable) acquire(grant func() bool) error {
+	deadline := time.Now().Add(lt.maxWait)
+	lt.mu.Lock()
+	for {
+		if grant() {
+			lt.mu.Unlock()
+			return nil
+		}
+		wait := lt.released
+		lt.mu.Unlock()
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return ErrLockAbort
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-wait:
+			timer.Stop()
+		case <-timer.C:
+			return ErrLockAbort
+		}
+		lt.mu.Lock()
+	}
+}
+
+// hasXLock reports whether blk is exclusively locked. The caller must hold lt.mu.
+func (lt *LockTable) hasXLock(blk file.BlockId) bool {
+	return lt.locks[blk] < 0
+}
+
+// hasOtherSLocks reports whether clients other than the caller hold shared
+// locks on blk. The caller must hold lt.mu.
+func (lt *LockTable) hasOtherSLocks(blk file.BlockId) bool {
+	return lt.locks[blk] > 1
+}
@@ -0,0 +1,122 @@
+package concurrency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"simpledb-in-golang/file"
+)
+
+func TestLockTable(t *testing.T) {
+	t.Parallel()
+
+	blk := file.NewBlockId("test.db", 1)
+
+	type (
+		args struct {
+			// held are the locks granted before the request, in order.
+			held []string
+			// request is the lock requested afterwards.
+			request string
+		}
+		wants struct {
+			err error
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "shared lock on free block",
+			args:  args{held: nil, request: "S"},
+			wants: wants{err: nil},
+		},
+		{
+			name:  "shared locks are compatible",
+			args:  args{held: []string{"S", "S"}, request: "S"},
+			wants: wants{err: nil},
+		},
+		{
+			name:  "upgrade of the only shared lock",
+			args:  args{held: []string{"S"}, request: "X"},
+			wants: wants{err: nil},
+		},
+		{
+			name:  "shared lock waits for exclusive lock",
+			args:  args{held: []string{"S", "X"}, request: "S"},
+			wants: wants{err: ErrLockAbort},
+		},
+		{
+			name:  "upgrade waits for other shared locks",
+			args:  args{held: []string{"S", "S"}, request: "X"},
+			wants: wants{err: ErrLockAbort},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			lt := NewLockTable(20 * time.Millisecond)
+			for _, l := range tt.args.held {
+				var err error
+				if l == "S" {
+					err = lt.SLock(blk)
+				} else {
+					err = lt.xLock(blk)
+				}
+				if err != nil {
+					t.Fatalf("setup %sLock() failed: %v", l, err)
+				}
+			}
+
+			var err error
+			if tt.args.request == "S" {
+				err = lt.SLock(blk)
+			} else {
+				err = lt.xLock(blk)
+			}
+			if !errors.Is(err, tt.wants.err) {
+				t.Errorf("%sLock() error = %v, want %v", tt.args.request, err, tt.wants.err)
+			}
+		})
+	}
+}
+
+func TestLockTable_UnlockWakesWaiter(t *testing.T) {
+	t.Parallel()
+
+	blk := file.NewBlockId("test.db", 1)
+	lt := NewLockTable(5 * time.Second)
+
+	if err := lt.SLock(blk); err != nil {
+		t.Fatalf("SLock() failed: %v", err)
+	}
+	if err := lt.xLock(blk); err != nil {
+		t.Fatalf("xLock() failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- lt.SLock(blk) }()
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("SLock() returned %v while the block was exclusively locked", err)
+	default:
+	}
+
+	lt.Unlock(blk)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("waiting SLock() error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("waiting SLock() was not woken by Unlock")
+	}
+}
@@ -0,0 +1,260 @@
+// Package extsort sorts streams of byte records that may not fit in
+// memory. Records are buffered up to a memory budget, written as sorted
+// runs to temporary files through file.FileMgr, and merged back together
+// a bounded number of runs at a time.
+package extsort
+
+import (
+	"container/heap"
+	"errors"
+	"slices"
+
+	"simpledb-in-golang/file"
+)
+
+// Defaults for the memory budget in bytes and the merge fan-in.
+const (
+	DefaultMemoryBudget = 1 << 20
+	DefaultFanIn        = 16
+)
+
+// recordOverhead approximates the memory held by a buffered record
+// besides its bytes.
+const recordOverhead = 24
+
+// Sorter sorts the records added to it. It is not safe for concurrent use.
+type Sorter struct {
+	fm     *file.FileMgr
+	cmp    func(a, b []byte) int
+	budget int
+	fanIn  int
+
+	buf      [][]byte
+	bufBytes int
+	runs     []run
+	done     bool
+}
+
+// Option configures optional behavior of a Sorter.
+type Option func(*Sorter)
+
+// WithMemoryBudget sets the number of bytes of records buffered before a
+// sorted run is written out.
+func WithMemoryBudget(bytes int) Option {
+	return func(s *Sorter) {
+		s.budget = bytes
+	}
+}
+
+// WithFanIn sets the largest number of runs merged at once. Values below
+// two are raised to two.
+func WithFanIn(n int) Option {
+	return func(s *Sorter) {
+		s.fanIn = max(n, 2)
+	}
+}
+
+// New creates a sorter ordering records with cmp, which returns a
+// negative number, zero or a positive number when a sorts before, equal
+// to or after b. Equal records keep the order in which they were added.
+func New(fm *file.FileMgr, cmp func(a, b []byte) int, opts ...Option) *Sorter {
+	s := &Sorter{fm: fm, cmp: cmp, budget: DefaultMemoryBudget, fanIn: DefaultFanIn}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add adds a copy of rec to the records to sort.
+func (s *Sorter) Add(rec []byte) error {
+	if s.done {
+		return errors.New("extsort: Add after Sort")
+	}
+	s.buf = append(s.buf, slices.Clone(rec))
+	s.bufBytes += len(rec) + recordOverhead
+	if s.bufBytes >= s.budget {
+		return s.spill()
+	}
+	return nil
+}
+
+// Sort finishes the input and returns an iterator over the records in
+// order. The sorter's temporary files are removed once the iterator is
+// exhausted or closed; on error they are removed immediately.
+func (s *Sorter) Sort() (*Iterator, error) {
+	if s.done {
+		return nil, errors.New("extsort: Sort called twice")
+	}
+	s.done = true
+	if len(s.runs) == 0 {
+		// Everything fit in memory.
+		slices.SortStableFunc(s.buf, s.cmp)
+		it := &Iterator{mem: s.buf}
+		s.buf = nil
+		return it, nil
+	}
+	if len(s.buf) > 0 {
+		if err := s.spill(); err != nil {
+			_ = s.removeRuns(s.runs)
+			return nil, err
+		}
+	}
+	// Merge the leading runs until one final merge can take them all.
+	// Putting each merged run first keeps equal records in input order.
+	for len(s.runs) > s.fanIn {
+		r, err := s.merge(s.runs[:s.fanIn])
+		if err != nil {
+			_ = s.removeRuns(s.runs)
+			return nil, err
+		}
+		merged := s.runs[:s.fanIn]
+		s.runs = append([]run{r}, s.runs[s.fanIn:]...)
+		if err := s.removeRuns(merged); err != nil {
+			_ = s.removeRuns(s.runs)
+			return nil, err
+		}
+	}
+	m, err := s.newMerger(s.runs)
+	if err != nil {
+		_ = s.removeRuns(s.runs)
+		return nil, err
+	}
+	it := &Iterator{s: s, m: m, runs: s.runs}
+	s.runs = nil
+	return it, nil
+}
+
+// spill sorts the buffered records and writes them as a new run.
+func (s *Sorter) spill() error {
+	slices.SortStableFunc(s.buf, s.cmp)
+	w := newRunWriter(s.fm)
+	for _, rec := range s.buf {
+		if err := w.write(rec); err != nil {
+			_ = s.removeRuns([]run{w.r})
+			return err
+		}
+	}
+	r, err := w.close()
+	if err != nil {
+		_ = s.removeRuns([]run{w.r})
+		return err
+	}
+	s.runs = append(s.runs, r)
+	s.buf, s.bufBytes = nil, 0
+	return nil
+}
+
+// merge merges runs into a single new run.
+func (s *Sorter) merge(runs []run) (run, error) {
+	m, err := s.newMerger(runs)
+	if err != nil {
+		return run{}, err
+	}
+	w := newRunWriter(s.fm)
+	for {
+		rec, err := m.next()
+		if err == nil && rec == nil {
+			break
+		}
+		if err == nil {
+			err = w.write(rec)
+		}
+		if err != nil {
+			_ = s.removeRuns([]run{w.r})
+			return run{}, err
+		}
+	}
+	r, err := w.close()
+	if err != nil {
+		_ = s.removeRuns([]run{w.r})
+	}
+	return r, err
+}
+
+// removeRuns deletes the files of runs. Callers cleaning up after another
+// error ignore the result, since any leftover file is also purged by the
+// next NewFileMgr.
+func (s *Sorter) removeRuns(runs []run) error {
+	var errs []error
+	for _, r := range runs {
+		errs = append(errs, s.fm.Remove(r.filename))
+	}
+	return errors.Join(errs...)
+}
+
+// merger produces the records of several runs in order.
+type merger struct {
+	cmp     func(a, b []byte) int
+	readers []*runReader
+	heads   mergeHeap
+}
+
+// newMerger returns a merger reading the first record of every run.
+func (s *Sorter) newMerger(runs []run) (*merger, error) {
+	m := &merger{cmp: s.cmp}
+	m.heads.cmp = s.cmp
+	for i, r := range runs {
+		rd := newRunReader(s.fm, r)
+		m.readers = append(m.readers, rd)
+		rec, err := rd.next()
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil {
+			m.heads.items = append(m.heads.items, mergeItem{rec: rec, run: i})
+		}
+	}
+	heap.Init(&m.heads)
+	return m, nil
+}
+
+// next returns the smallest remaining record, or nil when all runs are exhausted.
+func (m *merger) next() ([]byte, error) {
+	if len(m.heads.items) == 0 {
+		return nil, nil
+	}
+	top := m.heads.items[0]
+	rec, err := m.readers[top.run].next()
+	if err != nil {
+		return nil, err
+	}
+	if rec != nil {
+		m.heads.items[0].rec = rec
+		heap.Fix(&m.heads, 0)
+	} else {
+		heap.Pop(&m.heads)
+	}
+	return top.rec, nil
+}
+
+// mergeItem is the current record of one run.
+type mergeItem struct {
+	rec []byte
+	run int
+}
+
+// mergeHeap orders run heads by record, then by run so that equal
+// records come out in input order.
+type mergeHeap struct {
+	cmp   func(a, b []byte) int
+	items []mergeItem
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	if c := h.cmp(h.items[i].rec, h.items[j].rec); c != 0 {
+		return c < 0
+	}
+	return h.items[i].run < h.items[j].run
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x any) { h.items = append(h.items, x.(mergeItem)) }
+
+func (h *mergeHeap) Pop() any {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
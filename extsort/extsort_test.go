@@ -0,0 +1,156 @@
+package extsort
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+// tempFiles returns the names of the sort files in dir.
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "tempsort") {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// record encodes a sort key followed by the position it was added at.
+func record(key, seq int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(key))
+	binary.BigEndian.PutUint32(b[4:], uint32(seq))
+	return b
+}
+
+// byKey compares records by their sort key only.
+func byKey(a, b []byte) int { return bytes.Compare(a[:4], b[:4]) }
+
+func TestSorter_Sort(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		n        int
+		opts     []Option
+		wantRuns bool
+	}{
+		{"empty", 0, nil, false},
+		{"in memory", 500, nil, false},
+		{"single merge", 2000, []Option{WithMemoryBudget(4000), WithFanIn(100)}, true},
+		{"multi-pass merge", 2000, []Option{WithMemoryBudget(1000), WithFanIn(3)}, true},
+		{"fan-in raised to two", 300, []Option{WithMemoryBudget(500), WithFanIn(0)}, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_extsort_sort", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := file.NewFileMgr(testDir, 128)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			s := New(fm, byKey, tt.opts...)
+			// Few distinct keys, so stability is visible.
+			rng := rand.New(rand.NewSource(int64(i)))
+			for seq := range tt.n {
+				if err := s.Add(record(rng.Intn(50), seq)); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+			}
+			if got := len(tempFiles(t, testDir)) > 0; got != tt.wantRuns {
+				t.Errorf("runs on disk before Sort() = %v, want %v", got, tt.wantRuns)
+			}
+			it, err := s.Sort()
+			if err != nil {
+				t.Fatalf("Sort() error = %v", err)
+			}
+			var prev []byte
+			count := 0
+			for {
+				ok, err := it.Next()
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if !ok {
+					break
+				}
+				rec := it.Record()
+				if prev != nil && bytes.Compare(prev, rec) > 0 {
+					t.Fatalf("record %d out of order (or unstable): %x after %x", count, rec, prev)
+				}
+				prev = rec
+				count++
+			}
+			if count != tt.n {
+				t.Errorf("Sort() returned %d records, want %d", count, tt.n)
+			}
+			if left := tempFiles(t, testDir); len(left) > 0 {
+				t.Errorf("temporary files left after the sort finished: %v", left)
+			}
+			if err := s.Add(record(0, 0)); err == nil {
+				t.Errorf("Add() after Sort() error = nil, want error")
+			}
+			if _, err := s.Sort(); err == nil {
+				t.Errorf("Sort() twice error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestIterator_CloseEarly(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_extsort_close")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 128)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	s := New(fm, bytes.Compare, WithMemoryBudget(200), WithFanIn(2))
+	for i := range 100 {
+		if err := s.Add(record(100-i, i)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	it, err := s.Sort()
+	if err != nil {
+		t.Fatalf("Sort() error = %v", err)
+	}
+	if ok, err := it.Next(); !ok || err != nil {
+		t.Fatalf("Next() = %v, %v, want true, nil", ok, err)
+	}
+	if got := binary.BigEndian.Uint32(it.Record()); got != 1 {
+		t.Errorf("first key = %d, want 1", got)
+	}
+	if len(tempFiles(t, testDir)) == 0 {
+		t.Fatalf("no runs on disk during the merge")
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if left := tempFiles(t, testDir); len(left) > 0 {
+		t.Errorf("temporary files left after Close(): %v", left)
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+	if ok, _ := it.Next(); ok {
+		t.Errorf("Next() after Close() = true, want false")
+	}
+}
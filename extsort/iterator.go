@@ -0,0 +1,52 @@
+package extsort
+
+// Iterator returns sorted records one at a time.
+type Iterator struct {
+	// mem holds the records of a sort that never left memory.
+	mem [][]byte
+	pos int
+
+	// s, m and runs drive the final merge of an external sort.
+	s    *Sorter
+	m    *merger
+	runs []run
+
+	rec []byte
+}
+
+// Next advances to the next record and reports whether there is one.
+// Reaching the end removes the sort's temporary files.
+func (it *Iterator) Next() (bool, error) {
+	if it.m == nil {
+		if it.pos >= len(it.mem) {
+			it.rec = nil
+			return false, nil
+		}
+		it.rec = it.mem[it.pos]
+		it.pos++
+		return true, nil
+	}
+	rec, err := it.m.next()
+	if err != nil {
+		_ = it.Close()
+		return false, err
+	}
+	if rec == nil {
+		return false, it.Close()
+	}
+	it.rec = rec
+	return true, nil
+}
+
+// Record returns the current record.
+func (it *Iterator) Record() []byte { return it.rec }
+
+// Close removes the sort's temporary files. It is safe to call more than once.
+func (it *Iterator) Close() error {
+	it.mem = nil
+	if it.m == nil {
+		return nil
+	}
+	it.m = nil
+	return it.s.removeRuns(it.runs)
+}
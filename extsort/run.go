@@ -0,0 +1,138 @@
+package extsort
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"simpledb-in-golang/file"
+)
+
+// tempSeq numbers the run files of every sorter in the process.
+var tempSeq atomic.Int64
+
+// newTempName returns an unused temporary file name. The "temp" prefix
+// makes NewFileMgr delete files left behind by a crash.
+func newTempName() string {
+	return fmt.Sprintf("tempsort%d", tempSeq.Add(1))
+}
+
+// run is a sorted sequence of records stored in a temporary file as a
+// byte stream of length-prefixed records, packed across block boundaries.
+type run struct {
+	filename string
+	count    int
+}
+
+// runWriter appends records to a run, one block at a time.
+type runWriter struct {
+	fm     *file.FileMgr
+	r      run
+	p      *file.Page
+	blknum int
+	pos    int
+}
+
+func newRunWriter(fm *file.FileMgr) *runWriter {
+	return &runWriter{fm: fm, r: run{filename: newTempName()}, p: file.NewPage(fm.BlockSize())}
+}
+
+// write appends a record to the run.
+func (w *runWriter) write(rec []byte) error {
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(len(rec)))
+	if err := w.writeBytes(hdr[:]); err != nil {
+		return err
+	}
+	w.r.count++
+	return w.writeBytes(rec)
+}
+
+// writeBytes copies b into the current block, flushing each block that fills up.
+func (w *runWriter) writeBytes(b []byte) error {
+	buf := w.p.Buffer()
+	for len(b) > 0 {
+		n := copy(buf[w.pos:], b)
+		w.pos += n
+		b = b[n:]
+		if w.pos == len(buf) {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flush writes the current block to the end of the run file. Writing the
+// block past the end extends the file, so no separate Append is needed;
+// being temporary, the file is never synced.
+func (w *runWriter) flush() error {
+	if err := w.fm.Write(file.NewBlockId(w.r.filename, w.blknum), w.p); err != nil {
+		return err
+	}
+	w.blknum++
+	clear(w.p.Buffer())
+	w.pos = 0
+	return nil
+}
+
+// close writes any partial last block and returns the finished run.
+func (w *runWriter) close() (run, error) {
+	if w.pos > 0 {
+		if err := w.flush(); err != nil {
+			return run{}, err
+		}
+	}
+	return w.r, nil
+}
+
+// runReader reads the records of a run back, one block at a time.
+type runReader struct {
+	fm     *file.FileMgr
+	r      run
+	p      *file.Page
+	blknum int
+	pos    int
+	read   int
+}
+
+func newRunReader(fm *file.FileMgr, r run) *runReader {
+	bs := fm.BlockSize()
+	return &runReader{fm: fm, r: r, p: file.NewPage(bs), blknum: -1, pos: bs}
+}
+
+// next returns the next record of the run, or nil at its end.
+func (rd *runReader) next() ([]byte, error) {
+	if rd.read == rd.r.count {
+		return nil, nil
+	}
+	var hdr [4]byte
+	if err := rd.readBytes(hdr[:]); err != nil {
+		return nil, err
+	}
+	rec := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+	if err := rd.readBytes(rec); err != nil {
+		return nil, err
+	}
+	rd.read++
+	return rec, nil
+}
+
+// readBytes fills b from the run, reading the following blocks as needed.
+func (rd *runReader) readBytes(b []byte) error {
+	buf := rd.p.Buffer()
+	for len(b) > 0 {
+		if rd.pos == len(buf) {
+			rd.blknum++
+			if err := rd.fm.Read(file.NewBlockId(rd.r.filename, rd.blknum), rd.p); err != nil {
+				return err
+			}
+			rd.pos = 0
+		}
+		n := copy(b, buf[rd.pos:])
+		rd.pos += n
+		b = b[n:]
+	}
+	return nil
+}
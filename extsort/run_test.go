@@ -0,0 +1,94 @@
+package extsort
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/file/filetest"
+)
+
+func TestRun_WriteRead(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_extsort_run")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 64)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	tests := []struct {
+		name    string
+		records [][]byte
+		blocks  int
+	}{
+		{"empty run", nil, 0},
+		{"one small record", [][]byte{[]byte("abc")}, 1},
+		{"empty record", [][]byte{{}}, 1},
+		{"exactly one block", [][]byte{make([]byte, 60)}, 1},
+		{"record spanning blocks", [][]byte{[]byte("x"), bytes.Repeat([]byte("y"), 200), []byte("z")}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newRunWriter(fm)
+			for _, rec := range tt.records {
+				if err := w.write(rec); err != nil {
+					t.Fatalf("write() error = %v", err)
+				}
+			}
+			r, err := w.close()
+			if err != nil {
+				t.Fatalf("close() error = %v", err)
+			}
+			defer fm.Remove(r.filename)
+			if n, _ := fm.Length(r.filename); n != tt.blocks {
+				t.Errorf("run has %d blocks, want %d", n, tt.blocks)
+			}
+
+			rd := newRunReader(fm, r)
+			for i, want := range tt.records {
+				got, err := rd.next()
+				if err != nil {
+					t.Fatalf("next() error = %v", err)
+				}
+				if !bytes.Equal(got, want) || got == nil {
+					t.Errorf("record %d = %q, want %q", i, got, want)
+				}
+			}
+			if got, err := rd.next(); got != nil || err != nil {
+				t.Errorf("next() at end = %q, %v, want nil, nil", got, err)
+			}
+		})
+	}
+}
+
+func TestRun_WriteWithoutSync(t *testing.T) {
+	t.Parallel()
+
+	s := filetest.NewFaultStorage(1)
+	fm, err := file.NewFileMgr("", 64, file.WithStorage(s))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	// Run files are temporary, so writing one must never sync.
+	s.Inject(filetest.Fault{Op: filetest.OpSync, N: 1, Repeat: true, Err: syscall.EIO})
+	w := newRunWriter(fm)
+	for range 10 {
+		if err := w.write(bytes.Repeat([]byte("r"), 50)); err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+	}
+	r, err := w.close()
+	if err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+	if n, _ := fm.Length(r.filename); n != 9 {
+		t.Errorf("run has %d blocks, want 9", n)
+	}
+	s.ClearFaults()
+}
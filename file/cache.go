@@ -0,0 +1,133 @@
+package file
+
+import "sync"
+
+// EvictionPolicy decides which block leaves the block cache when it is full.
+// Implementations are called with the cache's lock held and need no
+// synchronization of their own.
+type EvictionPolicy interface {
+	// Insert records that blk has been added to the cache.
+	Insert(blk BlockId)
+	// Access records a cache hit on blk.
+	Access(blk BlockId)
+	// Remove forgets blk, which has been dropped from the cache.
+	Remove(blk BlockId)
+	// Victim chooses a block to evict and forgets it.
+	// It returns false if the policy tracks no blocks.
+	Victim() (BlockId, bool)
+}
+
+// CacheStats holds the block cache hit and miss counts for a file.
+type CacheStats struct {
+	Hits   int
+	Misses int
+}
+
+// blockCache is a read-through cache of block images keyed by BlockId.
+type blockCache struct {
+	mu       sync.Mutex
+	capacity int
+	policy   EvictionPolicy
+	blocks   map[BlockId][]byte
+	counts   map[string]*CacheStats
+}
+
+// newBlockCache creates a cache holding up to capacity blocks.
+func newBlockCache(capacity int, policy EvictionPolicy) *blockCache {
+	return &blockCache{
+		capacity: capacity,
+		policy:   policy,
+		blocks:   make(map[BlockId][]byte),
+		counts:   make(map[string]*CacheStats),
+	}
+}
+
+// get copies the cached image of blk into buf and reports whether it was cached.
+func (c *blockCache) get(blk BlockId, buf []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.fileStats(blk.FileName())
+	b, ok := c.blocks[blk]
+	if !ok {
+		st.Misses++
+		return false
+	}
+	st.Hits++
+	copy(buf, b)
+	c.policy.Access(blk)
+	return true
+}
+
+// put adds a copy of buf as the image of blk, evicting a block if the cache is full.
+func (c *blockCache) put(blk BlockId, buf []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b, ok := c.blocks[blk]; ok {
+		copy(b, buf)
+		return
+	}
+	if c.capacity <= 0 {
+		return
+	}
+	for len(c.blocks) >= c.capacity {
+		victim, ok := c.policy.Victim()
+		if !ok {
+			return
+		}
+		delete(c.blocks, victim)
+	}
+	c.blocks[blk] = append([]byte(nil), buf...)
+	c.policy.Insert(blk)
+}
+
+// update refreshes the image of blk if it is cached.
+func (c *blockCache) update(blk BlockId, buf []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b, ok := c.blocks[blk]; ok {
+		copy(b, buf)
+	}
+}
+
+// remove drops blk from the cache.
+func (c *blockCache) remove(blk BlockId) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.blocks[blk]; ok {
+		delete(c.blocks, blk)
+		c.policy.Remove(blk)
+	}
+}
+
+// removeFile drops every block of filename from the cache.
+func (c *blockCache) removeFile(filename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for blk := range c.blocks {
+		if blk.FileName() == filename {
+			delete(c.blocks, blk)
+			c.policy.Remove(blk)
+		}
+	}
+}
+
+// stats returns a snapshot of the per-file hit and miss counts.
+func (c *blockCache) stats() map[string]CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]CacheStats, len(c.counts))
+	for name, st := range c.counts {
+		out[name] = *st
+	}
+	return out
+}
+
+// fileStats returns the counters for filename, creating them if necessary.
+func (c *blockCache) fileStats(filename string) *CacheStats {
+	st, ok := c.counts[filename]
+	if !ok {
+		st = &CacheStats{}
+		c.counts[filename] = st
+	}
+	return st
+}
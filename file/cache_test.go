@@ -0,0 +1,165 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMgr_BlockCache(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			policy func(capacity int) EvictionPolicy
+		}
+	)
+
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "lru",
+			args: args{policy: func(int) EvictionPolicy { return NewLRUPolicy() }},
+		},
+		{
+			name: "clock",
+			args: args{policy: func(int) EvictionPolicy { return NewClockPolicy() }},
+		},
+		{
+			name: "lru-k",
+			args: args{policy: func(int) EvictionPolicy { return NewLRUKPolicy(2) }},
+		},
+		{
+			name: "2q",
+			args: args{policy: func(c int) EvictionPolicy { return NewTwoQueuePolicy(c) }},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_cache_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			const (
+				blocksize = 512
+				capacity  = 2
+			)
+			fm, err := NewFileMgr(testDir, blocksize, WithBlockCache(capacity, tt.args.policy(capacity)))
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+
+			for i := range 4 {
+				page := NewPage(blocksize)
+				page.SetInt(0, i)
+				if err := fm.Write(NewBlockId("a.db", i), page); err != nil {
+					t.Fatalf("Write() failed: %v", err)
+				}
+			}
+
+			p := NewPage(blocksize)
+			read := func(filename string, n int) int {
+				t.Helper()
+				if err := fm.Read(NewBlockId(filename, n), p); err != nil {
+					t.Fatalf("Read() failed: %v", err)
+				}
+				v, _ := p.GetInt(0)
+				return v
+			}
+
+			// Two misses, then hits for the cached blocks.
+			read("a.db", 0)
+			read("a.db", 1)
+			for range 3 {
+				if got := read("a.db", 0); got != 0 {
+					t.Errorf("cached Read() = %v, want 0", got)
+				}
+			}
+
+			// Writes keep cached images current.
+			p2 := NewPage(blocksize)
+			p2.SetInt(0, 99)
+			if err := fm.Write(NewBlockId("a.db", 0), p2); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+			if got := read("a.db", 0); got != 99 {
+				t.Errorf("Read() after Write() = %v, want 99", got)
+			}
+
+			// Reading more blocks than the capacity forces evictions but stays correct.
+			for i := 1; i < 4; i++ {
+				if got := read("a.db", i); got != i {
+					t.Errorf("Read(%d) = %v, want %v", i, got, i)
+				}
+			}
+			if n := len(fm.cache.blocks); n > capacity {
+				t.Errorf("cached blocks = %v, want at most %v", n, capacity)
+			}
+
+			stats := fm.CacheStats()["a.db"]
+			if stats.Hits < 4 {
+				t.Errorf("Hits = %v, want at least 4", stats.Hits)
+			}
+			if stats.Misses < 4 {
+				t.Errorf("Misses = %v, want at least 4", stats.Misses)
+			}
+			if stats.Hits+stats.Misses != 9 {
+				t.Errorf("Hits+Misses = %v, want 9", stats.Hits+stats.Misses)
+			}
+		})
+	}
+}
+
+func TestFileMgr_CacheStats_Disabled(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_cache_disabled")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 512)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	if stats := fm.CacheStats(); stats != nil {
+		t.Errorf("CacheStats() = %v, want nil", stats)
+	}
+}
+
+func TestFileMgr_BlockCache_PerFileStats(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_cache_perfile")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 512, WithBlockCache(8, NewLRUPolicy()))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	for _, name := range []string{"x.db", "y.db"} {
+		if _, err := fm.Append(name); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+
+	p := NewPage(512)
+	for range 3 {
+		if err := fm.Read(NewBlockId("x.db", 0), p); err != nil {
+			t.Fatalf("Read() failed: %v", err)
+		}
+	}
+	if err := fm.Read(NewBlockId("y.db", 0), p); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+
+	stats := fm.CacheStats()
+	if got, want := stats["x.db"], (CacheStats{Hits: 2, Misses: 1}); got != want {
+		t.Errorf("CacheStats()[x.db] = %+v, want %+v", got, want)
+	}
+	if got, want := stats["y.db"], (CacheStats{Hits: 0, Misses: 1}); got != want {
+		t.Errorf("CacheStats()[y.db] = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// trailerSize is the size of the trailer WithChecksums reserves at the end
+// of each block: the block number, a hash of the file name and a CRC32C of
+// everything before it.
+const trailerSize = 12
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrChecksumMismatch is matched, through errors.Is, by the
+// *ChecksumMismatchError that Read returns for a corrupt block.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumMismatchError reports a block whose contents do not match its
+// checksum, or whose trailer names a different block.
+type ChecksumMismatchError struct {
+	Blk BlockId
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("Read: %s: %v", e.Blk, ErrChecksumMismatch)
+}
+
+// Is makes errors.Is(err, ErrChecksumMismatch) true.
+func (e *ChecksumMismatchError) Is(target error) bool { return target == ErrChecksumMismatch }
+
+// WithChecksums reserves a trailer in each block holding a CRC32C and the
+// block's identity, written on every write and verified on every read.
+// BlockSize then returns the usable size, the configured block size less
+// the trailer, so pages never reach into it.
+func WithChecksums() Option {
+	return func(fm *FileMgr) {
+		fm.checksums = true
+	}
+}
+
+// seal returns the on-disk image of a block holding data: data followed
+// by the checksum trailer, all encrypted if so configured. Without either
+// option the image is data itself.
+func (fm *FileMgr) seal(blk BlockId, data []byte) ([]byte, error) {
+	if fm.diskBlockSize == fm.blocksize {
+		return data, nil
+	}
+	img := fm.newImage()
+	copy(img, data)
+	if fm.checksums {
+		end := fm.blocksize + trailerSize
+		t := img[fm.blocksize:end]
+		binary.BigEndian.PutUint32(t, uint32(blk.Number()))
+		binary.BigEndian.PutUint32(t[4:], crc32.Checksum([]byte(blk.FileName()), castagnoli))
+		binary.BigEndian.PutUint32(t[8:], crc32.Checksum(img[:end-4], castagnoli))
+	}
+	if fm.enc != nil {
+		if err := fm.encrypt(blk, img); err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// unseal turns the on-disk image of blk back into its data, which then
+// fills the first BlockSize bytes of img, decrypting it and verifying its
+// trailer as configured.
+func (fm *FileMgr) unseal(blk BlockId, img []byte) error {
+	if fm.enc != nil {
+		if err := fm.decrypt(blk, img); err != nil {
+			return err
+		}
+	}
+	if !fm.checksums {
+		return nil
+	}
+	end := fm.blocksize + trailerSize
+	t := img[fm.blocksize:end]
+	if binary.BigEndian.Uint32(t[8:]) != crc32.Checksum(img[:end-4], castagnoli) ||
+		binary.BigEndian.Uint32(t) != uint32(blk.Number()) ||
+		binary.BigEndian.Uint32(t[4:]) != crc32.Checksum([]byte(blk.FileName()), castagnoli) {
+		return &ChecksumMismatchError{Blk: blk}
+	}
+	return nil
+}
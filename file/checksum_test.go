@@ -0,0 +1,214 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMgr_Checksums(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_checksums")
+	defer os.RemoveAll(testDir)
+
+	const blocksize = 128
+	if _, err := NewFileMgr(filepath.Join(testDir, "tiny"), trailerSize, WithChecksums()); err == nil {
+		t.Errorf("NewFileMgr() with no usable bytes error = nil, want error")
+	}
+	fm, err := NewFileMgr(testDir, blocksize, WithChecksums())
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	if got := fm.BlockSize(); got != blocksize-trailerSize {
+		t.Fatalf("BlockSize() = %d, want %d", got, blocksize-trailerSize)
+	}
+
+	// The page covers only the usable area.
+	p := NewPage(fm.BlockSize())
+	if err := p.SetInt(fm.BlockSize()-2, 1); err == nil {
+		t.Errorf("SetInt() into the trailer error = nil, want error")
+	}
+	if err := p.SetString(0, "payload"); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+	if err := p.SetInt(fm.BlockSize()-4, 77); err != nil {
+		t.Fatalf("SetInt() at the last usable offset error = %v", err)
+	}
+
+	for i := range 3 {
+		if _, err := fm.Append("data.tbl"); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		if err := fm.Write(NewBlockId("data.tbl", i), p); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	batch := map[BlockId]*Page{NewBlockId("data.tbl", 3): p}
+	if err := fm.WriteBatch(batch); err != nil {
+		t.Fatalf("WriteBatch() error = %v", err)
+	}
+	if n, _ := fm.Length("data.tbl"); n != 4 {
+		t.Errorf("Length() = %d, want 4", n)
+	}
+	fi, err := os.Stat(filepath.Join(testDir, "data.tbl"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if fi.Size() != 4*blocksize {
+		t.Errorf("file size = %d, want %d", fi.Size(), 4*blocksize)
+	}
+	for i := range 4 {
+		got := NewPage(fm.BlockSize())
+		if err := fm.Read(NewBlockId("data.tbl", i), got); err != nil {
+			t.Fatalf("Read(block %d) error = %v", i, err)
+		}
+		if s, _ := got.GetString(0); s != "payload" {
+			t.Errorf("block %d holds %q, want %q", i, s, "payload")
+		}
+		if v, _ := got.GetInt(fm.BlockSize() - 4); v != 77 {
+			t.Errorf("block %d last int = %d, want 77", i, v)
+		}
+	}
+
+	// Freed and reallocated blocks carry valid trailers too.
+	if err := fm.Free(NewBlockId("data.tbl", 1)); err != nil {
+		t.Fatalf("Free() error = %v", err)
+	}
+	blk, err := fm.Allocate("data.tbl")
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if err := fm.Read(blk, p); err != nil {
+		t.Errorf("Read() of reallocated block error = %v", err)
+	}
+}
+
+func TestFileMgr_ChecksumMismatch(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 128
+	tests := []struct {
+		name    string
+		corrupt func(img [][]byte) // on-disk images of blocks 0 and 1
+	}{
+		{"flipped data bit", func(img [][]byte) { img[1][10] ^= 0x04 }},
+		{"flipped trailer bit", func(img [][]byte) { img[1][blocksize-1] ^= 0x80 }},
+		{"misdirected write", func(img [][]byte) { copy(img[1], img[0]) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_checksum_mismatch", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, blocksize, WithChecksums())
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			p := NewPage(fm.BlockSize())
+			if err := p.SetString(0, "same contents"); err != nil {
+				t.Fatalf("SetString() error = %v", err)
+			}
+			for i := range 2 {
+				if err := fm.Write(NewBlockId("data.tbl", i), p); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if err := fm.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			path := filepath.Join(testDir, "data.tbl")
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			tt.corrupt([][]byte{raw[:blocksize], raw[blocksize:]})
+			if err := os.WriteFile(path, raw, 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			fm, err = NewFileMgr(testDir, blocksize, WithChecksums())
+			if err != nil {
+				t.Fatalf("NewFileMgr() reopen failed: %v", err)
+			}
+			defer fm.Close()
+			if err := fm.Read(NewBlockId("data.tbl", 0), p); err != nil {
+				t.Errorf("Read() of intact block error = %v", err)
+			}
+			err = fm.Read(NewBlockId("data.tbl", 1), p)
+			if !errors.Is(err, ErrChecksumMismatch) {
+				t.Fatalf("Read() of corrupt block error = %v, want ErrChecksumMismatch", err)
+			}
+			var cm *ChecksumMismatchError
+			if !errors.As(err, &cm) || cm.Blk != NewBlockId("data.tbl", 1) {
+				t.Errorf("Read() error = %#v, want a ChecksumMismatchError for data.tbl block 1", err)
+			}
+		})
+	}
+}
+
+func TestFileMgr_TrailersUnallocatedReads(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		damaged error // what a block zeroed in place reads as
+	}{
+		{"checksums", []Option{WithChecksums()}, ErrChecksumMismatch},
+		{"encryption", []Option{WithEncryption(testKeys(1))}, ErrAuthenticationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_trailers_unallocated", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, 4096, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			defer fm.Close()
+			// Writing block 3 seals the blocks it skips.
+			writeInt(t, fm, NewBlockId("data.tbl", 3), 1)
+			writeInt(t, fm, NewBlockId("data.tbl", 4), 2)
+
+			p := NewPage(fm.BlockSize())
+			for _, n := range []int{1, 9} {
+				p.Buffer()[0] = 1
+				if err := fm.Read(NewBlockId("data.tbl", n), p); err != nil {
+					t.Errorf("Read(block %d) error = %v", n, err)
+				}
+				if !allZero(p.Buffer()) {
+					t.Errorf("block %d is not zero-filled", n)
+				}
+			}
+
+			// A punched block is a real hole, so it reads as zeros too.
+			if err := fm.PunchHole(NewBlockId("data.tbl", 3)); err == nil {
+				if err := fm.Read(NewBlockId("data.tbl", 3), p); err != nil || !allZero(p.Buffer()) {
+					t.Errorf("Read() of punched block error = %v, zero-filled = %v", err, allZero(p.Buffer()))
+				}
+			} else if !errors.Is(err, errors.ErrUnsupported) {
+				t.Fatalf("PunchHole() error = %v", err)
+			}
+
+			// A block zeroed by media damage is not mistaken for a hole.
+			f, err := os.OpenFile(filepath.Join(testDir, "data.tbl"), os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("OpenFile() error = %v", err)
+			}
+			if _, err := f.WriteAt(make([]byte, 4096), 4*4096); err != nil {
+				t.Fatalf("WriteAt() error = %v", err)
+			}
+			f.Close()
+			if err := fm.Read(NewBlockId("data.tbl", 4), p); !errors.Is(err, tt.damaged) {
+				t.Errorf("Read() of zeroed block error = %v, want %v", err, tt.damaged)
+			}
+		})
+	}
+}
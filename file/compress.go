@@ -0,0 +1,455 @@
+package file
+
+import (
+	"bytes"
+	"cmp"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// offsetMapSuffix names the offset map kept alongside each file when
+// WithCompression is set. Entry i locates block i in the file, which then
+// is a container of variable-size extents rather than an array of blocks.
+const offsetMapSuffix = ".zmap"
+
+// Offset map and container layout. A map entry holds the offset of the
+// block's extent in the container and the extent's capacity; a zero offset
+// marks an unallocated block. The container starts with a magic header, so
+// no extent lies at offset zero. An extent is a length word, whose top bit
+// is set when the image is stored uncompressed, followed by the image.
+const (
+	mapEntrySize   = 12
+	containerMagic = "SDBFLATE"
+	extentAlign    = 64
+	extentHeader   = 4
+	extentRawFlag  = 1 << 31
+)
+
+// errHole is returned by readImage for a block that has no extent.
+var errHole = errors.New("hole")
+
+// WithCompression compresses block images with DEFLATE before they reach
+// the disk. Each file becomes a container of variable-size extents located
+// through an offset map, so compressible blocks take a fraction of their
+// size; BlockSize and block numbers are unchanged. A write never touches
+// the block's current extent: the image goes to a free extent, which is
+// flushed before the map entry is switched to it, so a torn write cannot
+// damage the previous image. The old extent is reused once the map no
+// longer pointing at it is flushed. A database must always be opened with
+// the same setting.
+func WithCompression() Option {
+	return func(fm *FileMgr) {
+		fm.compress = true
+	}
+}
+
+var (
+	flateWriters = sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	}}
+	flateReaders = sync.Pool{New: func() any {
+		return flate.NewReader(nil)
+	}}
+)
+
+// extent locates the stored image of a block in its container.
+type extent struct {
+	off      int64
+	capacity int
+}
+
+// extentSpace tracks the unused extents of a container. It is built from
+// the offset map on the first write to the file. An extent given up by a
+// write is pending until the map is flushed, since until then a crash
+// could bring back the entry pointing at it.
+type extentSpace struct {
+	mu      sync.Mutex
+	loaded  bool
+	free    []extent // sorted by offset, never adjacent
+	pending []retired
+}
+
+// retired is a pending extent. The extent of a punched block is
+// deallocated when it becomes free; others are soon reused.
+type retired struct {
+	extent
+	punch bool
+}
+
+// extentSpace returns the space tracker of a container.
+func (fm *FileMgr) extentSpace(filename string) *extentSpace {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	sp, ok := fm.extents[filename]
+	if !ok {
+		sp = new(extentSpace)
+		fm.extents[filename] = sp
+	}
+	return sp
+}
+
+// load finds the free extents of the container f from its offset map mf,
+// if not done yet: the gaps between the extents the map points at.
+// The caller must hold the file's lock exclusively.
+func (sp *extentSpace) load(f, mf StorageFile) error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.loaded {
+		return nil
+	}
+	size, err := mf.Size()
+	if err != nil {
+		return err
+	}
+	b := make([]byte, size/mapEntrySize*mapEntrySize)
+	if _, err := mf.ReadAt(b, 0); err != nil && err != io.EOF {
+		return err
+	}
+	var used []extent
+	for ; len(b) > 0; b = b[mapEntrySize:] {
+		if e := decodeMapEntry(b); e.off != 0 {
+			used = append(used, e)
+		}
+	}
+	slices.SortFunc(used, func(a, b extent) int { return cmp.Compare(a.off, b.off) })
+	end, err := f.Size()
+	if err != nil {
+		return err
+	}
+	pos := roundUp(int64(len(containerMagic)), extentAlign)
+	for _, e := range append(used, extent{off: roundUp(end, extentAlign)}) {
+		if e.off > pos {
+			sp.free = append(sp.free, extent{off: pos, capacity: int(e.off - pos)})
+		}
+		pos = max(pos, e.off+int64(e.capacity))
+	}
+	sp.loaded = true
+	return nil
+}
+
+// take removes a free extent of the given capacity from the free list,
+// reporting false if none is large enough.
+func (sp *extentSpace) take(capacity int) (extent, bool) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for i, e := range sp.free {
+		if e.capacity < capacity {
+			continue
+		}
+		if e.capacity == capacity {
+			sp.free = slices.Delete(sp.free, i, i+1)
+		} else {
+			sp.free[i] = extent{off: e.off + int64(capacity), capacity: e.capacity - capacity}
+		}
+		return extent{off: e.off, capacity: capacity}, true
+	}
+	return extent{}, false
+}
+
+// retire records that no map entry points at e any more.
+func (sp *extentSpace) retire(e extent, punch bool) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.pending = append(sp.pending, retired{e, punch})
+}
+
+// takePending removes and returns the pending extents.
+func (sp *extentSpace) takePending() []retired {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	pending := sp.pending
+	sp.pending = nil
+	return pending
+}
+
+// release returns extents to the free list, merging neighbors, once the
+// map entries that pointed at them have been replaced on disk.
+func (sp *extentSpace) release(es ...extent) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for _, e := range es {
+		i, _ := slices.BinarySearchFunc(sp.free, e.off, func(f extent, off int64) int { return cmp.Compare(f.off, off) })
+		sp.free = slices.Insert(sp.free, i, e)
+		if i+1 < len(sp.free) && e.off+int64(e.capacity) == sp.free[i+1].off {
+			sp.free[i].capacity += sp.free[i+1].capacity
+			sp.free = slices.Delete(sp.free, i+1, i+2)
+		}
+		if i > 0 && sp.free[i-1].off+int64(sp.free[i-1].capacity) == e.off {
+			sp.free[i-1].capacity += sp.free[i].capacity
+			sp.free = slices.Delete(sp.free, i, i+1)
+		}
+	}
+}
+
+// blockCount returns the number of blocks of the open file f.
+// The caller must hold the file's lock.
+func (fm *FileMgr) blockCount(filename string, f StorageFile) (int, error) {
+	if fm.compress {
+		var n int
+		err := fm.withFile(filename+offsetMapSuffix, func(mf StorageFile) error {
+			size, err := mf.Size()
+			n = int(size / mapEntrySize)
+			return err
+		})
+		return n, err
+	}
+	size, err := f.Size()
+	return int(size / int64(fm.diskBlockSize)), err
+}
+
+// writeImage writes the on-disk image of blk to f.
+// The caller must hold the file's lock exclusively.
+func (fm *FileMgr) writeImage(f StorageFile, blk BlockId, img []byte) error {
+	if !fm.compress {
+		if fm.direct != nil && !isAligned(img) {
+			buf := alignedBuffer(len(img))
+			copy(buf, img)
+			img = buf
+		}
+		_, err := f.WriteAt(img, int64(blk.Number())*int64(fm.diskBlockSize))
+		return err
+	}
+	ext := encodeExtent(img)
+	sp := fm.extentSpace(blk.FileName())
+	return fm.withFile(blk.FileName()+offsetMapSuffix, func(mf StorageFile) error {
+		if err := sp.load(f, mf); err != nil {
+			return err
+		}
+		old, _, err := readMapEntry(mf, blk.Number())
+		if err != nil {
+			return err
+		}
+		e, err := fm.newExtent(f, sp, ext)
+		if err != nil {
+			return err
+		}
+		// If the entry is not written, e is leaked until the next open.
+		if err := writeMapEntry(mf, blk.Number(), e); err != nil {
+			return err
+		}
+		if old.off != 0 {
+			sp.retire(old, false)
+		}
+		return nil
+	})
+}
+
+// newExtent writes ext to a free extent of the container f, or to a new
+// one at its end. The extent is flushed before it is returned, so that
+// the map never points at data that could be lost in a crash.
+// The caller must hold the file's lock exclusively.
+func (fm *FileMgr) newExtent(f StorageFile, sp *extentSpace, ext []byte) (extent, error) {
+	capacity := int(roundUp(int64(len(ext)), extentAlign))
+	e, ok := sp.take(capacity)
+	if !ok {
+		end, err := f.Size()
+		if err != nil {
+			return extent{}, err
+		}
+		if end == 0 {
+			if _, err := f.WriteAt([]byte(containerMagic), 0); err != nil {
+				return extent{}, err
+			}
+		}
+		e = extent{off: roundUp(max(end, int64(len(containerMagic))), extentAlign), capacity: capacity}
+	}
+	padded := make([]byte, e.capacity)
+	copy(padded, ext)
+	if _, err := f.WriteAt(padded, e.off); err != nil {
+		sp.release(e)
+		return extent{}, err
+	}
+	if err := fm.flush(f); err != nil {
+		sp.release(e)
+		return extent{}, err
+	}
+	return e, nil
+}
+
+// readImage reads the on-disk image of blk from f into img, returning the
+// number of bytes read as ReadAt does. With compression, it returns io.EOF
+// for a block past the end of the offset map and errHole for a block
+// without an extent.
+// The caller must hold the file's lock, shared or exclusive.
+func (fm *FileMgr) readImage(f StorageFile, blk BlockId, img []byte) (int, error) {
+	if !fm.compress {
+		if fm.direct != nil && !isAligned(img) {
+			buf := alignedBuffer(len(img))
+			n, err := f.ReadAt(buf, int64(blk.Number())*int64(fm.diskBlockSize))
+			copy(img, buf[:n])
+			return n, err
+		}
+		return f.ReadAt(img, int64(blk.Number())*int64(fm.diskBlockSize))
+	}
+	var e extent
+	var ok bool
+	err := fm.withFile(blk.FileName()+offsetMapSuffix, func(mf StorageFile) error {
+		var err error
+		e, ok, err = readMapEntry(mf, blk.Number())
+		return err
+	})
+	switch {
+	case err != nil:
+		return 0, err
+	case !ok:
+		return 0, io.EOF
+	case e.off == 0:
+		return 0, errHole
+	}
+	ext := make([]byte, e.capacity)
+	if _, err := f.ReadAt(ext, e.off); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, fmt.Errorf("Read: %s: %w", blk, err)
+	}
+	if err := decodeExtent(ext, img); err != nil {
+		return 0, fmt.Errorf("Read: %s: %w", blk, err)
+	}
+	return len(img), nil
+}
+
+// dropExtent detaches blk from its extent, which is deallocated where the
+// platform allows it and becomes free once the map is flushed.
+// The caller must hold the file's lock exclusively.
+func (fm *FileMgr) dropExtent(f StorageFile, blk BlockId) error {
+	sp := fm.extentSpace(blk.FileName())
+	return fm.withFile(blk.FileName()+offsetMapSuffix, func(mf StorageFile) error {
+		if err := sp.load(f, mf); err != nil {
+			return err
+		}
+		e, ok, err := readMapEntry(mf, blk.Number())
+		if err != nil || !ok || e.off == 0 {
+			return err
+		}
+		if err := writeMapEntry(mf, blk.Number(), extent{}); err != nil {
+			return err
+		}
+		sp.retire(e, true)
+		return nil
+	})
+}
+
+// flushBlocks flushes a file and, with compression, its offset map. The
+// extents the flushed map no longer points at then become free.
+func (fm *FileMgr) flushBlocks(filename string, f StorageFile) error {
+	if err := fm.flush(f); err != nil {
+		return err
+	}
+	if !fm.compress {
+		return nil
+	}
+	sp := fm.extentSpace(filename)
+	pending := sp.takePending()
+	if err := fm.withFile(filename+offsetMapSuffix, fm.flush); err != nil {
+		for _, r := range pending {
+			sp.retire(r.extent, r.punch)
+		}
+		return err
+	}
+	fm.releaseRetired(f, sp, pending)
+	return nil
+}
+
+// releaseRetired frees the retired extents of f, punching the ones that
+// asked for it.
+func (fm *FileMgr) releaseRetired(f StorageFile, sp *extentSpace, pending []retired) {
+	for _, r := range pending {
+		if r.punch {
+			// Failing to deallocate leaves the extent allocated but free.
+			_ = fm.punchHole(f, r.off, int64(r.capacity))
+		}
+		sp.release(r.extent)
+	}
+}
+
+// readMapEntry returns the map entry of block num. It reports false if
+// the map ends before the entry.
+func readMapEntry(mf StorageFile, num int) (extent, bool, error) {
+	var b [mapEntrySize]byte
+	_, err := mf.ReadAt(b[:], int64(num)*mapEntrySize)
+	if err == io.EOF {
+		return extent{}, false, nil
+	}
+	if err != nil {
+		return extent{}, false, err
+	}
+	return decodeMapEntry(b[:]), true, nil
+}
+
+// decodeMapEntry parses the map entry at the start of b.
+func decodeMapEntry(b []byte) extent {
+	return extent{
+		off:      int64(binary.BigEndian.Uint64(b)),
+		capacity: int(binary.BigEndian.Uint32(b[8:])),
+	}
+}
+
+// writeMapEntry sets the map entry of block num. Entries of skipped
+// blocks read as zero, and so as unallocated.
+func writeMapEntry(mf StorageFile, num int, e extent) error {
+	var b [mapEntrySize]byte
+	binary.BigEndian.PutUint64(b[:], uint64(e.off))
+	binary.BigEndian.PutUint32(b[8:], uint32(e.capacity))
+	_, err := mf.WriteAt(b[:], int64(num)*mapEntrySize)
+	return err
+}
+
+// encodeExtent compresses img into an extent, storing it as is if it
+// does not compress.
+func encodeExtent(img []byte) []byte {
+	var b bytes.Buffer
+	b.Write(make([]byte, extentHeader))
+	w := flateWriters.Get().(*flate.Writer)
+	w.Reset(&b)
+	_, _ = w.Write(img) // writes to a bytes.Buffer do not fail
+	_ = w.Close()
+	flateWriters.Put(w)
+
+	ext := b.Bytes()
+	if len(ext)-extentHeader >= len(img) {
+		ext = make([]byte, extentHeader+len(img))
+		copy(ext[extentHeader:], img)
+		binary.BigEndian.PutUint32(ext, uint32(len(img))|extentRawFlag)
+		return ext
+	}
+	binary.BigEndian.PutUint32(ext, uint32(len(ext)-extentHeader))
+	return ext
+}
+
+// decodeExtent fills img with the image stored in ext.
+func decodeExtent(ext, img []byte) error {
+	h := binary.BigEndian.Uint32(ext)
+	n := int(h &^ extentRawFlag)
+	if extentHeader+n > len(ext) {
+		return errors.New("corrupt extent: bad length")
+	}
+	data := ext[extentHeader : extentHeader+n]
+	if h&extentRawFlag != 0 {
+		if n != len(img) {
+			return errors.New("corrupt extent: bad length")
+		}
+		copy(img, data)
+		return nil
+	}
+	r := flateReaders.Get().(io.ReadCloser)
+	defer flateReaders.Put(r)
+	if err := r.(flate.Resetter).Reset(bytes.NewReader(data), nil); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, img); err != nil {
+		return fmt.Errorf("corrupt extent: %w", err)
+	}
+	return nil
+}
+
+// roundUp rounds n up to a multiple of align.
+func roundUp(n, align int64) int64 {
+	return (n + align - 1) / align * align
+}
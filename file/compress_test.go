@@ -0,0 +1,319 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeExtent(t *testing.T) {
+	t.Parallel()
+
+	random := make([]byte, 512)
+	rand.New(rand.NewSource(1)).Read(random)
+	tests := []struct {
+		name string
+		img  []byte
+		raw  bool
+	}{
+		{"zeros", make([]byte, 512), false},
+		{"text", []byte(strings.Repeat("the quick brown fox ", 100)), false},
+		{"incompressible", random, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ext := encodeExtent(tt.img)
+			if raw := ext[0]&0x80 != 0; raw != tt.raw {
+				t.Errorf("encodeExtent() stored raw = %v, want %v", raw, tt.raw)
+			}
+			if !tt.raw && len(ext) >= len(tt.img) {
+				t.Errorf("encodeExtent() = %d bytes, want fewer than %d", len(ext), len(tt.img))
+			}
+			got := make([]byte, len(tt.img))
+			if err := decodeExtent(ext, got); err != nil {
+				t.Fatalf("decodeExtent() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.img) {
+				t.Errorf("decodeExtent() did not round-trip the image")
+			}
+			if err := decodeExtent(ext[:len(ext)/2], got); err == nil {
+				t.Errorf("decodeExtent() of a truncated extent error = nil")
+			}
+		})
+	}
+}
+
+func TestFileMgr_Compression(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 4096
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"checksums", []Option{WithChecksums()}},
+		{"double write", []Option{WithDoubleWrite(2), WithDurability(SyncOnDemand)}},
+		{"block cache", []Option{WithBlockCache(4, NewLRUPolicy())}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_compression", tt.name)
+			defer os.RemoveAll(testDir)
+
+			opts := append([]Option{WithCompression()}, tt.opts...)
+			fm, err := NewFileMgr(testDir, blocksize, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			if tt.opts == nil && fm.BlockSize() != blocksize {
+				t.Errorf("BlockSize() = %d, want %d", fm.BlockSize(), blocksize)
+			}
+
+			// Highly compressible text, then a block growing out of its
+			// extent, then one shrinking back into a smaller image.
+			rng := rand.New(rand.NewSource(2))
+			want := make(map[int]string)
+			const nblocks = 20
+			for i := range nblocks {
+				want[i] = strings.Repeat("archived record "+string(rune('a'+i))+" ", 50)
+			}
+			noise := make([]byte, fm.BlockSize()/3)
+			rng.Read(noise)
+			want[3] = string(noise)
+			want[4] = "short"
+			for _, pass := range []int{0, 1} {
+				for i := range nblocks {
+					s := want[i]
+					if pass == 0 && (i == 3 || i == 4) {
+						s = want[0] // rewritten with the final value on the second pass
+					}
+					p := NewPage(fm.BlockSize())
+					if err := p.SetString(0, s); err != nil {
+						t.Fatalf("SetString() error = %v", err)
+					}
+					if err := fm.Write(NewBlockId("data.tbl", i), p); err != nil {
+						t.Fatalf("Write() error = %v", err)
+					}
+				}
+			}
+			check := func(fm *FileMgr) {
+				t.Helper()
+				if n, err := fm.Length("data.tbl"); err != nil || n != nblocks {
+					t.Errorf("Length() = %d, %v, want %d", n, err, nblocks)
+				}
+				for i := range nblocks {
+					p := NewPage(fm.BlockSize())
+					if err := fm.Read(NewBlockId("data.tbl", i), p); err != nil {
+						t.Fatalf("Read(%d) error = %v", i, err)
+					}
+					if got, _ := p.GetString(0); got != want[i] {
+						t.Errorf("Read(%d) = %.20q..., want %.20q...", i, got, want[i])
+					}
+				}
+			}
+			check(fm)
+			if err := fm.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			fi, err := os.Stat(filepath.Join(testDir, "data.tbl"))
+			if err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+			if raw := int64(nblocks * blocksize); fi.Size() > raw/4 {
+				t.Errorf("container is %d bytes, want at most a quarter of %d", fi.Size(), raw)
+			}
+
+			fm, err = NewFileMgr(testDir, blocksize, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() reopen failed: %v", err)
+			}
+			defer fm.Close()
+			check(fm)
+		})
+	}
+}
+
+func TestFileMgr_CompressionBlockLifecycle(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_compression_lifecycle")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 1024, WithCompression(), WithStrictReads())
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+
+	blk, err := fm.Append("data.tbl")
+	if err != nil || blk.Number() != 0 {
+		t.Fatalf("Append() = %v, %v, want block 0", blk, err)
+	}
+	// Writing block 3 leaves blocks 1 and 2 unallocated.
+	if err := fm.Write(NewBlockId("data.tbl", 3), NewPage(1024)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if blk, err := fm.Append("data.tbl"); err != nil || blk.Number() != 4 {
+		t.Fatalf("Append() = %v, %v, want block 4", blk, err)
+	}
+	if err := fm.PunchHole(NewBlockId("data.tbl", 3)); err != nil {
+		t.Fatalf("PunchHole() error = %v", err)
+	}
+	for _, r := range []struct {
+		num       int
+		allocated bool
+	}{{0, true}, {1, false}, {3, false}, {4, true}, {5, false}} {
+		err := fm.Read(NewBlockId("data.tbl", r.num), NewPage(1024))
+		if r.allocated && err != nil {
+			t.Errorf("Read(%d) error = %v", r.num, err)
+		}
+		if !r.allocated && !errors.Is(err, ErrBlockNotAllocated) {
+			t.Errorf("Read(%d) error = %v, want ErrBlockNotAllocated", r.num, err)
+		}
+	}
+
+	// Freed and reallocated blocks, and atomic batches, go through the
+	// same containers.
+	if err := fm.Free(NewBlockId("data.tbl", 0)); err != nil {
+		t.Fatalf("Free() error = %v", err)
+	}
+	if blk, err := fm.Allocate("data.tbl"); err != nil || blk.Number() != 0 {
+		t.Fatalf("Allocate() = %v, %v, want block 0", blk, err)
+	}
+	p := NewPage(1024)
+	p.SetInt(0, 42)
+	if err := fm.WriteBatch(map[BlockId]*Page{NewBlockId("data.tbl", 1): p}); err != nil {
+		t.Fatalf("WriteBatch() error = %v", err)
+	}
+	if got := readInt(t, fm, NewBlockId("data.tbl", 1)); got != 42 {
+		t.Errorf("Read() after WriteBatch() = %d, want 42", got)
+	}
+
+	if err := fm.Remove("data.tbl"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	entries, err := os.ReadDir(testDir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "data.tbl") {
+			t.Errorf("%s left behind by Remove()", e.Name())
+		}
+	}
+}
+
+func TestExtentSpace(t *testing.T) {
+	t.Parallel()
+
+	sp := &extentSpace{loaded: true}
+	sp.release(extent{off: 64, capacity: 64}, extent{off: 256, capacity: 128})
+	sp.release(extent{off: 128, capacity: 128}) // joins both neighbors
+	if want := []extent{{off: 64, capacity: 320}}; !slices.Equal(sp.free, want) {
+		t.Fatalf("free after release() = %v, want %v", sp.free, want)
+	}
+
+	tests := []struct {
+		capacity int
+		want     extent
+		ok       bool
+	}{
+		{128, extent{off: 64, capacity: 128}, true},
+		{256, extent{}, false},
+		{192, extent{off: 192, capacity: 192}, true},
+		{64, extent{}, false},
+	}
+	for _, tt := range tests {
+		if got, ok := sp.take(tt.capacity); got != tt.want || ok != tt.ok {
+			t.Errorf("take(%d) = %v, %v, want %v, %v", tt.capacity, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFileMgr_CompressionReusesExtents(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 4096
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"every write", nil},
+		{"on demand", []Option{WithDurability(SyncOnDemand)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_compression_reuse", tt.name)
+			defer os.RemoveAll(testDir)
+
+			opts := append([]Option{WithCompression()}, tt.opts...)
+			noise := make([]byte, blocksize/2)
+			rand.New(rand.NewSource(3)).Read(noise)
+			// Blocks alternately grow out of their extents and shrink.
+			rewrite := func(fm *FileMgr, rounds int) {
+				t.Helper()
+				for r := range rounds {
+					for i := range 4 {
+						p := NewPage(blocksize)
+						if (r+i)%2 == 0 {
+							copy(p.Buffer(), noise)
+						}
+						p.SetInt(0, r)
+						if err := fm.Write(NewBlockId("data.tbl", i), p); err != nil {
+							t.Fatalf("Write() error = %v", err)
+						}
+					}
+					if err := fm.SyncAll(); err != nil {
+						t.Fatalf("SyncAll() error = %v", err)
+					}
+				}
+			}
+			containerSize := func() int64 {
+				t.Helper()
+				fi, err := os.Stat(filepath.Join(testDir, "data.tbl"))
+				if err != nil {
+					t.Fatalf("Stat() error = %v", err)
+				}
+				return fi.Size()
+			}
+
+			fm, err := NewFileMgr(testDir, blocksize, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			rewrite(fm, 4)
+			size := containerSize()
+			rewrite(fm, 50)
+			if got := containerSize(); got > size+blocksize {
+				t.Errorf("container grew from %d to %d bytes over 50 rewrites", size, got)
+			}
+			fm.Close()
+
+			// Reopening finds the free extents from the offset map.
+			fm, err = NewFileMgr(testDir, blocksize, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() reopen failed: %v", err)
+			}
+			defer fm.Close()
+			rewrite(fm, 50)
+			if got := containerSize(); got > size+blocksize {
+				t.Errorf("container grew from %d to %d bytes after reopening", size, got)
+			}
+			for i := range 4 {
+				if got := readInt(t, fm, NewBlockId("data.tbl", i)); got != 49 {
+					t.Errorf("block %d = %d, want 49", i, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package file_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"syscall"
+	"testing"
+	"time"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/file/filetest"
+)
+
+// writeValue writes v at the start and end of a block, so that a torn
+// block shows two different values. Half the block is random, so that
+// compressed images span several sectors and can tear too.
+func writeValue(fm *file.FileMgr, blk file.BlockId, v int) error {
+	p := file.NewPage(fm.BlockSize())
+	rand.New(rand.NewSource(int64(v))).Read(p.Buffer()[:fm.BlockSize()/2])
+	p.SetInt(0, v)
+	p.SetInt(fm.BlockSize()-4, v)
+	return fm.Write(blk, p)
+}
+
+// readValue returns the value of a block written by writeValue.
+func readValue(fm *file.FileMgr, blk file.BlockId) (int, error) {
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(blk, p); err != nil {
+		return 0, err
+	}
+	first, _ := p.GetInt(0)
+	last, _ := p.GetInt(fm.BlockSize() - 4)
+	if first != last {
+		return 0, fmt.Errorf("%s is torn: %d...%d", blk, first, last)
+	}
+	return first, nil
+}
+
+func TestFileMgr_CrashDurability(t *testing.T) {
+	t.Parallel()
+
+	reordered := filetest.PowerLoss{KeepProbability: 0.5, Reorder: true}
+	torn := filetest.PowerLoss{KeepProbability: 0.5, Reorder: true, Tear: true}
+	onDemand := file.WithDurability(file.SyncOnDemand)
+	tests := []struct {
+		name string
+		opts []file.Option
+		loss filetest.PowerLoss
+		// syncEvery is the number of writes between SyncAll calls, or 0
+		// if each write is durable when it returns.
+		syncEvery int
+	}{
+		{"plain", nil, reordered, 0},
+		{"checksums", []file.Option{file.WithChecksums()}, reordered, 0},
+		{"compression", []file.Option{file.WithCompression()}, reordered, 0},
+		{"compression with torn writes", []file.Option{file.WithCompression()}, torn, 0},
+		{"encryption", []file.Option{file.WithEncryption(file.StaticKeys{1: make([]byte, 16)})}, reordered, 0},
+		{"double write", []file.Option{file.WithChecksums(), file.WithDoubleWrite(2)}, torn, 0},
+		{"on demand", []file.Option{onDemand}, reordered, 3},
+		{"interval", []file.Option{file.WithDurability(file.SyncInterval(time.Hour))}, reordered, 3},
+		{"on demand with double write", []file.Option{onDemand, file.WithChecksums(), file.WithDoubleWrite(2)}, torn, 3},
+		{"on demand with compression", []file.Option{onDemand, file.WithCompression()}, torn, 3},
+	}
+	const nblocks = 4
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			blk := func(i int) file.BlockId { return file.NewBlockId("data.tbl", i%nblocks) }
+			// Every block is written twice. A write that is durable, because
+			// it returned or was followed by SyncAll, survives; a later one
+			// may or may not have landed.
+			err := filetest.ExploreCrashPoints(filetest.Scenario{
+				BlockSize: 2048,
+				Options:   tt.opts,
+				Setup: func(fm *file.FileMgr) error {
+					for i := range nblocks {
+						if err := writeValue(fm, blk(i), 0); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+				Workload: func(fm *file.FileMgr, p *filetest.Progress) error {
+					for i := range 2 * nblocks {
+						if err := writeValue(fm, blk(i), i+1); err != nil {
+							return err
+						}
+						if tt.syncEvery == 0 {
+							p.Mark()
+						} else if (i+1)%tt.syncEvery == 0 {
+							if err := fm.SyncAll(); err != nil {
+								return err
+							}
+							for range tt.syncEvery {
+								p.Mark()
+							}
+						}
+					}
+					return nil
+				},
+				Check: func(fm *file.FileMgr, p *filetest.Progress) error {
+					for b := range nblocks {
+						got, err := readValue(fm, blk(b))
+						if err != nil {
+							return err
+						}
+						// The last durable write of the block survives; those
+						// after it may have landed too.
+						want := []int{0}
+						for i := b; i < 2*nblocks; i += nblocks {
+							if i < p.Count() {
+								want[0] = i + 1
+							} else {
+								want = append(want, i+1)
+							}
+						}
+						if !contains(want, got) {
+							return fmt.Errorf("%s = %d after %d writes, want one of %v", blk(b), got, p.Count(), want)
+						}
+					}
+					return nil
+				},
+				Loss: tt.loss,
+				Seed: 1,
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestFileMgr_CrashBatchAtomicity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts []file.Option
+	}{
+		{"plain", nil},
+		{"on demand", []file.Option{file.WithDurability(file.SyncOnDemand)}},
+		{"compression", []file.Option{file.WithCompression()}},
+		{"encryption and double write", []file.Option{
+			file.WithEncryption(file.StaticKeys{1: make([]byte, 32)}),
+			file.WithDoubleWrite(4),
+		}},
+	}
+	blks := []file.BlockId{
+		file.NewBlockId("a.tbl", 0), file.NewBlockId("a.tbl", 2), file.NewBlockId("b.tbl", 1),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := filetest.ExploreCrashPoints(filetest.Scenario{
+				BlockSize: 1024,
+				Options:   tt.opts,
+				Setup: func(fm *file.FileMgr) error {
+					for _, blk := range blks {
+						if err := writeValue(fm, blk, 0); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+				Workload: func(fm *file.FileMgr, p *filetest.Progress) error {
+					for v := 1; v <= 3; v++ {
+						pages := make(map[file.BlockId]*file.Page)
+						for _, blk := range blks {
+							pg := file.NewPage(fm.BlockSize())
+							pg.SetInt(0, v)
+							pg.SetInt(fm.BlockSize()-4, v)
+							pages[blk] = pg
+						}
+						if err := fm.WriteBatch(pages); err != nil {
+							return err
+						}
+						p.Mark()
+					}
+					return nil
+				},
+				Check: func(fm *file.FileMgr, p *filetest.Progress) error {
+					first := -1
+					for _, blk := range blks {
+						got, err := readValue(fm, blk)
+						if err != nil {
+							return err
+						}
+						if first < 0 {
+							first = got
+						}
+						if got != first {
+							return fmt.Errorf("batch applied in part: %s = %d, %s = %d", blks[0], first, blk, got)
+						}
+					}
+					if first < p.Count() || first > p.Count()+1 {
+						return fmt.Errorf("blocks hold batch %d after %d committed batches", first, p.Count())
+					}
+					return nil
+				},
+				Loss: filetest.PowerLoss{KeepProbability: 0.5, Reorder: true},
+				Seed: 7,
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestFileMgr_WriteBatchAfterFailedApply(t *testing.T) {
+	t.Parallel()
+
+	s := filetest.NewFaultStorage(1)
+	fm, err := file.NewFileMgr("", 512, file.WithStorage(s))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	a, b := file.NewBlockId("a.db", 0), file.NewBlockId("b.db", 0)
+	for _, blk := range []file.BlockId{a, b} {
+		if err := writeValue(fm, blk, 0); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	batch := func(blk file.BlockId, v int) error {
+		p := file.NewPage(fm.BlockSize())
+		p.SetInt(0, v)
+		p.SetInt(fm.BlockSize()-4, v)
+		return fm.WriteBatch(map[file.BlockId]*file.Page{blk: p})
+	}
+
+	// The first batch commits, but applying it fails.
+	s.Inject(filetest.Fault{Op: filetest.OpWriteAt, Name: "a.db", N: 1, Err: syscall.EIO})
+	if err := batch(a, 1); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("WriteBatch() error = %v, want EIO", err)
+	}
+	s.ClearFaults()
+	// The second batch reuses the redo slot of the first, then fails
+	// before its own commit.
+	s.Inject(filetest.Fault{Op: filetest.OpRename, Name: "redotable.new", N: 1, Err: syscall.EIO})
+	if err := batch(b, 2); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("WriteBatch() error = %v, want EIO", err)
+	}
+
+	fm, err = filetest.Reopen(s, filetest.PowerLoss{}, 512)
+	if err != nil {
+		t.Fatalf("Reopen() failed: %v", err)
+	}
+	defer fm.Close()
+	for _, tt := range []struct {
+		blk  file.BlockId
+		want int
+	}{{a, 1}, {b, 0}} {
+		if got, err := readValue(fm, tt.blk); err != nil || got != tt.want {
+			t.Errorf("%s after crash = %d, %v, want %d", tt.blk, got, err, tt.want)
+		}
+	}
+}
+
+func TestFileMgr_WriteBatchCommitPoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		fault         filetest.Fault
+		wantCommitted bool
+	}{
+		{"redo write fails", filetest.Fault{Op: filetest.OpWriteAt, Name: "redo.dat", N: 1, Err: syscall.EIO}, false},
+		{"redo table rename fails", filetest.Fault{Op: filetest.OpRename, Name: "redotable.new", N: 1, Err: syscall.EIO}, false},
+		{"apply fails", filetest.Fault{Op: filetest.OpWriteAt, Name: "a.db", N: 1, Err: syscall.EIO}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := filetest.NewFaultStorage(1)
+			fm, err := file.NewFileMgr("", 512, file.WithStorage(s))
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			blk := file.NewBlockId("a.db", 0)
+			if err := writeValue(fm, blk, 1); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			p := file.NewPage(fm.BlockSize())
+			p.SetInt(0, 2)
+			p.SetInt(fm.BlockSize()-4, 2)
+			s.Inject(tt.fault)
+			err = fm.WriteBatch(map[file.BlockId]*file.Page{blk: p})
+			if !errors.Is(err, syscall.EIO) {
+				t.Fatalf("WriteBatch() error = %v, want EIO", err)
+			}
+			if got := errors.Is(err, file.ErrBatchNotCommitted); got == tt.wantCommitted {
+				t.Errorf("WriteBatch() error = %v, matches ErrBatchNotCommitted = %v, want %v", err, got, !tt.wantCommitted)
+			}
+
+			// The error tells whether the batch survives a crash.
+			fm, err = filetest.Reopen(s, filetest.PowerLoss{}, 512)
+			if err != nil {
+				t.Fatalf("Reopen() failed: %v", err)
+			}
+			defer fm.Close()
+			want := 1
+			if tt.wantCommitted {
+				want = 2
+			}
+			if got, err := readValue(fm, blk); err != nil || got != want {
+				t.Errorf("block after crash = %d, %v, want %d", got, err, want)
+			}
+		})
+	}
+}
+
+func TestFileMgr_InjectedFaults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		opts    []file.Option
+		fault   filetest.Fault
+		op      func(fm *file.FileMgr) error
+		wantErr error
+	}{
+		{
+			name:    "write EIO",
+			fault:   filetest.Fault{Op: filetest.OpWriteAt, Name: "data.tbl", N: 1, Err: syscall.EIO},
+			op:      func(fm *file.FileMgr) error { return writeValue(fm, file.NewBlockId("data.tbl", 1), 5) },
+			wantErr: syscall.EIO,
+		},
+		{
+			name:    "short write",
+			fault:   filetest.Fault{Op: filetest.OpWriteAt, Name: "data.tbl", N: 1, Short: true},
+			op:      func(fm *file.FileMgr) error { return writeValue(fm, file.NewBlockId("data.tbl", 1), 5) },
+			wantErr: io.ErrShortWrite,
+		},
+		{
+			name:  "disk full",
+			fault: filetest.Fault{Op: filetest.OpWriteAt, N: 1, Repeat: true, Err: syscall.ENOSPC},
+			op: func(fm *file.FileMgr) error {
+				_, err := fm.Append("data.tbl")
+				return err
+			},
+			wantErr: syscall.ENOSPC,
+		},
+		{
+			name:    "read EIO",
+			fault:   filetest.Fault{Op: filetest.OpReadAt, Name: "data.tbl", N: 1, Err: syscall.EIO},
+			op:      func(fm *file.FileMgr) error { _, err := readValue(fm, file.NewBlockId("data.tbl", 0)); return err },
+			wantErr: syscall.EIO,
+		},
+		{
+			name:    "sync EIO",
+			opts:    []file.Option{file.WithDurability(file.SyncOnDemand)},
+			fault:   filetest.Fault{Op: filetest.OpSync, N: 1, Err: syscall.EIO},
+			op:      func(fm *file.FileMgr) error { return fm.SyncAll() },
+			wantErr: syscall.EIO,
+		},
+		{
+			name:  "batch redo write EIO",
+			fault: filetest.Fault{Op: filetest.OpWriteAt, Name: "redo.dat", N: 1, Err: syscall.EIO},
+			op: func(fm *file.FileMgr) error {
+				p := file.NewPage(fm.BlockSize())
+				p.SetInt(0, 5)
+				p.SetInt(fm.BlockSize()-4, 5)
+				return fm.WriteBatch(map[file.BlockId]*file.Page{file.NewBlockId("data.tbl", 0): p})
+			},
+			wantErr: syscall.EIO,
+		},
+		{
+			name:    "double-write area EIO",
+			opts:    []file.Option{file.WithDoubleWrite(2)},
+			fault:   filetest.Fault{Op: filetest.OpWriteAt, Name: "doublewrite.dat", N: 1, Err: syscall.EIO},
+			op:      func(fm *file.FileMgr) error { return writeValue(fm, file.NewBlockId("data.tbl", 0), 5) },
+			wantErr: syscall.EIO,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := filetest.NewFaultStorage(1)
+			opts := append([]file.Option{file.WithStorage(s)}, tt.opts...)
+			fm, err := file.NewFileMgr("", 512, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			defer fm.Close()
+			if err := writeValue(fm, file.NewBlockId("data.tbl", 0), 1); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			s.Inject(tt.fault)
+			if err := tt.op(fm); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			s.ClearFaults()
+
+			// The FileMgr stays usable, and the block written before the
+			// fault is intact unless the failed operation targeted it.
+			if err := writeValue(fm, file.NewBlockId("data.tbl", 2), 3); err != nil {
+				t.Errorf("Write() after the fault error = %v", err)
+			}
+			if got, err := readValue(fm, file.NewBlockId("data.tbl", 2)); err != nil || got != 3 {
+				t.Errorf("Read() after the fault = %d, %v, want 3", got, err)
+			}
+			if got, err := readValue(fm, file.NewBlockId("data.tbl", 0)); err != nil || got != 1 && got != 5 {
+				t.Errorf("block 0 = %d, %v, want 1 or 5", got, err)
+			}
+		})
+	}
+}
+
+func TestFileMgr_SyncRetriesAfterFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		sync func(fm *file.FileMgr) error
+	}{
+		{"Sync", func(fm *file.FileMgr) error { return fm.Sync("data.tbl") }},
+		{"SyncAll", func(fm *file.FileMgr) error { return fm.SyncAll() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := filetest.NewFaultStorage(1)
+			opts := []file.Option{file.WithDurability(file.SyncOnDemand)}
+			fm, err := file.NewFileMgr("", 512, append(opts, file.WithStorage(s))...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			blk := file.NewBlockId("data.tbl", 0)
+			if err := writeValue(fm, blk, 7); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			s.Inject(filetest.Fault{Op: filetest.OpSync, Name: "data.tbl", N: 1, Err: syscall.EIO})
+			if err := tt.sync(fm); !errors.Is(err, syscall.EIO) {
+				t.Fatalf("%s() error = %v, want EIO", tt.name, err)
+			}
+			// The failed flush left the file dirty, so this one syncs it.
+			if err := tt.sync(fm); err != nil {
+				t.Fatalf("%s() retry error = %v", tt.name, err)
+			}
+
+			fm, err = filetest.Reopen(s, filetest.PowerLoss{}, 512, opts...)
+			if err != nil {
+				t.Fatalf("Reopen() failed: %v", err)
+			}
+			defer fm.Close()
+			if got, err := readValue(fm, blk); err != nil || got != 7 {
+				t.Errorf("block after crash = %d, %v, want 7", got, err)
+			}
+		})
+	}
+}
+
+func contains(vs []int, v int) bool {
+	for _, x := range vs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
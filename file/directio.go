@@ -0,0 +1,97 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// pageAlign is the memory alignment of aligned pages and of the buffers
+// direct I/O goes through: the memory page size, which is a multiple of
+// any device's logical sector size.
+const pageAlign = 4096
+
+// directProbeFile is the file a directory's SectorSize writes to. Its name
+// marks it as temporary, so that one left behind by a crash is removed on
+// startup.
+const directProbeFile = "temp_directio"
+
+// directStorage is implemented by a Storage that can open files for
+// direct I/O, bypassing the operating system's page cache.
+type directStorage interface {
+	// OpenDirect opens a file as Open does, for direct I/O. Every read
+	// and write of the file must then be a multiple of the sector size,
+	// at an offset that is one, from memory aligned for direct I/O.
+	OpenDirect(name string) (StorageFile, error)
+	// SectorSize returns the logical sector size of the storage.
+	SectorSize() (int, error)
+}
+
+// WithDirectIO opens database files for direct I/O, so that blocks move
+// between their pages and the device without a copy in the operating
+// system's page cache, which only duplicates the FileMgr's block cache.
+// The configured block size must be a multiple of the logical sector size.
+// Direct I/O needs pages aligned in memory: pages from NewAlignedPage are
+// read and written in place, others through an aligned copy. Files the
+// FileMgr keeps for itself, such as the redo and double-write files,
+// still go through the page cache. Direct I/O is only supported on Linux,
+// with the default storage, and not with WithCompression.
+func WithDirectIO() Option {
+	return func(fm *FileMgr) {
+		fm.directIO = true
+	}
+}
+
+// openDirect checks that the storage supports direct I/O with the
+// configured block size and keeps it for opening database files.
+func (fm *FileMgr) openDirect() error {
+	if fm.compress {
+		return errors.New("direct I/O cannot be combined with compression")
+	}
+	ds, ok := fm.storage.(directStorage)
+	if !ok {
+		return fmt.Errorf("direct I/O: storage %w", errors.ErrUnsupported)
+	}
+	sector, err := ds.SectorSize()
+	if err != nil {
+		return fmt.Errorf("direct I/O: %w", err)
+	}
+	if fm.diskBlockSize%sector != 0 {
+		return fmt.Errorf("block size %d is not a multiple of the sector size %d", fm.diskBlockSize, sector)
+	}
+	fm.direct = ds
+	return nil
+}
+
+// openFile opens a file of the storage, for direct I/O if it holds blocks
+// and WithDirectIO is set.
+func (fm *FileMgr) openFile(filename string) (StorageFile, error) {
+	if fm.direct != nil && !isReservedFile(filename) {
+		return fm.direct.OpenDirect(filename)
+	}
+	return fm.storage.Open(filename)
+}
+
+// newImage returns a zeroed buffer for the on-disk image of a block,
+// aligned if the FileMgr uses direct I/O.
+func (fm *FileMgr) newImage() []byte {
+	if fm.direct != nil {
+		return alignedBuffer(fm.diskBlockSize)
+	}
+	return make([]byte, fm.diskBlockSize)
+}
+
+// alignedBuffer returns a zeroed buffer of n bytes aligned for direct I/O.
+func alignedBuffer(n int) []byte {
+	b := make([]byte, n+pageAlign)
+	off := 0
+	if r := int(uintptr(unsafe.Pointer(unsafe.SliceData(b))) % pageAlign); r != 0 {
+		off = pageAlign - r
+	}
+	return b[off : off+n : off+n]
+}
+
+// isAligned reports whether b starts at an address aligned for direct I/O.
+func isAligned(b []byte) bool {
+	return uintptr(unsafe.Pointer(unsafe.SliceData(b)))%pageAlign == 0
+}
@@ -0,0 +1,43 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// OpenDirect opens a file with O_DIRECT.
+func (s *dirStorage) OpenDirect(name string) (StorageFile, error) {
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_RDWR|os.O_CREATE|syscall.O_DIRECT, 0o644)
+	if errors.Is(err, syscall.EINVAL) {
+		return nil, fmt.Errorf("open %s: O_DIRECT %w by the file system", name, errors.ErrUnsupported)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return osFile{f}, nil
+}
+
+// SectorSize finds the logical sector size of the directory's device as
+// the smallest write it accepts from a file opened with O_DIRECT.
+func (s *dirStorage) SectorSize() (int, error) {
+	f, err := s.OpenDirect(directProbeFile)
+	if err != nil {
+		return 0, err
+	}
+	defer s.Remove(directProbeFile)
+	defer f.Close()
+	buf := alignedBuffer(pageAlign)
+	for n := 512; n <= pageAlign; n *= 2 {
+		_, err := f.WriteAt(buf[:n], 0)
+		if err == nil {
+			return n, nil
+		}
+		if !errors.Is(err, syscall.EINVAL) {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("no write of up to %d bytes is accepted with O_DIRECT", pageAlign)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package file
+
+import "errors"
+
+// OpenDirect is not supported on this platform.
+func (s *dirStorage) OpenDirect(name string) (StorageFile, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// SectorSize is not supported on this platform.
+func (s *dirStorage) SectorSize() (int, error) {
+	return 0, errors.ErrUnsupported
+}
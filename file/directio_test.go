@@ -0,0 +1,120 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewAlignedPage(t *testing.T) {
+	t.Parallel()
+
+	for _, size := range []int{1, 400, 512, 4096, 8192, 10000} {
+		p := NewAlignedPage(size)
+		if len(p.Buffer()) != size || cap(p.Buffer()) != size {
+			t.Errorf("NewAlignedPage(%d) buffer len %d, cap %d, want %d", size, len(p.Buffer()), cap(p.Buffer()), size)
+		}
+		if !isAligned(p.Buffer()) {
+			t.Errorf("NewAlignedPage(%d) buffer is not aligned", size)
+		}
+		if !allZero(p.Buffer()) {
+			t.Errorf("NewAlignedPage(%d) buffer is not zeroed", size)
+		}
+	}
+}
+
+func TestFileMgr_DirectIO(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 4096
+	testDir := filepath.Join(os.TempDir(), "testdb_directio")
+	defer os.RemoveAll(testDir)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"checksums and encryption", []Option{WithChecksums(), WithEncryption(testKeys(1))}},
+		{"double write and cache", []Option{WithDoubleWrite(2), WithBlockCache(2, NewLRUPolicy())}},
+	}
+	for i, tt := range tests {
+		dir := filepath.Join(testDir, tt.name)
+		fm, err := NewFileMgr(dir, blocksize, append(tt.opts, WithDirectIO())...)
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skipf("direct I/O not supported here: %v", err)
+		}
+		if err != nil {
+			t.Fatalf("%s: NewFileMgr() failed: %v", tt.name, err)
+		}
+
+		// Aligned pages are used in place, others are copied.
+		pages := []*Page{NewAlignedPage(fm.BlockSize()), NewPageFromBytes(make([]byte, fm.BlockSize()+1)[1:])}
+		for j, p := range pages {
+			blk := NewBlockId("data.tbl", j)
+			p.SetInt(0, i*10+j)
+			if err := fm.Write(blk, p); err != nil {
+				t.Fatalf("%s: Write(%s) error = %v", tt.name, blk, err)
+			}
+		}
+		if _, err := fm.Append("data.tbl"); err != nil {
+			t.Fatalf("%s: Append() error = %v", tt.name, err)
+		}
+		batch := map[BlockId]*Page{NewBlockId("data.tbl", 3): pages[1]}
+		if err := fm.WriteBatch(batch); err != nil {
+			t.Fatalf("%s: WriteBatch() error = %v", tt.name, err)
+		}
+		if err := fm.Close(); err != nil {
+			t.Fatalf("%s: Close() error = %v", tt.name, err)
+		}
+
+		fm, err = NewFileMgr(dir, blocksize, append(tt.opts, WithDirectIO())...)
+		if err != nil {
+			t.Fatalf("%s: reopening failed: %v", tt.name, err)
+		}
+		if n, _ := fm.Length("data.tbl"); n != 4 {
+			t.Errorf("%s: Length() = %d, want 4", tt.name, n)
+		}
+		want := []int{i * 10, i*10 + 1, 0, i*10 + 1}
+		for j, p := range []*Page{NewAlignedPage(fm.BlockSize()), NewPage(fm.BlockSize())} {
+			for b, w := range want {
+				blk := NewBlockId("data.tbl", b)
+				if err := fm.Read(blk, p); err != nil {
+					t.Fatalf("%s: Read(%s) into page %d error = %v", tt.name, blk, j, err)
+				}
+				if got, _ := p.GetInt(0); got != w {
+					t.Errorf("%s: Read(%s) into page %d = %d, want %d", tt.name, blk, j, got, w)
+				}
+			}
+		}
+		fm.Close()
+		if _, err := os.Stat(filepath.Join(dir, directProbeFile)); !os.IsNotExist(err) {
+			t.Errorf("%s: sector size probe file left behind: %v", tt.name, err)
+		}
+	}
+}
+
+func TestFileMgr_DirectIORejected(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_directio_rejected")
+	defer os.RemoveAll(testDir)
+
+	tests := []struct {
+		name      string
+		blocksize int
+		opts      []Option
+	}{
+		{"block size not a multiple of the sector size", 1000, nil},
+		{"compression", 4096, []Option{WithCompression()}},
+		{"storage without direct I/O", 4096, []Option{WithStorage(NewMemStorage())}},
+	}
+	for _, tt := range tests {
+		fm, err := NewFileMgr(testDir, tt.blocksize, append(tt.opts, WithDirectIO())...)
+		if err == nil {
+			fm.Close()
+			t.Errorf("%s: NewFileMgr() error = nil, want error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"sync"
+)
+
+// doubleWriteFile holds the double-write area: fixed-size slots, each a
+// header naming a block followed by the on-disk image last written to it.
+const doubleWriteFile = "doublewrite.dat"
+
+// Slot layout. The header holds the magic number, a CRC32C of the rest of
+// the record, the block number, the image size, the file name length and
+// the file name. Recording the image size lets recovery tell a record
+// written with another block size from a torn one.
+const (
+	dwHeaderSize = 256
+	dwMaxNameLen = dwHeaderSize - 20
+	dwMagic      = 0x44574232 // "DWB2"
+)
+
+// dwDefaultSlots is the number of slots used when WithDoubleWrite is
+// given a non-positive count.
+const dwDefaultSlots = 64
+
+// Slot states. A slot's record stays on disk while the slot is clean, so
+// every block has at most one record, which is never older than the block.
+const (
+	slotFree     = iota // holds no record
+	slotInFlight        // record written, in-place write in progress
+	slotWritten         // in-place write done but not yet flushed
+	slotClean           // in-place write flushed; the slot may be reused
+)
+
+// dwSlot is the in-memory state of one double-write slot.
+type dwSlot struct {
+	owner BlockId
+	state int
+}
+
+// doubleWrite tracks the slots of the double-write area.
+type doubleWrite struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	slots []dwSlot
+	owned map[BlockId]int
+}
+
+// WithDoubleWrite protects blocks against torn writes: each Write first
+// writes and flushes the block's image to a double-write area of the given
+// number of slots, and only then writes it in place. NewFileMgr restores
+// blocks from the area after a crash, so a block is never left half old,
+// half new, even when the block size exceeds what the file system writes
+// atomically. A slot is reused once its block has been flushed in place;
+// when none is free, Write flushes the files holding them.
+func WithDoubleWrite(slots int) Option {
+	return func(fm *FileMgr) {
+		if slots <= 0 {
+			slots = dwDefaultSlots
+		}
+		dw := &doubleWrite{slots: make([]dwSlot, slots), owned: make(map[BlockId]int)}
+		dw.cond = sync.NewCond(&dw.mu)
+		fm.dw = dw
+	}
+}
+
+// slotSize returns the size of a double-write slot.
+func (fm *FileMgr) slotSize() int { return dwHeaderSize + fm.diskBlockSize }
+
+// stageDoubleWrite durably records img as the next image of blk and
+// returns the slot holding it. The caller must hold the block's file lock.
+func (fm *FileMgr) stageDoubleWrite(blk BlockId, img []byte) (int, error) {
+	if len(blk.FileName()) > dwMaxNameLen {
+		return 0, fmt.Errorf("Write: file name %q too long for the double-write area", blk.FileName())
+	}
+	slot, err := fm.acquireSlot(blk)
+	if err != nil {
+		return 0, err
+	}
+	rec := make([]byte, fm.slotSize())
+	binary.BigEndian.PutUint32(rec, dwMagic)
+	binary.BigEndian.PutUint32(rec[8:], uint32(blk.Number()))
+	binary.BigEndian.PutUint32(rec[12:], uint32(len(img)))
+	binary.BigEndian.PutUint32(rec[16:], uint32(len(blk.FileName())))
+	copy(rec[20:], blk.FileName())
+	copy(rec[dwHeaderSize:], img)
+	binary.BigEndian.PutUint32(rec[4:], crc32.Checksum(rec[8:], castagnoli))
+	err = fm.withFile(doubleWriteFile, func(f StorageFile) error {
+		if _, err := f.WriteAt(rec, int64(slot)*int64(fm.slotSize())); err != nil {
+			return err
+		}
+		return fm.flush(f)
+	})
+	if err != nil {
+		// The slot may hold a torn record, which recovery ignores.
+		fm.dw.mu.Lock()
+		delete(fm.dw.owned, blk)
+		fm.dw.slots[slot] = dwSlot{}
+		fm.dw.cond.Broadcast()
+		fm.dw.mu.Unlock()
+		return 0, err
+	}
+	return slot, nil
+}
+
+// acquireSlot returns a slot for a new record of blk: the slot holding
+// blk's current record if there is one, so that an older record never
+// outlives a newer one, or else a free or clean slot.
+func (fm *FileMgr) acquireSlot(blk BlockId) (int, error) {
+	dw := fm.dw
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	for {
+		if s, ok := dw.owned[blk]; ok {
+			switch dw.slots[s].state {
+			case slotClean:
+				dw.slots[s].state = slotInFlight
+				return s, nil
+			case slotInFlight:
+				dw.cond.Wait()
+				continue
+			}
+			// The previous image is not flushed in place yet; overwriting
+			// its record now could lose both versions.
+			if err := fm.flushSlotFiles([]string{blk.FileName()}); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		var pending []string
+		for i := range dw.slots {
+			switch dw.slots[i].state {
+			case slotFree, slotClean:
+				delete(dw.owned, dw.slots[i].owner)
+				dw.slots[i] = dwSlot{owner: blk, state: slotInFlight}
+				dw.owned[blk] = i
+				return i, nil
+			case slotWritten:
+				pending = append(pending, dw.slots[i].owner.FileName())
+			}
+		}
+		if len(pending) > 0 {
+			if err := fm.flushSlotFiles(pending); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		// Every slot is in flight; wait for a writer to finish.
+		dw.cond.Wait()
+	}
+}
+
+// flushSlotFiles flushes files so that their slots become reusable.
+// It is called with fm.dw.mu held and returns with it held.
+func (fm *FileMgr) flushSlotFiles(names []string) error {
+	fm.dw.mu.Unlock()
+	defer fm.dw.mu.Lock()
+	var err error
+	for _, name := range names {
+		err = errors.Join(err, fm.syncFile(name))
+	}
+	return err
+}
+
+// slotWrittenInPlace records that the in-place write staged in slot is done.
+func (dw *doubleWrite) slotWrittenInPlace(slot int) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	dw.slots[slot].state = slotWritten
+	dw.cond.Broadcast()
+}
+
+// writtenSlots returns the slots of filename whose in-place writes are
+// done but not flushed. A flush started afterwards makes them clean.
+func (dw *doubleWrite) writtenSlots(filename string) []int {
+	if dw == nil {
+		return nil
+	}
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	var slots []int
+	for i, s := range dw.slots {
+		if s.state == slotWritten && s.owner.FileName() == filename {
+			slots = append(slots, i)
+		}
+	}
+	return slots
+}
+
+// markClean records that the in-place writes of slots have been flushed.
+func (dw *doubleWrite) markClean(slots []int) {
+	if dw == nil || len(slots) == 0 {
+		return
+	}
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	for _, s := range slots {
+		if dw.slots[s].state == slotWritten {
+			dw.slots[s].state = slotClean
+		}
+	}
+	dw.cond.Broadcast()
+}
+
+// dropDoubleWrites erases the records of the blocks matching keep, before
+// they are changed without going through the double-write area. Their
+// files are flushed first, so that no record is dropped while its block
+// could still be torn. The caller must hold the files' locks, or otherwise
+// keep the blocks from being written meanwhile.
+func (fm *FileMgr) dropDoubleWrites(match func(BlockId) bool) error {
+	if fm.dw == nil {
+		return nil
+	}
+	dw := fm.dw
+	dw.mu.Lock()
+	var slots []int
+	var files []string
+	for blk, s := range dw.owned {
+		if match(blk) {
+			slots = append(slots, s)
+			if dw.slots[s].state != slotClean {
+				files = append(files, blk.FileName())
+			}
+		}
+	}
+	if len(slots) == 0 {
+		dw.mu.Unlock()
+		return nil
+	}
+	err := fm.flushSlotFiles(files)
+	dw.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = fm.withFile(doubleWriteFile, func(f StorageFile) error {
+		zero := make([]byte, dwHeaderSize)
+		for _, s := range slots {
+			if _, err := f.WriteAt(zero, int64(s)*int64(fm.slotSize())); err != nil {
+				return err
+			}
+		}
+		return fm.flush(f)
+	})
+	if err != nil {
+		return err
+	}
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	for _, s := range slots {
+		delete(dw.owned, dw.slots[s].owner)
+		dw.slots[s] = dwSlot{}
+	}
+	dw.cond.Broadcast()
+	return nil
+}
+
+// recoverDoubleWrites copies every intact record of the double-write area
+// back in place, repairing blocks torn by a crash, and then empties the
+// area. Each record is the latest image of its block, so restoring an
+// untorn block is harmless. A torn record is stale: a block is only
+// written in place once its record is flushed. Any other record that
+// cannot be applied is an error, and the area is kept for a later
+// attempt. It runs even without WithDoubleWrite, so that a database is
+// repaired whatever options it is reopened with.
+func (fm *FileMgr) recoverDoubleWrites() error {
+	b, err := fm.readFile(doubleWriteFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	touched := make(map[string]bool)
+	for off := 0; off+dwHeaderSize <= len(b); off += fm.slotSize() {
+		hdr := b[off : off+dwHeaderSize]
+		switch binary.BigEndian.Uint32(hdr) {
+		case 0:
+			continue // a slot never used, or emptied
+		case dwMagic:
+		default:
+			return fmt.Errorf("double-write area: record at offset %d is damaged", off)
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[12:]))
+		end := int64(off) + dwHeaderSize + size
+		if end > int64(len(b)) ||
+			binary.BigEndian.Uint32(hdr[4:]) != crc32.Checksum(b[off+8:end], castagnoli) {
+			continue // torn while being staged
+		}
+		num := int(binary.BigEndian.Uint32(hdr[8:]))
+		n := int(binary.BigEndian.Uint32(hdr[16:]))
+		switch {
+		case size != int64(fm.diskBlockSize):
+			return fmt.Errorf("double-write area holds %d-byte blocks, not %d", size, fm.diskBlockSize)
+		case n > dwMaxNameLen:
+			return fmt.Errorf("double-write area: record at offset %d is damaged", off)
+		}
+		name := string(hdr[20 : 20+n])
+		err := fm.withFile(name, func(f StorageFile) error {
+			return fm.writeImage(f, NewBlockId(name, num), b[off+dwHeaderSize:end])
+		})
+		if err != nil {
+			return err
+		}
+		touched[name] = true
+	}
+	for name := range touched {
+		err := fm.withFile(name, func(f StorageFile) error { return fm.flushBlocks(name, f) })
+		if err != nil {
+			return err
+		}
+	}
+	if err := fm.storage.Remove(doubleWriteFile); err != nil {
+		return err
+	}
+	return fm.syncDir()
+}
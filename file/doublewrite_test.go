@@ -0,0 +1,300 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// writeInt writes v at offset 0 of a block.
+func writeInt(t *testing.T, fm *FileMgr, blk BlockId, v int) {
+	t.Helper()
+	p := NewPage(fm.BlockSize())
+	if err := p.SetInt(0, v); err != nil {
+		t.Fatalf("SetInt() error = %v", err)
+	}
+	if err := p.SetInt(fm.BlockSize()-4, v); err != nil {
+		t.Fatalf("SetInt() error = %v", err)
+	}
+	if err := fm.Write(blk, p); err != nil {
+		t.Fatalf("Write(%s) error = %v", blk, err)
+	}
+}
+
+// readInt returns the value at offset 0 of a block.
+func readInt(t *testing.T, fm *FileMgr, blk BlockId) int {
+	t.Helper()
+	p := NewPage(fm.BlockSize())
+	if err := fm.Read(blk, p); err != nil {
+		t.Fatalf("Read(%s) error = %v", blk, err)
+	}
+	v, _ := p.GetInt(0)
+	return v
+}
+
+// tearBlock overwrites the second half of a block on disk with garbage,
+// as a crash in the middle of an in-place write would.
+func tearBlock(t *testing.T, dir string, blk BlockId, blocksize int) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(dir, blk.FileName()), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer f.Close()
+	garbage := make([]byte, blocksize/2)
+	for i := range garbage {
+		garbage[i] = 0xee
+	}
+	if _, err := f.WriteAt(garbage, int64(blk.Number()*blocksize+blocksize/2)); err != nil {
+		t.Fatalf("WriteAt() error = %v", err)
+	}
+}
+
+func TestFileMgr_DoubleWriteRepairsTornBlocks(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 8192 // larger than a typical atomic write unit
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"every write", nil},
+		{"on demand", []Option{WithDurability(SyncOnDemand)}},
+		{"with checksums", []Option{WithChecksums()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_doublewrite_repair", tt.name)
+			defer os.RemoveAll(testDir)
+
+			opts := append([]Option{WithDoubleWrite(4)}, tt.opts...)
+			fm, err := NewFileMgr(testDir, blocksize, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			blk := NewBlockId("data.tbl", 1)
+			writeInt(t, fm, NewBlockId("data.tbl", 0), 7)
+			writeInt(t, fm, blk, 1)
+			writeInt(t, fm, blk, 2) // the latest image must win
+			if err := fm.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			tearBlock(t, testDir, blk, blocksize)
+
+			// Recovery runs whatever options the database is reopened with,
+			// but the slot layout must match.
+			reopen := []Option(nil)
+			if tt.name == "with checksums" {
+				reopen = []Option{WithChecksums()}
+			}
+			fm, err = NewFileMgr(testDir, blocksize, reopen...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() reopen failed: %v", err)
+			}
+			defer fm.Close()
+			p := NewPage(fm.BlockSize())
+			if err := fm.Read(blk, p); err != nil {
+				t.Fatalf("Read() of repaired block error = %v", err)
+			}
+			first, _ := p.GetInt(0)
+			last, _ := p.GetInt(fm.BlockSize() - 4)
+			if first != 2 || last != 2 {
+				t.Errorf("repaired block holds %d...%d, want 2...2", first, last)
+			}
+			if got := readInt(t, fm, NewBlockId("data.tbl", 0)); got != 7 {
+				t.Errorf("untorn block holds %d, want 7", got)
+			}
+			if _, err := os.Stat(filepath.Join(testDir, doubleWriteFile)); !os.IsNotExist(err) {
+				t.Errorf("double-write area not emptied after recovery, stat error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFileMgr_DoubleWriteIgnoresTornRecords(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_doublewrite_tornrecord")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 512, WithDoubleWrite(2))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	blk := NewBlockId("data.tbl", 0)
+	writeInt(t, fm, blk, 5)
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	// A record torn while being written must not be applied.
+	path := filepath.Join(testDir, doubleWriteFile)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	raw[dwHeaderSize+3] = 99
+	if err := os.WriteFile(path, raw, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	fm, err = NewFileMgr(testDir, 512)
+	if err != nil {
+		t.Fatalf("NewFileMgr() reopen failed: %v", err)
+	}
+	defer fm.Close()
+	if got := readInt(t, fm, blk); got != 5 {
+		t.Errorf("block holds %d, want 5", got)
+	}
+}
+
+func TestFileMgr_DoubleWriteKeepsUnappliedRecords(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		damage    func(raw []byte)
+		blocksize int // the block size NewFileMgr is reopened with
+	}{
+		{"other block size", func([]byte) {}, 1024},
+		{"damaged header", func(raw []byte) { raw[0] ^= 0xff }, 512},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_doublewrite_unapplied", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, 512, WithDoubleWrite(2))
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			blk := NewBlockId("data.tbl", 0)
+			writeInt(t, fm, blk, 5)
+			if err := fm.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			path := filepath.Join(testDir, doubleWriteFile)
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			tt.damage(raw)
+			if err := os.WriteFile(path, raw, 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			if fm, err := NewFileMgr(testDir, tt.blocksize); err == nil {
+				fm.Close()
+				t.Fatalf("NewFileMgr() error = nil, want the record reported")
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("double-write area removed although a record was not applied: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileMgr_DoubleWriteNeverRestoresStaleImages(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		bypass func(t *testing.T, fm *FileMgr, blk BlockId)
+		want   int
+	}{
+		{"WriteBatch", func(t *testing.T, fm *FileMgr, blk BlockId) {
+			p := NewPage(fm.BlockSize())
+			p.SetInt(0, 2)
+			if err := fm.WriteBatch(map[BlockId]*Page{blk: p}); err != nil {
+				t.Fatalf("WriteBatch() error = %v", err)
+			}
+		}, 2},
+		{"PunchHole", func(t *testing.T, fm *FileMgr, blk BlockId) {
+			if err := fm.PunchHole(blk); err != nil {
+				t.Skipf("PunchHole() not available: %v", err)
+			}
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_doublewrite_stale", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, 4096, WithDoubleWrite(4))
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			blk := NewBlockId("data.tbl", 0)
+			writeInt(t, fm, blk, 1)
+			tt.bypass(t, fm, blk)
+			if err := fm.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			fm, err = NewFileMgr(testDir, 4096)
+			if err != nil {
+				t.Fatalf("NewFileMgr() reopen failed: %v", err)
+			}
+			defer fm.Close()
+			if got := readInt(t, fm, blk); got != tt.want {
+				t.Errorf("block holds %d after recovery, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileMgr_DoubleWriteSlotPressure(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_doublewrite_pressure")
+	defer os.RemoveAll(testDir)
+
+	// Far more concurrent writers than slots, with flushes left to the
+	// double-write area to trigger.
+	fm, err := NewFileMgr(testDir, 256, WithDoubleWrite(2), WithDurability(SyncOnDemand))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	const files, blocks = 6, 10
+	var wg sync.WaitGroup
+	for f := range files {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range blocks {
+				writeInt(t, fm, NewBlockId("f"+strconv.Itoa(f), b), f*100+b)
+			}
+		}()
+	}
+	wg.Wait()
+	for f := range files {
+		for b := range blocks {
+			if got := readInt(t, fm, NewBlockId("f"+strconv.Itoa(f), b)); got != f*100+b {
+				t.Errorf("f%d block %d = %d, want %d", f, b, got, f*100+b)
+			}
+		}
+	}
+	fi, err := os.Stat(filepath.Join(testDir, doubleWriteFile))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if max := int64(2 * fm.slotSize()); fi.Size() > max {
+		t.Errorf("double-write area is %d bytes, want at most %d", fi.Size(), max)
+	}
+
+	if err := fm.Remove("f0"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	fm.dw.mu.Lock()
+	defer fm.dw.mu.Unlock()
+	for blk := range fm.dw.owned {
+		if blk.FileName() == "f0" {
+			t.Errorf("double-write record of %s survived Remove()", blk)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package file
+
+import (
+	"errors"
+	"time"
+)
+
+// Durability selects when a FileMgr flushes written blocks to stable
+// storage. Temporary files, whose names start with "temp", are never
+// flushed whatever the mode, since NewFileMgr removes them.
+type Durability struct {
+	mode     int
+	interval time.Duration
+}
+
+const (
+	syncEveryWrite = iota
+	syncOnDemand
+	syncInterval
+)
+
+var (
+	// SyncEveryWrite flushes each Write and Append before it returns.
+	// It is the default.
+	SyncEveryWrite = Durability{mode: syncEveryWrite}
+
+	// SyncOnDemand flushes only when Sync or SyncAll is called, or when
+	// a file is closed.
+	SyncOnDemand = Durability{mode: syncOnDemand}
+)
+
+// SyncInterval flushes every file written to in the last interval d from
+// a background goroutine, and otherwise behaves like SyncOnDemand.
+func SyncInterval(d time.Duration) Durability {
+	return Durability{mode: syncInterval, interval: d}
+}
+
+// WithDurability sets when written blocks are flushed to stable storage.
+// WriteBatch always flushes, since its atomicity depends on it.
+func WithDurability(d Durability) Option {
+	return func(fm *FileMgr) {
+		fm.durability = d
+	}
+}
+
+// WithFdatasync flushes with fdatasync where the platform has it, which
+// skips metadata such as modification times that a block file does not
+// need. Elsewhere it has no effect.
+func WithFdatasync() Option {
+	return func(fm *FileMgr) {
+		fm.datasync = true
+	}
+}
+
+// Sync flushes the blocks written to a file to stable storage. A file
+// that fails to flush stays dirty, so a later Sync tries again.
+func (fm *FileMgr) Sync(filename string) error {
+	fm.syncMu.Lock()
+	defer fm.syncMu.Unlock()
+	fm.mu.Lock()
+	dirty := fm.dirty[filename]
+	delete(fm.dirty, filename)
+	fm.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+	return fm.syncFile(filename)
+}
+
+// SyncAll is a flush barrier: when it returns, every Write and Append that
+// completed before it was called is on stable storage, whatever the
+// durability mode. It also reports any error met by the background
+// flusher since the last call.
+func (fm *FileMgr) SyncAll() error {
+	err := fm.syncDirty()
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	err = errors.Join(fm.flushErr, err)
+	fm.flushErr = nil
+	return err
+}
+
+// syncDirty flushes every file written to since it was last flushed.
+func (fm *FileMgr) syncDirty() error {
+	fm.syncMu.Lock()
+	defer fm.syncMu.Unlock()
+	fm.mu.Lock()
+	names := make([]string, 0, len(fm.dirty))
+	for name := range fm.dirty {
+		names = append(names, name)
+	}
+	clear(fm.dirty)
+	fm.mu.Unlock()
+
+	var err error
+	for _, name := range names {
+		err = errors.Join(err, fm.syncFile(name))
+	}
+	return err
+}
+
+// syncFile flushes one file, freeing the double-write slots of the
+// blocks written to it. If the flush fails, the file is marked dirty, so
+// that the next Sync or SyncAll tries again.
+func (fm *FileMgr) syncFile(filename string) error {
+	slots := fm.dw.writtenSlots(filename)
+	err := fm.withFile(filename, func(f StorageFile) error { return fm.flushBlocks(filename, f) })
+	if err != nil {
+		if !errors.Is(err, ErrClosed) {
+			fm.mu.Lock()
+			fm.dirty[filename] = true
+			fm.mu.Unlock()
+		}
+		return err
+	}
+	fm.dw.markClean(slots)
+	return nil
+}
+
+// flush flushes an open file, with fdatasync if so configured.
+func (fm *FileMgr) flush(f StorageFile) error {
+	if ds, ok := f.(dataSyncer); ok && fm.datasync {
+		return ds.DataSync()
+	}
+	return f.Sync()
+}
+
+// written records that a file was written to, flushing it right away in
+// SyncEveryWrite mode. The caller must hold the file's lock.
+func (fm *FileMgr) written(filename string, f StorageFile) error {
+	if isTempFile(filename) {
+		// Nothing needs flushing before the extents a write replaced in a
+		// temporary file are reused.
+		if fm.compress {
+			sp := fm.extentSpace(filename)
+			fm.releaseRetired(f, sp, sp.takePending())
+		}
+		return nil
+	}
+	if fm.durability.mode == syncEveryWrite {
+		slots := fm.dw.writtenSlots(filename)
+		if err := fm.flushBlocks(filename, f); err != nil {
+			return err
+		}
+		fm.dw.markClean(slots)
+		return nil
+	}
+	fm.mu.Lock()
+	fm.dirty[filename] = true
+	fm.mu.Unlock()
+	return nil
+}
+
+// startFlusher flushes dirty files every interval until stopFlusher is closed.
+func (fm *FileMgr) startFlusher(interval time.Duration) {
+	fm.stopFlusher = make(chan struct{})
+	fm.flusherDone = make(chan struct{})
+	go func() {
+		defer close(fm.flusherDone)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-fm.stopFlusher:
+				return
+			case <-t.C:
+			}
+			if err := fm.syncDirty(); err != nil {
+				fm.mu.Lock()
+				fm.flushErr = errors.Join(fm.flushErr, err)
+				fm.mu.Unlock()
+			}
+		}
+	}()
+}
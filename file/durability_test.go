@@ -0,0 +1,180 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// dirtyFiles returns the number of files written since their last flush.
+func dirtyFiles(fm *FileMgr) int {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return len(fm.dirty)
+}
+
+func TestFileMgr_Durability(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantDirty int // files left dirty after writing two files
+	}{
+		{"default", nil, 0},
+		{"every write", []Option{WithDurability(SyncEveryWrite)}, 0},
+		{"every write with fdatasync", []Option{WithDurability(SyncEveryWrite), WithFdatasync()}, 0},
+		{"on demand", []Option{WithDurability(SyncOnDemand)}, 2},
+		{"on demand with fdatasync", []Option{WithDurability(SyncOnDemand), WithFdatasync()}, 2},
+		{"interval", []Option{WithDurability(SyncInterval(time.Hour))}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_durability", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, 64, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			p := NewPage(64)
+			if err := p.SetInt(0, 42); err != nil {
+				t.Fatalf("SetInt() error = %v", err)
+			}
+			for _, name := range []string{"a.tbl", "b.tbl"} {
+				blk, err := fm.Append(name)
+				if err != nil {
+					t.Fatalf("Append() error = %v", err)
+				}
+				if err := fm.Write(blk, p); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+			}
+			if got := dirtyFiles(fm); got != tt.wantDirty {
+				t.Errorf("dirty files after writes = %d, want %d", got, tt.wantDirty)
+			}
+
+			if err := fm.Sync("a.tbl"); err != nil {
+				t.Fatalf("Sync() error = %v", err)
+			}
+			if got := dirtyFiles(fm); got != max(tt.wantDirty-1, 0) {
+				t.Errorf("dirty files after Sync(a.tbl) = %d, want %d", got, max(tt.wantDirty-1, 0))
+			}
+			if err := fm.Sync("never-written.tbl"); err != nil {
+				t.Errorf("Sync() of a clean file error = %v", err)
+			}
+			if err := fm.SyncAll(); err != nil {
+				t.Fatalf("SyncAll() error = %v", err)
+			}
+			if got := dirtyFiles(fm); got != 0 {
+				t.Errorf("dirty files after SyncAll() = %d, want 0", got)
+			}
+			if err := fm.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			fm2, err := NewFileMgr(testDir, 64)
+			if err != nil {
+				t.Fatalf("NewFileMgr() reopen failed: %v", err)
+			}
+			defer fm2.Close()
+			if err := fm2.Read(NewBlockId("b.tbl", 0), p); err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if v, _ := p.GetInt(0); v != 42 {
+				t.Errorf("GetInt() after reopen = %d, want 42", v)
+			}
+		})
+	}
+}
+
+func TestFileMgr_SyncInterval(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_syncinterval")
+	defer os.RemoveAll(testDir)
+
+	if _, err := NewFileMgr(testDir, 64, WithDurability(SyncInterval(0))); err == nil {
+		t.Errorf("NewFileMgr() with a zero interval error = nil, want error")
+	}
+
+	fm, err := NewFileMgr(testDir, 64, WithDurability(SyncInterval(10*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	if _, err := fm.Append("a.tbl"); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	// The background flusher picks the file up without any explicit sync.
+	deadline := time.Now().Add(5 * time.Second)
+	for dirtyFiles(fm) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("background flusher did not flush a.tbl")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	select {
+	case <-fm.flusherDone:
+	default:
+		t.Errorf("background flusher still running after Close()")
+	}
+}
+
+func TestFileMgr_TempFilesNotFlushed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"compression", []Option{WithCompression()}},
+		{"double write", []Option{WithDoubleWrite(4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_durability_temp", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, 256, append(tt.opts, WithDurability(SyncOnDemand))...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			defer fm.Close()
+			blk := NewBlockId("temp1", 0)
+			size := func() int64 {
+				var n int64
+				err := fm.withFile(blk.FileName(), func(f StorageFile) (err error) {
+					n, err = f.Size()
+					return err
+				})
+				if err != nil {
+					t.Fatalf("Size() error = %v", err)
+				}
+				return n
+			}
+			var sizes []int64
+			for i := range 20 {
+				writeInt(t, fm, blk, i)
+				sizes = append(sizes, size())
+			}
+			if got := dirtyFiles(fm); got != 0 {
+				t.Errorf("dirty files after writing a temporary file = %d, want 0", got)
+			}
+			// Extents replaced in a compressed temporary file are reused
+			// without waiting for a flush.
+			if sizes[len(sizes)-1] != sizes[1] {
+				t.Errorf("file grew from %d to %d bytes over rewrites of one block", sizes[1], sizes[len(sizes)-1])
+			}
+			if got := readInt(t, fm, blk); got != 19 {
+				t.Errorf("block = %d, want 19", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// encTrailerSize is the size of the trailer WithEncryption reserves at the
+// end of each block: the GCM tag, the identifier of the key and the nonce.
+const (
+	encTrailerSize = gcmTagSize + 4 + gcmNonceSize
+	gcmTagSize     = 16
+	gcmNonceSize   = 12
+)
+
+// ErrAuthenticationFailed is returned by Read for an encrypted block that
+// does not decrypt: it was corrupted, moved from another block, or
+// encrypted with a different key of the same identifier.
+var ErrAuthenticationFailed = errors.New("block authentication failed")
+
+// KeyProvider supplies the AES keys of an encrypted database, each known
+// by an identifier stored with the blocks it encrypts. The key of an
+// identifier must never change.
+type KeyProvider interface {
+	// CurrentKeyID returns the identifier of the key new blocks are
+	// encrypted with.
+	CurrentKeyID() (uint32, error)
+	// Key returns the key with the given identifier: 16, 24 or 32 bytes,
+	// selecting AES-128, AES-192 or AES-256.
+	Key(id uint32) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider holding its keys in memory, by identifier.
+// The key with the highest identifier is the current one.
+type StaticKeys map[uint32][]byte
+
+// CurrentKeyID returns the highest key identifier.
+func (k StaticKeys) CurrentKeyID() (uint32, error) {
+	if len(k) == 0 {
+		return 0, errors.New("no encryption keys")
+	}
+	return slices.Max(slices.Collect(maps.Keys(k))), nil
+}
+
+// Key returns the key with the given identifier.
+func (k StaticKeys) Key(id uint32) ([]byte, error) {
+	key, ok := k[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption key %d", id)
+	}
+	return key, nil
+}
+
+// encryption holds the key provider and the ciphers built from its keys.
+type encryption struct {
+	keys  KeyProvider
+	mu    sync.Mutex
+	aeads map[uint32]cipher.AEAD
+}
+
+// WithEncryption encrypts every block with AES-GCM under the current key
+// of keys, using a fresh random nonce on each write. The filename and
+// block number are bound to the ciphertext as associated data, so a block
+// copied elsewhere fails to authenticate. Redo and double-write copies
+// of blocks are encrypted too. BlockSize then returns the configured
+// block size less the trailer holding the tag, key identifier and nonce.
+// Encrypted blocks do not compress, so WithCompression gains nothing.
+func WithEncryption(keys KeyProvider) Option {
+	return func(fm *FileMgr) {
+		fm.enc = &encryption{keys: keys, aeads: make(map[uint32]cipher.AEAD)}
+	}
+}
+
+// aead returns the cipher of the key with the given identifier.
+func (e *encryption) aead(id uint32) (cipher.AEAD, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if a, ok := e.aeads[id]; ok {
+		return a, nil
+	}
+	key, err := e.keys.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key %d: %w", id, err)
+	}
+	a, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	e.aeads[id] = a
+	return a, nil
+}
+
+// plainSize returns the number of bytes of an image that are encrypted:
+// everything before the encryption trailer.
+func (fm *FileMgr) plainSize() int { return fm.diskBlockSize - encTrailerSize }
+
+// encrypt encrypts the image of blk in place under the current key.
+func (fm *FileMgr) encrypt(blk BlockId, img []byte) error {
+	id, err := fm.enc.keys.CurrentKeyID()
+	if err != nil {
+		return err
+	}
+	a, err := fm.enc.aead(id)
+	if err != nil {
+		return err
+	}
+	n := fm.plainSize()
+	nonce := img[n+gcmTagSize+4:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(img[n+gcmTagSize:], id)
+	a.Seal(img[:0], nonce, img[:n], associatedData(blk))
+	return nil
+}
+
+// decrypt authenticates and decrypts the image of blk in place.
+func (fm *FileMgr) decrypt(blk BlockId, img []byte) error {
+	n := fm.plainSize()
+	a, err := fm.enc.aead(keyID(img, n))
+	if err != nil {
+		return fmt.Errorf("Read: %s: %w", blk, err)
+	}
+	if _, err := a.Open(img[:0], img[n+gcmTagSize+4:], img[:n+gcmTagSize], associatedData(blk)); err != nil {
+		return fmt.Errorf("Read: %s: %w", blk, ErrAuthenticationFailed)
+	}
+	return nil
+}
+
+// keyID returns the identifier of the key an image was encrypted with.
+func keyID(img []byte, plainSize int) uint32 {
+	return binary.BigEndian.Uint32(img[plainSize+gcmTagSize:])
+}
+
+// associatedData binds a ciphertext to its block.
+func associatedData(blk BlockId) []byte {
+	ad := binary.BigEndian.AppendUint32(nil, uint32(blk.Number()))
+	return append(ad, blk.FileName()...)
+}
+
+// Rekey re-encrypts under the current key every block of a file that was
+// encrypted with an older one, so that the older keys can be retired.
+// Blocks are rewritten one at a time, as by Write, so the file stays
+// usable throughout.
+func (fm *FileMgr) Rekey(filename string) error {
+	if err := checkName("Rekey", filename); err != nil {
+		return err
+	}
+	return fm.rekey(filename)
+}
+
+// rekey is Rekey for any file of the storage, including free-space maps.
+func (fm *FileMgr) rekey(filename string) error {
+	if fm.enc == nil {
+		return errors.New("Rekey: encryption is not enabled")
+	}
+	current, err := fm.enc.keys.CurrentKeyID()
+	if err != nil {
+		return err
+	}
+	unlock := fm.rlockFile(filename)
+	n, err := fm.length(filename)
+	unlock()
+	if err != nil {
+		return err
+	}
+	for i := range n {
+		if err := fm.rekeyBlock(NewBlockId(filename, i), current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RekeyAll calls Rekey on every database file of the storage. Temporary
+// files are left alone: an external sort may be writing them, and they
+// are removed when the FileMgr is next created anyway.
+func (fm *FileMgr) RekeyAll() error {
+	names, err := fm.storage.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if isReservedFile(name) || isTempFile(name) {
+			continue
+		}
+		if err := fm.rekey(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isReservedFile reports whether a file of the storage holds FileMgr
+// metadata rather than blocks addressed by callers.
+func isReservedFile(name string) bool {
+	switch name {
+	case redoFile, redoTableFile, redoTableNewTmp, doubleWriteFile:
+		return true
+	}
+	return strings.HasSuffix(name, offsetMapSuffix)
+}
+
+// rekeyBlock rewrites blk if it is encrypted with a key other than current.
+func (fm *FileMgr) rekeyBlock(blk BlockId, current uint32) error {
+	defer fm.lockFile(blk.FileName())()
+	img := fm.newImage()
+	stale := false
+	err := fm.withFile(blk.FileName(), func(f StorageFile) error {
+		_, err := fm.readImage(f, blk, img)
+		if err == errHole || err == io.EOF || (err == nil && allZero(img)) {
+			return nil // nothing stored
+		}
+		if err != nil || keyID(img, fm.plainSize()) == current {
+			return err
+		}
+		stale = true
+		return fm.unseal(blk, img)
+	})
+	if err != nil || !stale {
+		return err
+	}
+	return fm.write(blk, img[:fm.blocksize])
+}
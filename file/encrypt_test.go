@@ -0,0 +1,251 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testKeys returns keys with the given identifiers, each 32 bytes
+// derived from its identifier.
+func testKeys(ids ...uint32) StaticKeys {
+	keys := make(StaticKeys)
+	for _, id := range ids {
+		keys[id] = bytes.Repeat([]byte{byte(id)}, 32)
+	}
+	return keys
+}
+
+func TestStaticKeys(t *testing.T) {
+	t.Parallel()
+
+	if _, err := (StaticKeys{}).CurrentKeyID(); err == nil {
+		t.Errorf("CurrentKeyID() with no keys error = nil, want error")
+	}
+	keys := testKeys(3, 7, 5)
+	if id, err := keys.CurrentKeyID(); err != nil || id != 7 {
+		t.Errorf("CurrentKeyID() = %d, %v, want 7", id, err)
+	}
+	if _, err := keys.Key(4); err == nil {
+		t.Errorf("Key(4) error = nil, want error")
+	}
+}
+
+func TestFileMgr_Encryption(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 256
+	const secret = "customer 4711: Jane Doe, 1 Main St"
+	tests := []struct {
+		name string
+		opts []Option
+		want int // BlockSize
+	}{
+		{"plain", nil, blocksize - encTrailerSize},
+		{"checksums", []Option{WithChecksums()}, blocksize - encTrailerSize - trailerSize},
+		{"double write", []Option{WithDoubleWrite(2)}, blocksize - encTrailerSize},
+		{"compression", []Option{WithCompression(), WithBlockCache(4, NewLRUPolicy())}, blocksize - encTrailerSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_encryption", tt.name)
+			defer os.RemoveAll(testDir)
+
+			opts := append([]Option{WithEncryption(testKeys(1))}, tt.opts...)
+			fm, err := NewFileMgr(testDir, blocksize, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			if got := fm.BlockSize(); got != tt.want {
+				t.Errorf("BlockSize() = %d, want %d", got, tt.want)
+			}
+			p := NewPage(fm.BlockSize())
+			if err := p.SetString(0, secret); err != nil {
+				t.Fatalf("SetString() error = %v", err)
+			}
+			if _, err := fm.Append("data.tbl"); err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+			if err := fm.Write(NewBlockId("data.tbl", 1), p); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := fm.WriteBatch(map[BlockId]*Page{NewBlockId("data.tbl", 2): p}); err != nil {
+				t.Fatalf("WriteBatch() error = %v", err)
+			}
+			if err := fm.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			// No file of the directory, including redo and double-write
+			// copies, may hold the plaintext.
+			entries, err := os.ReadDir(testDir)
+			if err != nil {
+				t.Fatalf("ReadDir() error = %v", err)
+			}
+			for _, e := range entries {
+				raw, err := os.ReadFile(filepath.Join(testDir, e.Name()))
+				if err != nil {
+					t.Fatalf("ReadFile() error = %v", err)
+				}
+				if bytes.Contains(raw, []byte("Jane Doe")) {
+					t.Errorf("%s holds plaintext", e.Name())
+				}
+			}
+
+			fm, err = NewFileMgr(testDir, blocksize, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() reopen failed: %v", err)
+			}
+			defer fm.Close()
+			for i, want := range []string{"", secret, secret} {
+				p := NewPage(fm.BlockSize())
+				if err := fm.Read(NewBlockId("data.tbl", i), p); err != nil {
+					t.Fatalf("Read(%d) error = %v", i, err)
+				}
+				if got, _ := p.GetString(0); got != want {
+					t.Errorf("Read(%d) = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFileMgr_EncryptionAuthentication(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 128
+	tests := []struct {
+		name    string
+		corrupt func(img [][]byte) // on-disk images of blocks 0 and 1
+		keys    StaticKeys
+		wantErr error
+	}{
+		{"flipped ciphertext bit", func(img [][]byte) { img[1][10] ^= 0x04 }, testKeys(1), ErrAuthenticationFailed},
+		{"flipped nonce bit", func(img [][]byte) { img[1][blocksize-1] ^= 0x80 }, testKeys(1), ErrAuthenticationFailed},
+		{"block moved", func(img [][]byte) { copy(img[1], img[0]) }, testKeys(1), ErrAuthenticationFailed},
+		{"wrong key", func([][]byte) {}, StaticKeys{1: bytes.Repeat([]byte{9}, 32)}, ErrAuthenticationFailed},
+		{"missing key", func([][]byte) {}, testKeys(2), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_encryption_auth", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, blocksize, WithEncryption(testKeys(1)))
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			for i := range 2 {
+				writeInt(t, fm, NewBlockId("data.tbl", i), 10+i)
+			}
+			if err := fm.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			path := filepath.Join(testDir, "data.tbl")
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+			tt.corrupt([][]byte{raw[:blocksize], raw[blocksize:]})
+			if err := os.WriteFile(path, raw, 0o644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			fm, err = NewFileMgr(testDir, blocksize, WithEncryption(tt.keys))
+			if err != nil {
+				t.Fatalf("NewFileMgr() reopen failed: %v", err)
+			}
+			defer fm.Close()
+			err = fm.Read(NewBlockId("data.tbl", 1), NewPage(fm.BlockSize()))
+			if err == nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Read() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileMgr_Rekey(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_rekey")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 128, WithEncryption(testKeys(1)))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	if err := fm.Rekey("data.tbl"); err != nil {
+		t.Errorf("Rekey() of a missing file error = %v", err)
+	}
+	writeInt(t, fm, NewBlockId("data.tbl", 0), 1)
+	writeInt(t, fm, NewBlockId("data.tbl", 3), 4) // blocks 1 and 2 are unallocated
+	writeInt(t, fm, NewBlockId("idx.tbl", 0), 5)
+	if err := fm.Free(NewBlockId("data.tbl", 3)); err != nil {
+		t.Fatalf("Free() error = %v", err)
+	}
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Rotate to key 2 while key 1 is still known, with a temporary file
+	// written under key 1 that RekeyAll must not touch.
+	keys := testKeys(1)
+	fm, err = NewFileMgr(testDir, 128, WithEncryption(keys))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	writeInt(t, fm, NewBlockId("temp1", 0), 7)
+	keys[2] = testKeys(2)[2]
+	writeInt(t, fm, NewBlockId("data.tbl", 4), 6) // already under key 2
+	if err := fm.RekeyAll(); err != nil {
+		t.Fatalf("RekeyAll() error = %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(testDir, "temp1"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if id := keyID(raw, fm.plainSize()); id != 1 {
+		t.Errorf("temporary file rekeyed to key %d, want it left under key 1", id)
+	}
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Key 1 is retired.
+	fm, err = NewFileMgr(testDir, 128, WithEncryption(testKeys(2)))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	for _, r := range []struct {
+		blk  BlockId
+		want int
+	}{
+		{NewBlockId("data.tbl", 0), 1},
+		{NewBlockId("data.tbl", 1), 0},
+		{NewBlockId("data.tbl", 3), 4},
+		{NewBlockId("data.tbl", 4), 6},
+		{NewBlockId("idx.tbl", 0), 5},
+	} {
+		if got := readInt(t, fm, r.blk); got != r.want {
+			t.Errorf("Read(%s) = %d, want %d", r.blk, got, r.want)
+		}
+	}
+	if blk, err := fm.Allocate("data.tbl"); err != nil || blk.Number() != 3 {
+		t.Errorf("Allocate() = %v, %v, want block 3 from the rekeyed free-space map", blk, err)
+	}
+
+	plain, err := NewFileMgr(filepath.Join(testDir, "plain"), 128)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer plain.Close()
+	if err := plain.Rekey("data.tbl"); err == nil {
+		t.Errorf("Rekey() without encryption error = nil, want error")
+	}
+}
@@ -0,0 +1,283 @@
+package file
+
+import "container/list"
+
+// LRUPolicy evicts the least recently used block.
+type LRUPolicy struct {
+	order *list.List // front is most recently used
+	elems map[BlockId]*list.Element
+}
+
+// NewLRUPolicy creates an LRU eviction policy.
+func NewLRUPolicy() *LRUPolicy {
+	return &LRUPolicy{
+		order: list.New(),
+		elems: make(map[BlockId]*list.Element),
+	}
+}
+
+// Insert implements EvictionPolicy.
+func (p *LRUPolicy) Insert(blk BlockId) {
+	p.elems[blk] = p.order.PushFront(blk)
+}
+
+// Access implements EvictionPolicy.
+func (p *LRUPolicy) Access(blk BlockId) {
+	if e, ok := p.elems[blk]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+// Remove implements EvictionPolicy.
+func (p *LRUPolicy) Remove(blk BlockId) {
+	if e, ok := p.elems[blk]; ok {
+		p.order.Remove(e)
+		delete(p.elems, blk)
+	}
+}
+
+// Victim implements EvictionPolicy.
+func (p *LRUPolicy) Victim() (BlockId, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return BlockId{}, false
+	}
+	blk := e.Value.(BlockId)
+	p.order.Remove(e)
+	delete(p.elems, blk)
+	return blk, true
+}
+
+// clockEntry is a slot of the clock ring.
+type clockEntry struct {
+	blk   BlockId
+	ref   bool
+	valid bool
+}
+
+// ClockPolicy approximates LRU with a reference bit per block and a
+// rotating hand that clears bits until it finds an unreferenced block.
+type ClockPolicy struct {
+	ring  []clockEntry
+	index map[BlockId]int
+	free  []int
+	hand  int
+}
+
+// NewClockPolicy creates a Clock (second chance) eviction policy.
+func NewClockPolicy() *ClockPolicy {
+	return &ClockPolicy{index: make(map[BlockId]int)}
+}
+
+// Insert implements EvictionPolicy.
+func (p *ClockPolicy) Insert(blk BlockId) {
+	e := clockEntry{blk: blk, ref: true, valid: true}
+	if n := len(p.free); n > 0 {
+		slot := p.free[n-1]
+		p.free = p.free[:n-1]
+		p.ring[slot] = e
+		p.index[blk] = slot
+		return
+	}
+	p.index[blk] = len(p.ring)
+	p.ring = append(p.ring, e)
+}
+
+// Access implements EvictionPolicy.
+func (p *ClockPolicy) Access(blk BlockId) {
+	if slot, ok := p.index[blk]; ok {
+		p.ring[slot].ref = true
+	}
+}
+
+// Remove implements EvictionPolicy.
+func (p *ClockPolicy) Remove(blk BlockId) {
+	if slot, ok := p.index[blk]; ok {
+		p.release(slot)
+	}
+}
+
+// Victim implements EvictionPolicy.
+func (p *ClockPolicy) Victim() (BlockId, bool) {
+	if len(p.index) == 0 {
+		return BlockId{}, false
+	}
+	for {
+		slot := p.hand
+		p.hand = (p.hand + 1) % len(p.ring)
+		e := &p.ring[slot]
+		if !e.valid {
+			continue
+		}
+		if e.ref {
+			e.ref = false
+			continue
+		}
+		blk := e.blk
+		p.release(slot)
+		return blk, true
+	}
+}
+
+// release empties a ring slot so it can be reused.
+func (p *ClockPolicy) release(slot int) {
+	delete(p.index, p.ring[slot].blk)
+	p.ring[slot] = clockEntry{}
+	p.free = append(p.free, slot)
+}
+
+// LRUKPolicy evicts the block whose K-th most recent reference is oldest.
+// Blocks referenced fewer than K times are evicted first, least recently
+// used among them. Reference history is discarded when a block leaves the cache.
+type LRUKPolicy struct {
+	k       int
+	clock   int
+	history map[BlockId][]int // most recent reference last
+}
+
+// NewLRUKPolicy creates an LRU-K eviction policy. k must be at least 1.
+func NewLRUKPolicy(k int) *LRUKPolicy {
+	if k < 1 {
+		k = 1
+	}
+	return &LRUKPolicy{k: k, history: make(map[BlockId][]int)}
+}
+
+// Insert implements EvictionPolicy.
+func (p *LRUKPolicy) Insert(blk BlockId) {
+	p.history[blk] = nil
+	p.Access(blk)
+}
+
+// Access implements EvictionPolicy.
+func (p *LRUKPolicy) Access(blk BlockId) {
+	h, ok := p.history[blk]
+	if !ok {
+		return
+	}
+	p.clock++
+	h = append(h, p.clock)
+	if len(h) > p.k {
+		h = h[len(h)-p.k:]
+	}
+	p.history[blk] = h
+}
+
+// Remove implements EvictionPolicy.
+func (p *LRUKPolicy) Remove(blk BlockId) {
+	delete(p.history, blk)
+}
+
+// Victim implements EvictionPolicy.
+func (p *LRUKPolicy) Victim() (BlockId, bool) {
+	var (
+		victim   BlockId
+		found    bool
+		bestFull bool
+		bestTime int
+	)
+	for blk, h := range p.history {
+		full := len(h) == p.k
+		// For blocks with fewer than K references the backward K-distance is
+		// infinite; they are ranked by their most recent reference instead.
+		t := h[len(h)-1]
+		if full {
+			t = h[0]
+		}
+		better := !found ||
+			(!full && bestFull) ||
+			(full == bestFull && t < bestTime)
+		if better {
+			victim, found, bestFull, bestTime = blk, true, full, t
+		}
+	}
+	if found {
+		delete(p.history, victim)
+	}
+	return victim, found
+}
+
+// TwoQueuePolicy implements the full 2Q algorithm: newly cached blocks enter
+// a FIFO queue (A1in); blocks evicted from it are remembered in a ghost queue
+// (A1out), and a block that is cached again while remembered is promoted to
+// an LRU queue (Am) of frequently used blocks.
+type TwoQueuePolicy struct {
+	kin  int
+	kout int
+
+	a1in  *list.List // front is newest
+	a1out *list.List // ghost entries, front is newest
+	am    *list.List // front is most recently used
+
+	elems map[BlockId]*list.Element
+	where map[BlockId]*list.List
+	ghost map[BlockId]*list.Element
+}
+
+// NewTwoQueuePolicy creates a 2Q eviction policy tuned for a cache of the
+// given capacity, using the customary A1in = 25% and A1out = 50% sizes.
+func NewTwoQueuePolicy(capacity int) *TwoQueuePolicy {
+	return &TwoQueuePolicy{
+		kin:   max(1, capacity/4),
+		kout:  max(1, capacity/2),
+		a1in:  list.New(),
+		a1out: list.New(),
+		am:    list.New(),
+		elems: make(map[BlockId]*list.Element),
+		where: make(map[BlockId]*list.List),
+		ghost: make(map[BlockId]*list.Element),
+	}
+}
+
+// Insert implements EvictionPolicy.
+func (p *TwoQueuePolicy) Insert(blk BlockId) {
+	q := p.a1in
+	if g, ok := p.ghost[blk]; ok {
+		p.a1out.Remove(g)
+		delete(p.ghost, blk)
+		q = p.am
+	}
+	p.elems[blk] = q.PushFront(blk)
+	p.where[blk] = q
+}
+
+// Access implements EvictionPolicy.
+func (p *TwoQueuePolicy) Access(blk BlockId) {
+	if p.where[blk] == p.am {
+		p.am.MoveToFront(p.elems[blk])
+	}
+}
+
+// Remove implements EvictionPolicy.
+func (p *TwoQueuePolicy) Remove(blk BlockId) {
+	if q, ok := p.where[blk]; ok {
+		q.Remove(p.elems[blk])
+		delete(p.elems, blk)
+		delete(p.where, blk)
+	}
+}
+
+// Victim implements EvictionPolicy.
+func (p *TwoQueuePolicy) Victim() (BlockId, bool) {
+	if p.a1in.Len() > 0 && (p.a1in.Len() > p.kin || p.am.Len() == 0) {
+		blk := p.evictBack(p.a1in)
+		p.ghost[blk] = p.a1out.PushFront(blk)
+		if p.a1out.Len() > p.kout {
+			old := p.a1out.Remove(p.a1out.Back()).(BlockId)
+			delete(p.ghost, old)
+		}
+		return blk, true
+	}
+	if p.am.Len() > 0 {
+		return p.evictBack(p.am), true
+	}
+	return BlockId{}, false
+}
+
+// evictBack removes and returns the oldest block of q.
+func (p *TwoQueuePolicy) evictBack(q *list.List) BlockId {
+	blk := q.Remove(q.Back()).(BlockId)
+	delete(p.elems, blk)
+	delete(p.where, blk)
+	return blk
+}
@@ -0,0 +1,150 @@
+package file
+
+import (
+	"testing"
+)
+
+func TestEvictionPolicy_Victim(t *testing.T) {
+	t.Parallel()
+
+	blk := func(n int) BlockId { return NewBlockId("test.db", n) }
+
+	type (
+		args struct {
+			policy EvictionPolicy
+			// ops is a sequence of operations: "i" inserts, "a" accesses, "r" removes.
+			ops []struct {
+				op  string
+				num int
+			}
+		}
+		wants struct {
+			victims []int
+		}
+	)
+
+	type op = struct {
+		op  string
+		num int
+	}
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "lru evicts least recently used",
+			args: args{
+				policy: NewLRUPolicy(),
+				ops:    []op{{"i", 0}, {"i", 1}, {"i", 2}, {"a", 0}},
+			},
+			wants: wants{victims: []int{1, 2, 0}},
+		},
+		{
+			name: "lru skips removed blocks",
+			args: args{
+				policy: NewLRUPolicy(),
+				ops:    []op{{"i", 0}, {"i", 1}, {"r", 0}},
+			},
+			wants: wants{victims: []int{1}},
+		},
+		{
+			name: "clock gives referenced blocks a second chance",
+			args: args{
+				policy: NewClockPolicy(),
+				ops:    []op{{"i", 0}, {"i", 1}, {"i", 2}},
+			},
+			// All reference bits are set, so the hand clears them and returns to slot 0.
+			wants: wants{victims: []int{0, 1, 2}},
+		},
+		{
+			name: "clock reuses freed slots",
+			args: args{
+				policy: NewClockPolicy(),
+				ops:    []op{{"i", 0}, {"i", 1}, {"r", 0}, {"i", 2}},
+			},
+			wants: wants{victims: []int{2, 1}},
+		},
+		{
+			name: "lru-2 prefers blocks with a single reference",
+			args: args{
+				policy: NewLRUKPolicy(2),
+				ops:    []op{{"i", 0}, {"a", 0}, {"i", 1}, {"a", 1}, {"i", 2}},
+			},
+			wants: wants{victims: []int{2, 0, 1}},
+		},
+		{
+			name: "lru-2 ranks by second most recent reference",
+			args: args{
+				policy: NewLRUKPolicy(2),
+				ops:    []op{{"i", 0}, {"i", 1}, {"a", 1}, {"a", 0}, {"a", 1}},
+			},
+			// block 0 was referenced at t=1,4 and block 1 at t=3,5.
+			wants: wants{victims: []int{0, 1}},
+		},
+		{
+			name: "2q evicts from the fifo queue first",
+			args: args{
+				policy: NewTwoQueuePolicy(4),
+				ops:    []op{{"i", 0}, {"i", 1}, {"a", 0}},
+			},
+			wants: wants{victims: []int{0, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := tt.args.policy
+			for _, o := range tt.args.ops {
+				switch o.op {
+				case "i":
+					p.Insert(blk(o.num))
+				case "a":
+					p.Access(blk(o.num))
+				case "r":
+					p.Remove(blk(o.num))
+				}
+			}
+			for i, want := range tt.wants.victims {
+				got, ok := p.Victim()
+				if !ok {
+					t.Fatalf("Victim() #%d ok = false, want block %d", i, want)
+				}
+				if got.Number() != want {
+					t.Errorf("Victim() #%d = %v, want block %d", i, got.Number(), want)
+				}
+			}
+			if got, ok := p.Victim(); ok {
+				t.Errorf("Victim() on empty policy = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestTwoQueuePolicy_PromotesGhosts(t *testing.T) {
+	t.Parallel()
+
+	p := NewTwoQueuePolicy(4)
+	blk := func(n int) BlockId { return NewBlockId("test.db", n) }
+
+	p.Insert(blk(0))
+	p.Insert(blk(1))
+	p.Insert(blk(2))
+	// With more than kin blocks in A1in, the oldest one is evicted and remembered.
+	if got, _ := p.Victim(); got != blk(0) {
+		t.Fatalf("Victim() = %v, want %v", got, blk(0))
+	}
+
+	// Re-inserting a remembered block places it in Am. A1in is drained down
+	// to kin blocks before Am is touched.
+	p.Insert(blk(0))
+	for _, want := range []int{1, 0, 2} {
+		got, ok := p.Victim()
+		if !ok || got.Number() != want {
+			t.Errorf("Victim() = %v, %v, want block %d", got, ok, want)
+		}
+	}
+}
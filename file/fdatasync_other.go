@@ -0,0 +1,10 @@
+//go:build !linux
+
+package file
+
+import "os"
+
+// fdatasync falls back to a full sync where fdatasync is not available.
+func fdatasync(f *os.File) error {
+	return f.Sync()
+}
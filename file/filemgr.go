@@ -4,151 +4,534 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"io/fs"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 // FileMgr handles interaction with the OS file system.
+//
+// Blocks are accessed with positional I/O under a reader/writer lock per
+// file, so operations on different files, and reads of the same file, run
+// concurrently.
 type FileMgr struct {
-	dbDirectory string
-	blocksize   int
-	isNew       bool
+	storage       Storage
+	blocksize     int // usable bytes per block, the size of a Page
+	diskBlockSize int // bytes per block on disk, including any trailer
+	isNew         bool
 
+	// mu guards openFiles and locks; it is never held during I/O.
 	mu        sync.Mutex
-	openFiles map[string]*os.File
+	openFiles *handleCache
+	locks     map[string]*fileLock
+	closed    atomic.Bool
+
+	// batchMu serializes WriteBatch calls, which share the redo file.
+	batchMu sync.Mutex
+
+	strict      bool
+	checksums   bool
+	compress    bool
+	extents     map[string]*extentSpace // guarded by mu
+	freeHints   map[string]int          // guarded by mu; see Allocate
+	enc         *encryption
+	dw          *doubleWrite
+	durability  Durability
+	datasync    bool
+	directIO    bool
+	direct      directStorage   // set by WithDirectIO once the storage is checked
+	dirty       map[string]bool // guarded by mu
+	flushErr    error           // guarded by mu
+	syncMu      sync.Mutex      // serializes flushes of dirty files
+	stopFlusher chan struct{}
+	flusherDone chan struct{}
+
+	cache *blockCache
+}
+
+// ErrClosed is returned by operations on a FileMgr after Close.
+var ErrClosed = errors.New("file manager is closed")
+
+// ErrReservedName is returned when a caller names a file that the FileMgr
+// keeps for itself, such as a free-space map.
+var ErrReservedName = errors.New("reserved file name")
+
+var _ io.Closer = (*FileMgr)(nil)
+
+// Option configures optional behavior of a FileMgr.
+type Option func(*FileMgr)
+
+// WithBlockCache enables an in-memory read-through cache holding up to
+// capacity blocks, evicting according to the given policy.
+func WithBlockCache(capacity int, policy EvictionPolicy) Option {
+	return func(fm *FileMgr) {
+		fm.cache = newBlockCache(capacity, policy)
+	}
+}
+
+// WithMaxOpenFiles limits the number of file handles kept open at once.
+// Beyond the limit, the least recently used handle is synced and closed,
+// and reopened when its file is next used. A limit of 0 means unlimited.
+func WithMaxOpenFiles(n int) Option {
+	return func(fm *FileMgr) {
+		fm.openFiles.limit = max(n, 0)
+	}
 }
 
 // NewFileMgr creates a new file manager for the specified directory and block size.
-func NewFileMgr(dbDirectory string, blocksize int) (*FileMgr, error) {
-	fi, err := os.Stat(dbDirectory)
-	isNew := os.IsNotExist(err)
-	if isNew {
-		if mkErr := os.MkdirAll(dbDirectory, 0o755); mkErr != nil {
-			return nil, mkErr
-		}
-	} else if err == nil && !fi.IsDir() {
-		return nil, fmt.Errorf("%s exists and is not a directory", dbDirectory)
+// The directory is not used if WithStorage is given.
+func NewFileMgr(dbDirectory string, blocksize int, opts ...Option) (*FileMgr, error) {
+	fm := &FileMgr{
+		blocksize: blocksize,
+		openFiles: newHandleCache(0),
+		locks:     make(map[string]*fileLock),
+		dirty:     make(map[string]bool),
+		extents:   make(map[string]*extentSpace),
+		freeHints: make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(fm)
+	}
+	names, err := fm.openStorage(dbDirectory)
+	if err != nil {
+		return nil, err
 	}
 
 	// Remove leftover temporary files
-	entries, _ := os.ReadDir(dbDirectory)
-	for _, e := range entries {
-		if strings.HasPrefix(e.Name(), "temp") {
-			_ = os.Remove(filepath.Join(dbDirectory, e.Name()))
+	for _, name := range names {
+		if isTempFile(name) {
+			_ = fm.storage.Remove(name)
 		}
 	}
 
-	return &FileMgr{
-		dbDirectory: dbDirectory,
-		blocksize:   blocksize,
-		isNew:       isNew,
-		openFiles:   make(map[string]*os.File),
-	}, nil
+	fm.diskBlockSize = blocksize
+	if fm.checksums {
+		fm.blocksize -= trailerSize
+	}
+	if fm.enc != nil {
+		fm.blocksize -= encTrailerSize
+	}
+	if fm.blocksize <= 0 {
+		return nil, fmt.Errorf("block size %d leaves no room for block trailers", blocksize)
+	}
+	if fm.directIO {
+		if err := fm.openDirect(); err != nil {
+			return nil, err
+		}
+	}
+	// Repair blocks torn by a crash, then finish any atomic batch that committed before a crash.
+	if err := fm.recoverDoubleWrites(); err != nil {
+		return nil, err
+	}
+	if err := fm.recoverRedo(); err != nil {
+		return nil, err
+	}
+	if fm.durability.mode == syncInterval {
+		if fm.durability.interval <= 0 {
+			return nil, fmt.Errorf("sync interval %v must be positive", fm.durability.interval)
+		}
+		fm.startFlusher(fm.durability.interval)
+	}
+	return fm, nil
+}
+
+// openStorage sets up the default storage in dbDirectory unless another
+// was given, determines whether the database is new, and returns the
+// names of its files.
+func (fm *FileMgr) openStorage(dbDirectory string) ([]string, error) {
+	if fm.storage == nil {
+		s, isNew, err := openDirStorage(dbDirectory)
+		if err != nil {
+			return nil, err
+		}
+		fm.storage, fm.isNew = s, isNew
+		return s.List()
+	}
+	names, err := fm.storage.List()
+	fm.isNew = err == nil && len(names) == 0
+	return names, err
 }
 
 // IsNew returns true if this is a new database.
 func (fm *FileMgr) IsNew() bool { return fm.isNew }
 
-// BlockSize returns the block size in bytes.
+// BlockSize returns the number of usable bytes in a block, which is the
+// size of the pages read and written. It is the configured block size,
+// less the trailer if WithChecksums is set.
 func (fm *FileMgr) BlockSize() int { return fm.blocksize }
 
 // Length returns the number of blocks in the specified file.
 func (fm *FileMgr) Length(filename string) (int, error) {
-	fm.mu.Lock()
-	defer fm.mu.Unlock()
-	f, err := fm.getFile(filename)
-	if err != nil {
-		return 0, err
-	}
-	fi, err := f.Stat()
-	if err != nil {
+	if err := checkName("Length", filename); err != nil {
 		return 0, err
 	}
-	return int(fi.Size() / int64(fm.blocksize)), nil
+	defer fm.rlockFile(filename)()
+	return fm.length(filename)
 }
 
 // Read reads a block into the specified page.
+// If a block cache is configured, cached blocks are served from memory.
+// Reading a block past the end of the file, or inside a hole, yields a
+// zero-filled page, or ErrBlockNotAllocated with WithStrictReads.
 func (fm *FileMgr) Read(blk BlockId, p *Page) error {
-	fm.mu.Lock()
-	defer fm.mu.Unlock()
 	if len(p.buf) != fm.blocksize {
 		return errors.New("Read: page size != blocksize")
 	}
-	f, err := fm.getFile(blk.FileName())
-	if err != nil {
+	if err := checkName("Read", blk.FileName()); err != nil {
 		return err
 	}
-	offset := int64(blk.Number() * fm.blocksize)
-	if _, err := f.Seek(offset, io.SeekStart); err != nil {
-		return err
+	if fm.closed.Load() {
+		return ErrClosed
+	}
+	if fm.cache != nil && fm.cache.get(blk, p.buf) {
+		return nil
 	}
-	_, err = io.ReadFull(f, p.buf)
-	return err
+	defer fm.rlockFile(blk.FileName())()
+	return fm.read(blk, p.buf)
 }
 
 // Write writes a page to the specified block.
 func (fm *FileMgr) Write(blk BlockId, p *Page) error {
-	fm.mu.Lock()
-	defer fm.mu.Unlock()
 	if len(p.buf) != fm.blocksize {
 		return errors.New("Write: page size != blocksize")
 	}
-	f, err := fm.getFile(blk.FileName())
-	if err != nil {
+	if err := checkName("Write", blk.FileName()); err != nil {
 		return err
 	}
-	offset := int64(blk.Number() * fm.blocksize)
-	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+	defer fm.lockFile(blk.FileName())()
+	return fm.write(blk, p.buf)
+}
+
+// Append adds a new zero-filled block to the end of the file and returns its BlockId.
+func (fm *FileMgr) Append(filename string) (BlockId, error) {
+	if err := checkName("Append", filename); err != nil {
+		return BlockId{}, err
+	}
+	defer fm.lockFile(filename)()
+	return fm.appendBlock(filename)
+}
+
+// Remove closes and deletes a file, along with its free-space map and
+// any offset maps. Removing a file that does not exist is not an error.
+func (fm *FileMgr) Remove(filename string) error {
+	if err := checkName("Remove", filename); err != nil {
 		return err
 	}
-	if _, err := f.Write(p.buf); err != nil {
+	names := []string{filename, filename + freeMapSuffix}
+	if fm.compress {
+		names = append(names, filename+offsetMapSuffix, filename+freeMapSuffix+offsetMapSuffix)
+	}
+	for _, name := range names {
+		defer fm.lockFile(name)()
+	}
+	if err := fm.dropDoubleWrites(func(blk BlockId) bool { return slices.Contains(names, blk.FileName()) }); err != nil {
 		return err
 	}
-	// Sync to ensure data is written to disk immediately
-	return f.Sync()
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if fm.closed.Load() {
+		return ErrClosed
+	}
+	for _, name := range names {
+		// Holding the file's lock, no operation is using its handle.
+		if err := fm.openFiles.close(name); err != nil {
+			return err
+		}
+		delete(fm.dirty, name)
+		delete(fm.extents, name)
+		delete(fm.freeHints, name)
+		if fm.cache != nil {
+			fm.cache.removeFile(name)
+		}
+		err := fm.storage.Remove(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
 }
 
-// Append adds a new zero-filled block to the end of the file and returns its BlockId.
-func (fm *FileMgr) Append(filename string) (BlockId, error) {
+// Close stops the background flusher, then syncs and closes every open
+// file. Later operations return ErrClosed; operations already in progress
+// finish and close their files on the way out.
+func (fm *FileMgr) Close() error {
+	if fm.closed.Swap(true) {
+		return nil
+	}
+	if fm.stopFlusher != nil {
+		close(fm.stopFlusher)
+		<-fm.flusherDone
+	}
 	fm.mu.Lock()
 	defer fm.mu.Unlock()
+	clear(fm.dirty)
+	err := fm.flushErr
+	fm.flushErr = nil
+	return errors.Join(err, fm.openFiles.closeAll())
+}
 
-	f, err := fm.getFile(filename)
+// Files returns the names of the files holding blocks addressed by
+// callers, sorted. The FileMgr's own files, such as free-space maps, are
+// left out.
+func (fm *FileMgr) Files() ([]string, error) {
+	names, err := fm.storage.List()
 	if err != nil {
-		return BlockId{}, err
+		return nil, err
+	}
+	files := names[:0]
+	for _, name := range names {
+		if !isReservedName(name) {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// OpenFiles returns the number of file handles currently open.
+func (fm *FileMgr) OpenFiles() int {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return fm.openFiles.len()
+}
+
+// CacheStats returns the block cache hit and miss counts for each filename.
+// It returns nil if no block cache is configured.
+func (fm *FileMgr) CacheStats() map[string]CacheStats {
+	if fm.cache == nil {
+		return nil
 	}
-	// Calculate new block number
-	fi, err := f.Stat()
+	return fm.cache.stats()
+}
+
+// length returns the number of blocks in the file.
+// The caller must hold the file's lock.
+func (fm *FileMgr) length(filename string) (int, error) {
+	var n int
+	err := fm.withFile(filename, func(f StorageFile) error {
+		var err error
+		n, err = fm.blockCount(filename, f)
+		return err
+	})
+	return n, err
+}
+
+// read reads the specified block into buf.
+// The caller must hold the file's lock, shared or exclusive.
+func (fm *FileMgr) read(blk BlockId, buf []byte) error {
+	err := fm.withFile(blk.FileName(), func(f StorageFile) error {
+		return fm.readBlock(f, blk, buf)
+	})
 	if err != nil {
-		return BlockId{}, err
+		return err
 	}
-	newBlkNum := int(fi.Size() / int64(fm.blocksize))
-	blk := NewBlockId(filename, newBlkNum)
+	if fm.cache != nil {
+		fm.cache.put(blk, buf)
+	}
+	return nil
+}
 
-	// Write zero-filled block
+// write writes buf to the specified block, flushing it according to the
+// durability mode.
+// The caller must hold the file's lock exclusively.
+func (fm *FileMgr) write(blk BlockId, buf []byte) error {
+	return fm.withFile(blk.FileName(), func(f StorageFile) error {
+		if fm.diskBlockSize != fm.blocksize && !fm.compress {
+			if err := fm.sealGap(f, blk); err != nil {
+				return err
+			}
+		}
+		img, err := fm.seal(blk, buf)
+		if err != nil {
+			return err
+		}
+		slot := -1
+		if fm.dw != nil && !isTempFile(blk.FileName()) {
+			if slot, err = fm.stageDoubleWrite(blk, img); err != nil {
+				return err
+			}
+		}
+		err = fm.writeImage(f, blk, img)
+		if slot >= 0 {
+			fm.dw.slotWrittenInPlace(slot)
+		}
+		if err != nil {
+			if fm.cache != nil {
+				fm.cache.remove(blk)
+			}
+			return err
+		}
+		if fm.cache != nil {
+			fm.cache.update(blk, buf)
+		}
+		return fm.written(blk.FileName(), f)
+	})
+}
+
+// sealGap writes sealed zero-filled blocks between the end of f and blk.
+// With trailers an all-zero block reads as damaged unless it is a real
+// hole, so the blocks a write past the end skips must not be left zeroed.
+// The caller must hold the file's lock exclusively.
+func (fm *FileMgr) sealGap(f StorageFile, blk BlockId) error {
+	n, err := fm.blockCount(blk.FileName(), f)
+	if err != nil {
+		return err
+	}
 	zero := make([]byte, fm.blocksize)
-	if _, err := f.WriteAt(zero, int64(newBlkNum*fm.blocksize)); err != nil {
-		return BlockId{}, err
+	for ; n < blk.Number(); n++ {
+		gap := NewBlockId(blk.FileName(), n)
+		img, err := fm.seal(gap, zero)
+		if err != nil {
+			return err
+		}
+		if err := fm.writeImage(f, gap, img); err != nil {
+			return err
+		}
 	}
-	if err := f.Sync(); err != nil {
+	return nil
+}
+
+// appendBlock writes a zero-filled block at the end of the file.
+// The caller must hold the file's lock exclusively, which keeps the size
+// computation and the write atomic.
+func (fm *FileMgr) appendBlock(filename string) (BlockId, error) {
+	var blk BlockId
+	err := fm.withFile(filename, func(f StorageFile) error {
+		// Calculate new block number
+		newBlkNum, err := fm.blockCount(filename, f)
+		if err != nil {
+			return err
+		}
+		blk = NewBlockId(filename, newBlkNum)
+
+		// Write zero-filled block
+		zero := make([]byte, fm.blocksize)
+		img, err := fm.seal(blk, zero)
+		if err != nil {
+			return err
+		}
+		if err := fm.writeImage(f, blk, img); err != nil {
+			return err
+		}
+		if fm.cache != nil {
+			fm.cache.update(blk, zero)
+		}
+		return fm.written(filename, f)
+	})
+	if err != nil {
 		return BlockId{}, err
 	}
 	return blk, nil
 }
 
-// getFile returns an open file handle, opening it if necessary.
-func (fm *FileMgr) getFile(filename string) (*os.File, error) {
-	if f, ok := fm.openFiles[filename]; ok {
-		return f, nil
+// fileLock is the reader/writer lock guarding the blocks of a file. It is
+// kept in fm.locks only while some operation holds or waits for it.
+type fileLock struct {
+	sync.RWMutex
+	refs int // guarded by fm.mu
+}
+
+// lockFile takes the exclusive lock of a file and returns a function
+// releasing it. When an operation needs the locks of a file and of its
+// free-space map, it takes the file's lock first.
+func (fm *FileMgr) lockFile(filename string) func() {
+	l := fm.acquireLock(filename)
+	l.Lock()
+	return func() {
+		l.Unlock()
+		fm.releaseLock(filename, l)
+	}
+}
+
+// rlockFile takes the shared lock of a file and returns a function
+// releasing it.
+func (fm *FileMgr) rlockFile(filename string) func() {
+	l := fm.acquireLock(filename)
+	l.RLock()
+	return func() {
+		l.RUnlock()
+		fm.releaseLock(filename, l)
+	}
+}
+
+// acquireLock returns the lock of a file, creating it if no operation is
+// using it, and counts a new user.
+func (fm *FileMgr) acquireLock(filename string) *fileLock {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	l, ok := fm.locks[filename]
+	if !ok {
+		l = new(fileLock)
+		fm.locks[filename] = l
 	}
-	full := filepath.Join(fm.dbDirectory, filename)
-	// Open for read/write, create if not exists
-	f, err := os.OpenFile(full, os.O_RDWR|os.O_CREATE, 0o644)
+	l.refs++
+	return l
+}
+
+// releaseLock drops a user of a file's lock, forgetting the lock once it
+// has none, so that the locks of files no longer used do not accumulate.
+func (fm *FileMgr) releaseLock(filename string, l *fileLock) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if l.refs--; l.refs == 0 {
+		delete(fm.locks, filename)
+	}
+}
+
+// withFile calls fn with an open handle of the file, opening or reopening
+// it if necessary. The handle stays pinned, and so cannot be evicted,
+// until fn returns.
+func (fm *FileMgr) withFile(filename string, fn func(f StorageFile) error) error {
+	h, err := fm.acquire(filename)
+	if err != nil {
+		return err
+	}
+	err = fn(h.f)
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return errors.Join(err, fm.openFiles.release(h))
+}
+
+// acquire returns a pinned handle of the file, opening it if necessary.
+func (fm *FileMgr) acquire(filename string) (*handle, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if fm.closed.Load() {
+		return nil, ErrClosed
+	}
+	if h, ok := fm.openFiles.acquire(filename); ok {
+		return h, nil
+	}
+	f, err := fm.openFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	fm.openFiles[filename] = f
-	return f, nil
+	h, err := fm.openFiles.add(filename, f)
+	if err != nil {
+		h.refs--
+		return nil, errors.Join(err, fm.openFiles.close(filename))
+	}
+	return h, nil
+}
+
+// isTempFile reports whether a file is temporary. NewFileMgr removes
+// temporary files, so their writes are neither flushed nor protected
+// against tearing.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, "temp")
+}
+
+// isReservedName reports whether a file is kept by the FileMgr for itself,
+// so that callers may not name it.
+func isReservedName(name string) bool {
+	return isReservedFile(name) || strings.HasSuffix(name, freeMapSuffix)
+}
+
+// checkName returns ErrReservedName for a file callers may not name.
+func checkName(op, name string) error {
+	if isReservedName(name) {
+		return fmt.Errorf("%s: %s: %w", op, name, ErrReservedName)
+	}
+	return nil
 }
@@ -1,10 +1,14 @@
 package file
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewFileMgr(t *testing.T) {
@@ -343,7 +347,7 @@ func TestFileMgr_ReadWrite_Errors(t *testing.T) {
 		{
 			name:  "correct page size - read from empty file",
 			args:  args{pageSize: 512, operation: "read"},
-			wants: wants{hasError: true}, // EOF error expected when reading from non-existent block
+			wants: wants{hasError: false}, // a block past the end of the file reads as zeros
 		},
 	}
 
@@ -453,6 +457,264 @@ func TestFileMgr_Append(t *testing.T) {
 	}
 }
 
+func TestFileMgr_Remove(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_remove")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 64, WithBlockCache(4, NewLRUPolicy()), WithStrictReads())
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	for range 3 {
+		if _, err := fm.Append("gone.tbl"); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if err := fm.Free(NewBlockId("gone.tbl", 1)); err != nil {
+		t.Fatalf("Free() error = %v", err)
+	}
+	p := NewPage(64)
+	if err := fm.Read(NewBlockId("gone.tbl", 0), p); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	if err := fm.Remove("gone.tbl"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	for _, name := range []string{"gone.tbl", "gone.tbl" + freeMapSuffix} {
+		if _, err := os.Stat(filepath.Join(testDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after Remove(), stat error = %v", name, err)
+		}
+	}
+	// The cached block must not outlive the file.
+	if err := fm.Read(NewBlockId("gone.tbl", 0), p); err == nil {
+		t.Errorf("Read() of removed file error = nil, want error")
+	}
+	if n, err := fm.Length("gone.tbl"); err != nil || n != 0 {
+		t.Errorf("Length() of removed file = %d, %v, want 0, nil", n, err)
+	}
+	if err := fm.Remove("never.tbl"); err != nil {
+		t.Errorf("Remove(missing file) error = %v, want nil", err)
+	}
+}
+
+func TestFileMgr_Close(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_close")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 64, WithBlockCache(4, NewLRUPolicy()))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	blk, err := fm.Append("data.tbl")
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	p := NewPage(64)
+	if err := p.SetInt(0, 77); err != nil {
+		t.Fatalf("SetInt() error = %v", err)
+	}
+	if err := fm.Write(blk, p); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := fm.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if fm.OpenFiles() != 0 {
+		t.Errorf("OpenFiles() after Close() = %d, want 0", fm.OpenFiles())
+	}
+	if err := fm.Close(); err != nil {
+		t.Errorf("second Close() error = %v, want nil", err)
+	}
+
+	ops := []struct {
+		name string
+		op   func() error
+	}{
+		{"Read", func() error { return fm.Read(blk, p) }},
+		{"Write", func() error { return fm.Write(blk, p) }},
+		{"Append", func() error { _, err := fm.Append("data.tbl"); return err }},
+		{"Length", func() error { _, err := fm.Length("data.tbl"); return err }},
+		{"Remove", func() error { return fm.Remove("data.tbl") }},
+	}
+	for _, tt := range ops {
+		if err := tt.op(); !errors.Is(err, ErrClosed) {
+			t.Errorf("%s() after Close() error = %v, want ErrClosed", tt.name, err)
+		}
+	}
+
+	fm2, err := NewFileMgr(testDir, 64)
+	if err != nil {
+		t.Fatalf("NewFileMgr() reopen failed: %v", err)
+	}
+	defer fm2.Close()
+	if err := fm2.Read(blk, p); err != nil {
+		t.Fatalf("Read() after reopen error = %v", err)
+	}
+	if v, _ := p.GetInt(0); v != 77 {
+		t.Errorf("GetInt() after reopen = %d, want 77", v)
+	}
+}
+
+func TestFileMgr_MaxOpenFiles(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_maxopenfiles")
+	defer os.RemoveAll(testDir)
+
+	const limit = 3
+	fm, err := NewFileMgr(testDir, 64, WithMaxOpenFiles(limit))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+
+	const nfiles = 20
+	name := func(i int) string { return "f" + strconv.Itoa(i) + ".tbl" }
+	p := NewPage(64)
+	for i := range nfiles {
+		blk, err := fm.Append(name(i))
+		if err != nil {
+			t.Fatalf("Append(%s) error = %v", name(i), err)
+		}
+		if err := p.SetInt(0, i); err != nil {
+			t.Fatalf("SetInt() error = %v", err)
+		}
+		if err := fm.Write(blk, p); err != nil {
+			t.Fatalf("Write(%s) error = %v", blk, err)
+		}
+		if n := fm.OpenFiles(); n > limit {
+			t.Fatalf("OpenFiles() = %d, want at most %d", n, limit)
+		}
+	}
+
+	// A batch spanning more files than the limit still applies.
+	batch := make(map[BlockId]*Page)
+	for i := range nfiles {
+		bp := NewPage(64)
+		if err := bp.SetInt(0, 1000+i); err != nil {
+			t.Fatalf("SetInt() error = %v", err)
+		}
+		batch[NewBlockId(name(i), 0)] = bp
+	}
+	if err := fm.WriteBatch(batch); err != nil {
+		t.Fatalf("WriteBatch() error = %v", err)
+	}
+
+	// Every evicted file is reopened transparently.
+	for i := range nfiles {
+		if err := fm.Read(NewBlockId(name(i), 0), p); err != nil {
+			t.Fatalf("Read(%s) error = %v", name(i), err)
+		}
+		if v, _ := p.GetInt(0); v != 1000+i {
+			t.Errorf("%s holds %d, want %d", name(i), v, 1000+i)
+		}
+	}
+	if n := fm.OpenFiles(); n > limit {
+		t.Errorf("OpenFiles() = %d, want at most %d", n, limit)
+	}
+}
+
+func TestFileMgr_PerFileLocking(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_perfilelocking")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 64)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	for _, name := range []string{"a.tbl", "b.tbl"} {
+		if _, err := fm.Append(name); err != nil {
+			t.Fatalf("Append(%s) error = %v", name, err)
+		}
+	}
+
+	// While b.tbl is locked for writing, a.tbl stays fully usable and
+	// reads of b.tbl wait.
+	unlockB := fm.lockFile("b.tbl")
+	done := make(chan error, 1)
+	go func() {
+		p := NewPage(64)
+		if err := fm.Write(NewBlockId("a.tbl", 0), p); err != nil {
+			done <- err
+			return
+		}
+		done <- fm.Read(NewBlockId("a.tbl", 0), p)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("I/O on a.tbl error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("I/O on a.tbl blocked by a lock on b.tbl")
+	}
+
+	blocked := make(chan error, 1)
+	go func() { blocked <- fm.Read(NewBlockId("b.tbl", 0), NewPage(64)) }()
+	select {
+	case <-blocked:
+		t.Errorf("Read() of b.tbl completed while it was locked for writing")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlockB()
+	if err := <-blocked; err != nil {
+		t.Errorf("Read() of b.tbl error = %v", err)
+	}
+
+	// Concurrent appends to one file get distinct blocks.
+	const n = 20
+	var wg sync.WaitGroup
+	nums := make(chan int, n)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			blk, err := fm.Append("c.tbl")
+			if err != nil {
+				t.Errorf("Append() error = %v", err)
+				return
+			}
+			nums <- blk.Number()
+		}()
+	}
+	wg.Wait()
+	close(nums)
+	seen := make(map[int]bool)
+	for num := range nums {
+		if seen[num] {
+			t.Errorf("block %d appended twice", num)
+		}
+		seen[num] = true
+	}
+	if length, _ := fm.Length("c.tbl"); length != n {
+		t.Errorf("Length() = %d, want %d", length, n)
+	}
+
+	// Locks are only kept while in use, so short-lived files leave none.
+	for i := range n {
+		name := "temp" + strconv.Itoa(i)
+		if _, err := fm.Append(name); err != nil {
+			t.Fatalf("Append(%s) error = %v", name, err)
+		}
+		if err := fm.Remove(name); err != nil {
+			t.Fatalf("Remove(%s) error = %v", name, err)
+		}
+	}
+	fm.mu.Lock()
+	if len(fm.locks) != 0 {
+		t.Errorf("%d file locks kept while no operation is running", len(fm.locks))
+	}
+	fm.mu.Unlock()
+}
+
 func TestFileMgr_Concurrency(t *testing.T) {
 	testDir := filepath.Join(os.TempDir(), "testdb_concurrent")
 	defer os.RemoveAll(testDir)
@@ -516,3 +778,52 @@ func TestFileMgr_Concurrency(t *testing.T) {
 		t.Errorf("Final length = %v, want %v", length, numGoroutines)
 	}
 }
+
+func TestFileMgr_ReservedNames(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_reserved_names")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 64)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	if _, err := fm.Append("x"); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if err := fm.Free(NewBlockId("x", 0)); err != nil {
+		t.Fatalf("Free() error = %v", err)
+	}
+
+	ops := []struct {
+		name string
+		call func(name string) error
+	}{
+		{"Read", func(name string) error { return fm.Read(NewBlockId(name, 0), NewPage(64)) }},
+		{"Write", func(name string) error { return fm.Write(NewBlockId(name, 0), NewPage(64)) }},
+		{"WriteBatch", func(name string) error {
+			return fm.WriteBatch(map[BlockId]*Page{NewBlockId(name, 0): NewPage(64)})
+		}},
+		{"Append", func(name string) error { _, err := fm.Append(name); return err }},
+		{"Allocate", func(name string) error { _, err := fm.Allocate(name); return err }},
+		{"Free", func(name string) error { return fm.Free(NewBlockId(name, 0)) }},
+		{"PunchHole", func(name string) error { return fm.PunchHole(NewBlockId(name, 0)) }},
+		{"Length", func(name string) error { _, err := fm.Length(name); return err }},
+		{"Remove", fm.Remove},
+		{"Rekey", fm.Rekey},
+	}
+	for _, name := range []string{"x" + freeMapSuffix, "y" + offsetMapSuffix, redoFile, doubleWriteFile} {
+		for _, op := range ops {
+			if err := op.call(name); !errors.Is(err, ErrReservedName) {
+				t.Errorf("%s(%q) error = %v, want ErrReservedName", op.name, name, err)
+			}
+		}
+	}
+
+	// The free-space map of x is untouched.
+	if blk, err := fm.Allocate("x"); err != nil || blk.Number() != 0 {
+		t.Errorf("Allocate() = %v, %v, want block 0", blk, err)
+	}
+}
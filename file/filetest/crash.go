@@ -0,0 +1,120 @@
+package filetest
+
+import (
+	"errors"
+	"fmt"
+
+	"simpledb-in-golang/file"
+)
+
+// Progress records how far a workload got before a crash. The workload
+// calls Mark once an operation whose effects must survive a crash has
+// returned; the check then knows how many such operations completed.
+type Progress struct {
+	marks int
+}
+
+// Mark records that one more durable operation completed.
+func (p *Progress) Mark() { p.marks++ }
+
+// Count returns the number of completed durable operations.
+func (p *Progress) Count() int { return p.marks }
+
+// Scenario describes a crash test: a workload run against a FileMgr until
+// power is lost, and the invariants that must hold once the storage has
+// crashed and the FileMgr is reopened.
+type Scenario struct {
+	BlockSize int
+	// Options configure each FileMgr; WithStorage is added.
+	Options []file.Option
+	// Setup, if set, runs before power can be lost, and everything it
+	// writes is made durable.
+	Setup func(fm *file.FileMgr) error
+	// Workload runs until it finishes or the storage loses power.
+	Workload func(fm *file.FileMgr, p *Progress) error
+	// Check inspects the reopened FileMgr, given the progress the
+	// workload made, and reports any broken invariant.
+	Check func(fm *file.FileMgr, p *Progress) error
+	// Loss decides what becomes of unsynced writes at each crash.
+	Loss PowerLoss
+	// Seed drives the random choices of the crashes.
+	Seed int64
+}
+
+// Reopen crashes s as loss describes and opens a new FileMgr on it. The
+// FileMgr using s before the crash must not be used again.
+func Reopen(s *FaultStorage, loss PowerLoss, blocksize int, opts ...file.Option) (*file.FileMgr, error) {
+	s.Crash(loss)
+	return file.NewFileMgr("", blocksize, append(opts[:len(opts):len(opts)], file.WithStorage(s))...)
+}
+
+// ExploreCrashPoints runs sc once without interruption to count the
+// storage operations of its workload, then once more for every one of
+// them, on fresh storage, losing power right after that operation. After
+// each crash it reopens the storage and runs sc.Check. It returns the
+// failures of every run, each naming its crash point.
+func ExploreCrashPoints(sc Scenario) error {
+	total, err := sc.run(sc.Seed, -1)
+	if err != nil {
+		return fmt.Errorf("uninterrupted run: %w", err)
+	}
+	var errs []error
+	for n := 1; n <= total; n++ {
+		if _, err := sc.run(sc.Seed+int64(n), n); err != nil {
+			errs = append(errs, fmt.Errorf("power lost after %d of %d operations: %w", n, total, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run runs the scenario on fresh storage, losing power after cut
+// operations of the workload, or never if cut is negative. It returns the
+// number of operations the workload performed.
+func (sc Scenario) run(seed int64, cut int) (int, error) {
+	s := NewFaultStorage(seed)
+	opts := append(sc.Options[:len(sc.Options):len(sc.Options)], file.WithStorage(s))
+	fm, err := file.NewFileMgr("", sc.BlockSize, opts...)
+	if err != nil {
+		return 0, err
+	}
+	if sc.Setup != nil {
+		if err := sc.Setup(fm); err != nil {
+			return 0, fmt.Errorf("setup: %w", err)
+		}
+		if err := fm.SyncAll(); err != nil {
+			return 0, fmt.Errorf("setup: %w", err)
+		}
+	}
+
+	start := s.Ops()
+	if cut >= 0 {
+		s.CutPowerAfter(cut)
+	}
+	var p Progress
+	werr := sc.Workload(fm, &p)
+	ops := s.Ops() - start
+	if cut < 0 {
+		if werr != nil {
+			return ops, fmt.Errorf("workload: %w", werr)
+		}
+		// Finish as a clean shutdown would before checking.
+		if err := fm.Close(); err != nil {
+			return ops, err
+		}
+		s.CutPowerAfter(0)
+	} else {
+		if werr != nil && !errors.Is(werr, ErrPowerLost) {
+			return ops, fmt.Errorf("workload: %w", werr)
+		}
+		// Power is cut before Close can make anything durable, so this
+		// only releases resources such as a background flusher.
+		_ = fm.Close()
+	}
+
+	fm, err = Reopen(s, sc.Loss, sc.BlockSize, sc.Options...)
+	if err != nil {
+		return ops, fmt.Errorf("reopen: %w", err)
+	}
+	defer fm.Close()
+	return ops, sc.Check(fm, &p)
+}
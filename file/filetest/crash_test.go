@@ -0,0 +1,41 @@
+package filetest
+
+import (
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+func TestExploreCrashPoints(t *testing.T) {
+	t.Parallel()
+
+	const writes = 3
+	var counts []int
+	err := ExploreCrashPoints(Scenario{
+		BlockSize: 512,
+		Workload: func(fm *file.FileMgr, p *Progress) error {
+			for i := range writes {
+				if err := fm.Write(file.NewBlockId("data.tbl", i), file.NewPage(fm.BlockSize())); err != nil {
+					return err
+				}
+				p.Mark()
+			}
+			return nil
+		},
+		Check: func(fm *file.FileMgr, p *Progress) error {
+			counts = append(counts, p.Count())
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExploreCrashPoints() error = %v", err)
+	}
+	// The uninterrupted run comes first, then one run per operation, the
+	// last of them losing power right after the workload's last operation.
+	if len(counts) < 2 || counts[0] != writes || counts[len(counts)-1] != writes {
+		t.Errorf("progress seen by the checks = %v, want %d first and last", counts, writes)
+	}
+	if counts[1] != 0 {
+		t.Errorf("progress after the first operation = %d, want 0", counts[1])
+	}
+}
@@ -0,0 +1,458 @@
+// Package filetest provides a file.Storage for testing a FileMgr under
+// failures: it injects I/O errors, and simulates power loss by discarding,
+// reordering or tearing the writes that were not synced.
+package filetest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"math/rand"
+	"slices"
+	"sync"
+
+	"simpledb-in-golang/file"
+)
+
+// ErrPowerLost is returned by every operation of a FaultStorage after its
+// power was cut, and by files opened before a Crash.
+var ErrPowerLost = errors.New("storage lost power")
+
+// sectorSize is the unit in which PowerLoss tears writes.
+const sectorSize = 512
+
+// Op identifies a storage operation, for injecting faults.
+type Op int
+
+// Storage operations.
+const (
+	OpOpen Op = iota
+	OpReadAt
+	OpWriteAt
+	OpSize
+	OpSync
+	OpTruncate
+	OpClose
+	OpRemove
+	OpRename
+	OpList
+	OpSyncDir
+)
+
+var opNames = [...]string{"Open", "ReadAt", "WriteAt", "Size", "Sync", "Truncate", "Close", "Remove", "Rename", "List", "SyncDir"}
+
+func (op Op) String() string {
+	if op < 0 || int(op) >= len(opNames) {
+		return fmt.Sprintf("Op(%d)", int(op))
+	}
+	return opNames[op]
+}
+
+// Fault makes a FaultStorage fail an operation.
+type Fault struct {
+	Op   Op
+	Name string // file the fault applies to; empty matches every file
+	N    int    // the fault hits the Nth matching call, counting from 1
+	// Repeat makes the fault hit every matching call from the Nth on, as
+	// a full disk would.
+	Repeat bool
+	// Err is the error returned, such as syscall.EIO or syscall.ENOSPC.
+	Err error
+	// Short makes a failing WriteAt write the first half of its bytes,
+	// returning io.ErrShortWrite if Err is nil.
+	Short bool
+
+	seen int
+}
+
+// PowerLoss describes what becomes of unsynced writes when a FaultStorage
+// crashes. The zero value discards them all.
+type PowerLoss struct {
+	// KeepProbability is the chance that each unsynced write reaches the
+	// disk anyway.
+	KeepProbability float64
+	// Reorder makes the surviving writes reach the disk in random order,
+	// rather than in the order they were issued.
+	Reorder bool
+	// Tear makes each sector of a surviving write reach the disk, or not,
+	// independently.
+	Tear bool
+}
+
+// FaultStorage is an in-memory file.Storage that tracks what has been
+// synced, so that it can lose everything else in a simulated crash.
+// Written data is durable once its file is synced. A file created is
+// durable once it is synced; removals and renames are durable once
+// SyncDir is called.
+type FaultStorage struct {
+	mu      sync.Mutex
+	rng     *rand.Rand
+	files   map[string]*inode // current names
+	durable map[string]*inode // names that survive a crash
+	faults  []*Fault
+	ops     int
+	armed   bool // whether power is lost after cutAt operations
+	cutAt   int
+	lost    bool
+	gen     int // incremented by Crash; older files are dead
+}
+
+// inode is a file of a FaultStorage, which keeps its contents when renamed.
+type inode struct {
+	data    []byte  // contents as read back
+	synced  []byte  // contents on stable storage
+	pending []write // writes since the last sync
+}
+
+// write is an unsynced WriteAt, or a Truncate if truncate is set.
+type write struct {
+	off      int64
+	data     []byte
+	truncate bool
+}
+
+var _ file.Storage = (*FaultStorage)(nil)
+
+// NewFaultStorage returns an empty FaultStorage whose simulated crashes
+// are driven by the given random seed.
+func NewFaultStorage(seed int64) *FaultStorage {
+	return &FaultStorage{
+		rng:     rand.New(rand.NewSource(seed)),
+		files:   make(map[string]*inode),
+		durable: make(map[string]*inode),
+	}
+}
+
+// Inject adds a fault.
+func (s *FaultStorage) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes every injected fault.
+func (s *FaultStorage) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Ops returns the number of operations performed so far.
+func (s *FaultStorage) Ops() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ops
+}
+
+// CutPowerAfter lets n more operations succeed; every later one fails
+// with ErrPowerLost until Crash is called.
+func (s *FaultStorage) CutPowerAfter(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.armed, s.cutAt = true, s.ops+n
+}
+
+// Crash simulates a power loss followed by a restart: the names and
+// contents of files revert to what was durable, plus whatever unsynced
+// writes survive according to loss. Files opened before the crash fail
+// with ErrPowerLost. Injected faults are kept.
+func (s *FaultStorage) Crash(loss PowerLoss) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = make(map[string]*inode, len(s.durable))
+	seen := make(map[*inode]bool)
+	for _, name := range slices.Sorted(maps.Keys(s.durable)) {
+		ino := s.durable[name]
+		s.files[name] = ino
+		if !seen[ino] {
+			seen[ino] = true
+			s.recover(ino, loss)
+		}
+	}
+	s.armed, s.lost = false, false
+	s.gen++
+}
+
+// recover sets the contents of ino after a crash.
+func (s *FaultStorage) recover(ino *inode, loss PowerLoss) {
+	data := slices.Clone(ino.synced)
+	var survivors []write
+	for _, w := range ino.pending {
+		if s.rng.Float64() < loss.KeepProbability {
+			survivors = append(survivors, w)
+		}
+	}
+	if loss.Reorder {
+		s.rng.Shuffle(len(survivors), func(i, j int) {
+			survivors[i], survivors[j] = survivors[j], survivors[i]
+		})
+	}
+	for _, w := range survivors {
+		if w.truncate || !loss.Tear {
+			data = apply(data, w)
+			continue
+		}
+		for off := 0; off < len(w.data); off += sectorSize {
+			if s.rng.Intn(2) == 0 {
+				end := min(off+sectorSize, len(w.data))
+				data = apply(data, write{off: w.off + int64(off), data: w.data[off:end]})
+			}
+		}
+	}
+	ino.data, ino.synced, ino.pending = data, slices.Clone(data), nil
+}
+
+// apply returns data with w performed on it.
+func apply(data []byte, w write) []byte {
+	if w.truncate {
+		if w.off <= int64(len(data)) {
+			return data[:w.off]
+		}
+		return append(data, make([]byte, w.off-int64(len(data)))...)
+	}
+	if end := w.off + int64(len(w.data)); end > int64(len(data)) {
+		data = append(data, make([]byte, end-int64(len(data)))...)
+	}
+	copy(data[w.off:], w.data)
+	return data
+}
+
+// begin accounts for an operation on the named file, returning the fault
+// it hits, if any. The caller must hold s.mu.
+func (s *FaultStorage) begin(op Op, name string) (*Fault, error) {
+	if s.lost {
+		return nil, ErrPowerLost
+	}
+	s.ops++
+	if s.armed && s.ops > s.cutAt {
+		s.lost = true
+		return nil, ErrPowerLost
+	}
+	for _, f := range s.faults {
+		if f.Op != op || f.Name != "" && f.Name != name {
+			continue
+		}
+		f.seen++
+		if f.seen == f.N || f.Repeat && f.seen > f.N {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+// faultErr returns the error a fault makes an operation fail with.
+func faultErr(op Op, name string, f *Fault) error {
+	err := f.Err
+	if err == nil {
+		err = io.ErrShortWrite
+	}
+	return fmt.Errorf("%s %s: %w", op, name, err)
+}
+
+// Open opens a file, creating it empty if it does not exist.
+func (s *FaultStorage) Open(name string) (file.StorageFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, err := s.begin(OpOpen, name); err != nil || f != nil {
+		if f != nil {
+			err = faultErr(OpOpen, name, f)
+		}
+		return nil, err
+	}
+	ino, ok := s.files[name]
+	if !ok {
+		ino = new(inode)
+		s.files[name] = ino
+	}
+	return &faultFile{s: s, name: name, ino: ino, gen: s.gen}, nil
+}
+
+// Remove deletes a file.
+func (s *FaultStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, err := s.begin(OpRemove, name); err != nil || f != nil {
+		if f != nil {
+			err = faultErr(OpRemove, name, f)
+		}
+		return err
+	}
+	if _, ok := s.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(s.files, name)
+	return nil
+}
+
+// Rename replaces the file newname with oldname.
+func (s *FaultStorage) Rename(oldname, newname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, err := s.begin(OpRename, oldname); err != nil || f != nil {
+		if f != nil {
+			err = faultErr(OpRename, oldname, f)
+		}
+		return err
+	}
+	ino, ok := s.files[oldname]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	delete(s.files, oldname)
+	s.files[newname] = ino
+	return nil
+}
+
+// List returns the names of all files, sorted.
+func (s *FaultStorage) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, err := s.begin(OpList, ""); err != nil || f != nil {
+		if f != nil {
+			err = faultErr(OpList, "", f)
+		}
+		return nil, err
+	}
+	return slices.Sorted(maps.Keys(s.files)), nil
+}
+
+// SyncDir makes the current names of files durable.
+func (s *FaultStorage) SyncDir() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, err := s.begin(OpSyncDir, ""); err != nil || f != nil {
+		if f != nil {
+			err = faultErr(OpSyncDir, "", f)
+		}
+		return err
+	}
+	s.durable = make(map[string]*inode, len(s.files))
+	for name, ino := range s.files {
+		s.durable[name] = ino
+	}
+	return nil
+}
+
+// faultFile is an open file of a FaultStorage.
+type faultFile struct {
+	s      *FaultStorage
+	name   string
+	ino    *inode
+	gen    int
+	closed bool
+}
+
+// begin accounts for an operation on the file. The caller must hold f.s.mu.
+func (f *faultFile) begin(op Op) (*Fault, error) {
+	if f.gen != f.s.gen {
+		return nil, ErrPowerLost
+	}
+	if f.closed {
+		return nil, fs.ErrClosed
+	}
+	return f.s.begin(op, f.name)
+}
+
+func (f *faultFile) ReadAt(b []byte, off int64) (int, error) {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+	if ft, err := f.begin(OpReadAt); err != nil || ft != nil {
+		if ft != nil {
+			err = faultErr(OpReadAt, f.name, ft)
+		}
+		return 0, err
+	}
+	if off >= int64(len(f.ino.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.ino.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *faultFile) WriteAt(b []byte, off int64) (int, error) {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+	ft, err := f.begin(OpWriteAt)
+	if err != nil {
+		return 0, err
+	}
+	n := len(b)
+	if ft != nil {
+		if !ft.Short {
+			return 0, faultErr(OpWriteAt, f.name, ft)
+		}
+		n /= 2
+		err = faultErr(OpWriteAt, f.name, ft)
+	}
+	w := write{off: off, data: slices.Clone(b[:n])}
+	f.ino.data = apply(f.ino.data, w)
+	f.ino.pending = append(f.ino.pending, w)
+	return n, err
+}
+
+func (f *faultFile) Size() (int64, error) {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+	if ft, err := f.begin(OpSize); err != nil || ft != nil {
+		if ft != nil {
+			err = faultErr(OpSize, f.name, ft)
+		}
+		return 0, err
+	}
+	return int64(len(f.ino.data)), nil
+}
+
+func (f *faultFile) Truncate(size int64) error {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+	if ft, err := f.begin(OpTruncate); err != nil || ft != nil {
+		if ft != nil {
+			err = faultErr(OpTruncate, f.name, ft)
+		}
+		return err
+	}
+	w := write{off: size, truncate: true}
+	f.ino.data = apply(f.ino.data, w)
+	f.ino.pending = append(f.ino.pending, w)
+	return nil
+}
+
+// Sync makes the file's contents durable, and its name if it was created
+// since the last SyncDir.
+func (f *faultFile) Sync() error {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+	if ft, err := f.begin(OpSync); err != nil || ft != nil {
+		if ft != nil {
+			err = faultErr(OpSync, f.name, ft)
+		}
+		return err
+	}
+	f.ino.synced = slices.Clone(f.ino.data)
+	f.ino.pending = nil
+	if f.s.files[f.name] == f.ino {
+		if _, ok := f.s.durable[f.name]; !ok {
+			f.s.durable[f.name] = f.ino
+		}
+	}
+	return nil
+}
+
+func (f *faultFile) Close() error {
+	f.s.mu.Lock()
+	defer f.s.mu.Unlock()
+	if ft, err := f.begin(OpClose); err != nil || ft != nil {
+		if ft != nil {
+			err = faultErr(OpClose, f.name, ft)
+		}
+		f.closed = true
+		return err
+	}
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,233 @@
+package filetest
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"syscall"
+	"testing"
+)
+
+// contents returns the whole contents of a file.
+func contents(t *testing.T, s *FaultStorage, name string) string {
+	t.Helper()
+	f, err := s.Open(name)
+	if err != nil {
+		t.Fatalf("Open(%s) error = %v", name, err)
+	}
+	defer f.Close()
+	size, err := f.Size()
+	if err != nil {
+		t.Fatalf("Size() error = %v", err)
+	}
+	b := make([]byte, size)
+	if _, err := f.ReadAt(b, 0); err != nil && err != io.EOF {
+		t.Fatalf("ReadAt() error = %v", err)
+	}
+	return string(b)
+}
+
+func TestFaultStorage_Faults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		fault   Fault
+		wantErr []error // result of each of four writes to "a"
+		want    string
+	}{
+		{
+			name:    "EIO on the second write",
+			fault:   Fault{Op: OpWriteAt, N: 2, Err: syscall.EIO},
+			wantErr: []error{nil, syscall.EIO, nil, nil},
+			want:    "a-c-d-",
+		},
+		{
+			name:    "ENOSPC from the third write on",
+			fault:   Fault{Op: OpWriteAt, N: 3, Repeat: true, Err: syscall.ENOSPC},
+			wantErr: []error{nil, nil, syscall.ENOSPC, syscall.ENOSPC},
+			want:    "a-b-",
+		},
+		{
+			name:    "short first write",
+			fault:   Fault{Op: OpWriteAt, N: 1, Short: true},
+			wantErr: []error{io.ErrShortWrite, nil, nil, nil},
+			want:    "ab-c-d-",
+		},
+		{
+			name:    "other file",
+			fault:   Fault{Op: OpWriteAt, Name: "b", N: 1, Err: syscall.EIO},
+			wantErr: []error{nil, nil, nil, nil},
+			want:    "a-b-c-d-",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewFaultStorage(1)
+			s.Inject(tt.fault)
+			f, err := s.Open("a")
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			var off int64
+			for i, rec := range []string{"a-", "b-", "c-", "d-"} {
+				n, err := f.WriteAt([]byte(rec), off)
+				if !errors.Is(err, tt.wantErr[i]) || (err == nil) != (tt.wantErr[i] == nil) {
+					t.Errorf("write %d error = %v, want %v", i, err, tt.wantErr[i])
+				}
+				off += int64(n)
+			}
+			if got := contents(t, s, "a"); got != tt.want {
+				t.Errorf("contents = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFaultStorage_Crash(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		loss PowerLoss
+		want []string // acceptable contents of "a" after the crash
+	}{
+		{"unsynced writes lost", PowerLoss{}, []string{"synced"}},
+		{"unsynced writes kept", PowerLoss{KeepProbability: 1}, []string{"SYNced!"}},
+		{"some writes kept", PowerLoss{KeepProbability: 0.5}, []string{"synced", "SYnced", "synced!", "SYNced", "SYnced!", "SYNced!"}},
+		{"reordered writes", PowerLoss{KeepProbability: 1, Reorder: true}, []string{"SYNced!", "SYnced!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			for seed := range int64(20) {
+				s := NewFaultStorage(seed)
+				f, _ := s.Open("a")
+				f.WriteAt([]byte("synced"), 0)
+				if err := f.Sync(); err != nil {
+					t.Fatalf("Sync() error = %v", err)
+				}
+				f.WriteAt([]byte("SY"), 0)
+				f.WriteAt([]byte("!"), 6)
+				f.WriteAt([]byte("SYN"), 0)
+				s.Crash(tt.loss)
+
+				if _, err := f.Size(); !errors.Is(err, ErrPowerLost) {
+					t.Errorf("Size() on a file opened before the crash error = %v, want ErrPowerLost", err)
+				}
+				if got := contents(t, s, "a"); !slices.Contains(tt.want, got) {
+					t.Errorf("seed %d: contents after crash = %q, want one of %q", seed, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFaultStorage_TornWrites(t *testing.T) {
+	t.Parallel()
+
+	torn := false
+	for seed := range int64(10) {
+		s := NewFaultStorage(seed)
+		f, _ := s.Open("a")
+		f.WriteAt(make([]byte, 4*sectorSize), 0)
+		f.Sync()
+		f.WriteAt(slices.Repeat([]byte{1}, 4*sectorSize), 0)
+		s.Crash(PowerLoss{KeepProbability: 1, Tear: true})
+
+		got := contents(t, s, "a")
+		for i := 0; i < len(got); i += sectorSize {
+			sector := got[i : i+sectorSize]
+			if sector != string(make([]byte, sectorSize)) && sector != string(slices.Repeat([]byte{1}, sectorSize)) {
+				t.Fatalf("seed %d: sector %d is torn within", seed, i/sectorSize)
+			}
+			if sector != got[:sectorSize] {
+				torn = true
+			}
+		}
+	}
+	if !torn {
+		t.Errorf("no write was torn across sectors")
+	}
+}
+
+func TestFaultStorage_Names(t *testing.T) {
+	t.Parallel()
+
+	s := NewFaultStorage(1)
+	for _, name := range []string{"kept", "unsynced", "renamed", "removed"} {
+		f, _ := s.Open(name)
+		f.WriteAt([]byte(name), 0)
+		if name != "unsynced" {
+			f.Sync()
+		}
+		f.Close()
+	}
+	if err := s.SyncDir(); err != nil {
+		t.Fatalf("SyncDir() error = %v", err)
+	}
+	f, _ := s.Open("new")
+	f.Sync()
+	s.Rename("renamed", "moved")
+	s.Remove("removed")
+	if err := s.Remove("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove() of a missing file error = %v, want fs.ErrNotExist", err)
+	}
+
+	// The rename and removal were never made durable.
+	s.Crash(PowerLoss{})
+	names, err := s.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if want := []string{"kept", "new", "removed", "renamed", "unsynced"}; !slices.Equal(names, want) {
+		t.Errorf("List() after crash = %v, want %v", names, want)
+	}
+	if got := contents(t, s, "unsynced"); got != "" {
+		t.Errorf("unsynced file holds %q after crash, want nothing", got)
+	}
+
+	s.Rename("renamed", "moved")
+	s.SyncDir()
+	s.Crash(PowerLoss{})
+	if got := contents(t, s, "moved"); got != "renamed" {
+		t.Errorf("renamed file holds %q, want %q", got, "renamed")
+	}
+}
+
+func TestFaultStorage_CutPower(t *testing.T) {
+	t.Parallel()
+
+	s := NewFaultStorage(1)
+	f, _ := s.Open("a")
+	s.CutPowerAfter(2)
+	if _, err := f.WriteAt([]byte("x"), 0); err != nil {
+		t.Errorf("first WriteAt() error = %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Errorf("Sync() error = %v", err)
+	}
+	if _, err := f.WriteAt([]byte("y"), 1); !errors.Is(err, ErrPowerLost) {
+		t.Errorf("WriteAt() after power cut error = %v, want ErrPowerLost", err)
+	}
+	if _, err := s.List(); !errors.Is(err, ErrPowerLost) {
+		t.Errorf("List() after power cut error = %v, want ErrPowerLost", err)
+	}
+	s.Crash(PowerLoss{KeepProbability: 1})
+	if got := contents(t, s, "a"); got != "x" {
+		t.Errorf("contents after crash = %q, want %q", got, "x")
+	}
+
+	// Power can be cut before any operation, and a crash restores it.
+	s = NewFaultStorage(1)
+	s.CutPowerAfter(0)
+	if _, err := s.Open("a"); !errors.Is(err, ErrPowerLost) {
+		t.Errorf("Open() after power cut error = %v, want ErrPowerLost", err)
+	}
+	s.Crash(PowerLoss{})
+	if _, err := s.Open("a"); err != nil {
+		t.Errorf("Open() after crash error = %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// freeMapSuffix names the free-space map kept alongside each file that has
+// freed blocks. Bit k of byte i in the map is set when block i*8+k of the
+// data file is free.
+//
+// The map is a separate file rather than reserved blocks of the data file,
+// so that block numbers of files that never free a block are unchanged.
+// The two files need no atomic update: Free only sets a bit, and Allocate
+// clears a block before clearing its bit, so a crash between writes at
+// worst leaks a free block. The suffix is reserved: callers may not name
+// a file ending in it.
+const freeMapSuffix = ".fsm"
+
+// Free gives a block of a file back to the allocator, so that a later
+// Allocate on the same file can reuse it. Freeing a block twice is an error.
+func (fm *FileMgr) Free(blk BlockId) error {
+	if err := checkName("Free", blk.FileName()); err != nil {
+		return err
+	}
+	unlock := fm.lockWithFreeMap(blk.FileName())
+	defer unlock()
+	n, err := fm.length(blk.FileName())
+	if err != nil {
+		return err
+	}
+	if blk.Number() < 0 || blk.Number() >= n {
+		return fmt.Errorf("Free: %s is not allocated", blk)
+	}
+	mapblk, i, bit := fm.freeMapPos(blk)
+	buf, err := fm.readFreeMap(mapblk)
+	if err != nil {
+		return err
+	}
+	if buf[i]&bit != 0 {
+		return fmt.Errorf("Free: %s is already free", blk)
+	}
+	buf[i] |= bit
+	fm.mu.Lock()
+	if hint, ok := fm.freeHints[blk.FileName()]; ok && blk.Number() < hint {
+		fm.freeHints[blk.FileName()] = blk.Number()
+	}
+	fm.mu.Unlock()
+	// Extend the map with zeroed blocks, rather than leaving a hole
+	// before the block written.
+	mapn, err := fm.length(mapblk.FileName())
+	if err != nil {
+		return err
+	}
+	for b := mapn; b < mapblk.Number(); b++ {
+		if err := fm.write(NewBlockId(mapblk.FileName(), b), make([]byte, fm.blocksize)); err != nil {
+			return err
+		}
+	}
+	return fm.write(mapblk, buf)
+}
+
+// Allocate returns a zero-filled block of the file, reusing a freed block
+// if there is one and appending a new block otherwise. The search starts
+// at a hint, below which no block of the file is free, so that it does
+// not rescan the whole map on every call.
+func (fm *FileMgr) Allocate(filename string) (BlockId, error) {
+	if err := checkName("Allocate", filename); err != nil {
+		return BlockId{}, err
+	}
+	unlock := fm.lockWithFreeMap(filename)
+	defer unlock()
+	mapfile := filename + freeMapSuffix
+	n, err := fm.length(mapfile)
+	if err != nil {
+		return BlockId{}, err
+	}
+	bitsPerBlock := fm.blocksize * 8
+	fm.mu.Lock()
+	hint := fm.freeHints[filename]
+	fm.mu.Unlock()
+	for b := hint / bitsPerBlock; b < n; b++ {
+		mapblk := NewBlockId(mapfile, b)
+		buf, err := fm.readFreeMap(mapblk)
+		if err != nil {
+			return BlockId{}, err
+		}
+		start := 0
+		if b == hint/bitsPerBlock {
+			start = hint % bitsPerBlock / 8
+		}
+		for i := start; i < len(buf); i++ {
+			x := buf[i]
+			if x == 0 {
+				continue
+			}
+			k := bits.TrailingZeros8(x)
+			blk := NewBlockId(filename, (b*fm.blocksize+i)*8+k)
+			// Clear the block before taking it off the map, so that a crash
+			// in between leaves it free rather than leaked.
+			if err := fm.write(blk, make([]byte, fm.blocksize)); err != nil {
+				return BlockId{}, err
+			}
+			buf[i] &^= 1 << k
+			if err := fm.write(mapblk, buf); err != nil {
+				return BlockId{}, err
+			}
+			fm.setFreeHint(filename, blk.Number()+1)
+			return blk, nil
+		}
+	}
+	fm.setFreeHint(filename, n*bitsPerBlock)
+	return fm.appendBlock(filename)
+}
+
+// setFreeHint records that no block of filename below hint is free.
+func (fm *FileMgr) setFreeHint(filename string, hint int) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.freeHints[filename] = hint
+}
+
+// lockWithFreeMap takes the exclusive locks of a file and of its
+// free-space map, and returns a function releasing them.
+func (fm *FileMgr) lockWithFreeMap(filename string) func() {
+	unlock := fm.lockFile(filename)
+	unlockMap := fm.lockFile(filename + freeMapSuffix)
+	return func() {
+		unlockMap()
+		unlock()
+	}
+}
+
+// freeMapPos returns the map block, byte index and bit mask describing blk.
+func (fm *FileMgr) freeMapPos(blk BlockId) (BlockId, int, byte) {
+	bitsPerBlock := fm.blocksize * 8
+	mapblk := NewBlockId(blk.FileName()+freeMapSuffix, blk.Number()/bitsPerBlock)
+	pos := blk.Number() % bitsPerBlock
+	return mapblk, pos / 8, 1 << (pos % 8)
+}
+
+// readFreeMap returns the contents of a map block; blocks past the end of
+// the map, or in a hole left by an older version, are all zero, meaning
+// that none of their blocks is free.
+// The caller must hold the map's lock.
+func (fm *FileMgr) readFreeMap(mapblk BlockId) ([]byte, error) {
+	buf := make([]byte, fm.blocksize)
+	n, err := fm.length(mapblk.FileName())
+	if err != nil || mapblk.Number() >= n {
+		return buf, err
+	}
+	if err := fm.read(mapblk, buf); err != nil && !errors.Is(err, ErrBlockNotAllocated) {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,265 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMgr_FreeAllocate(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			initialBlocks int
+			free          []int
+		}
+		wants struct {
+			// allocated are the block numbers returned by successive Allocate calls.
+			allocated []int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "nothing freed extends the file",
+			args:  args{initialBlocks: 2, free: nil},
+			wants: wants{allocated: []int{2, 3}},
+		},
+		{
+			name:  "new file",
+			args:  args{initialBlocks: 0, free: nil},
+			wants: wants{allocated: []int{0, 1}},
+		},
+		{
+			name:  "freed blocks are reused lowest first",
+			args:  args{initialBlocks: 5, free: []int{3, 1}},
+			wants: wants{allocated: []int{1, 3, 5}},
+		},
+		{
+			name:  "block beyond the first map byte",
+			args:  args{initialBlocks: 20, free: []int{17}},
+			wants: wants{allocated: []int{17, 20}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_freemap_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			blocksize := 512
+			fm, err := NewFileMgr(testDir, blocksize)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			for i := range tt.args.initialBlocks {
+				p := NewPage(blocksize)
+				p.SetInt(0, i+1)
+				if err := fm.Write(NewBlockId("data.db", i), p); err != nil {
+					t.Fatalf("Write() failed: %v", err)
+				}
+			}
+			for _, n := range tt.args.free {
+				if err := fm.Free(NewBlockId("data.db", n)); err != nil {
+					t.Fatalf("Free(%d) error = %v", n, err)
+				}
+			}
+
+			for _, want := range tt.wants.allocated {
+				blk, err := fm.Allocate("data.db")
+				if err != nil {
+					t.Fatalf("Allocate() error = %v", err)
+				}
+				if blk.Number() != want {
+					t.Errorf("Allocate() = %v, want block %d", blk, want)
+				}
+				p := NewPage(blocksize)
+				if err := fm.Read(blk, p); err != nil {
+					t.Fatalf("Read() failed: %v", err)
+				}
+				if v, _ := p.GetInt(0); v != 0 {
+					t.Errorf("allocated block %d holds %v, want zero-filled", blk.Number(), v)
+				}
+			}
+		})
+	}
+}
+
+func TestFileMgr_Free_Errors(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_freemap_errors")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 512)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	for range 2 {
+		if _, err := fm.Append("data.db"); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+	if err := fm.Free(NewBlockId("data.db", 1)); err != nil {
+		t.Fatalf("Free() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		blk  BlockId
+	}{
+		{"double free", NewBlockId("data.db", 1)},
+		{"past end of file", NewBlockId("data.db", 2)},
+		{"negative block", NewBlockId("data.db", -1)},
+		{"free-space map block", NewBlockId("data.db"+freeMapSuffix, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := fm.Free(tt.blk); err == nil {
+				t.Errorf("Free(%v) error = nil, want error", tt.blk)
+			}
+		})
+	}
+}
+
+func TestFileMgr_Free_Persistent(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_freemap_persistent")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 512)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	for range 3 {
+		if _, err := fm.Append("data.db"); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+	if err := fm.Free(NewBlockId("data.db", 0)); err != nil {
+		t.Fatalf("Free() error = %v", err)
+	}
+
+	fm2, err := NewFileMgr(testDir, 512)
+	if err != nil {
+		t.Fatalf("NewFileMgr() reopen failed: %v", err)
+	}
+	blk, err := fm2.Allocate("data.db")
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if blk.Number() != 0 {
+		t.Errorf("Allocate() after reopen = %v, want block 0", blk)
+	}
+	if n, _ := fm2.Length("data.db"); n != 3 {
+		t.Errorf("Length() = %v, want 3", n)
+	}
+}
+
+func TestFileMgr_Free_LaterMapBlock(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"checksums", []Option{WithChecksums()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_freemap_later_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, 64, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			defer fm.Close()
+			// Block 1000 is described by the second map block.
+			last := 1000
+			if last/(fm.BlockSize()*8) == 0 {
+				t.Fatalf("block %d is in the first map block", last)
+			}
+			if err := fm.Write(NewBlockId("data.db", last), NewPage(fm.BlockSize())); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+			if err := fm.Free(NewBlockId("data.db", last)); err != nil {
+				t.Fatalf("Free() error = %v", err)
+			}
+			blk, err := fm.Allocate("data.db")
+			if err != nil {
+				t.Fatalf("Allocate() error = %v", err)
+			}
+			if blk.Number() != last {
+				t.Errorf("Allocate() = block %d, want %d", blk.Number(), last)
+			}
+			if blk, err := fm.Allocate("data.db"); err != nil || blk.Number() != last+1 {
+				t.Errorf("second Allocate() = block %d, %v, want %d", blk.Number(), err, last+1)
+			}
+		})
+	}
+}
+
+func TestFileMgr_Allocate_Hint(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_freemap_hint")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 64)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	bitsPerBlock := fm.BlockSize() * 8
+	if err := fm.Write(NewBlockId("data.db", 1199), NewPage(fm.BlockSize())); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	steps := []struct {
+		free     int // block freed before the Allocate, or -1
+		want     int
+		wantHint int
+	}{
+		{1100, 1100, 1101},
+		{5, 5, 6},
+		{-1, 1200, 3 * bitsPerBlock}, // the map is exhausted, so later calls skip it
+		{-1, 1201, 3 * bitsPerBlock},
+		{700, 700, 701},
+	}
+	for i, s := range steps {
+		if s.free >= 0 {
+			if err := fm.Free(NewBlockId("data.db", s.free)); err != nil {
+				t.Fatalf("step %d: Free() error = %v", i, err)
+			}
+		}
+		blk, err := fm.Allocate("data.db")
+		if err != nil {
+			t.Fatalf("step %d: Allocate() error = %v", i, err)
+		}
+		if blk.Number() != s.want {
+			t.Errorf("step %d: Allocate() = block %d, want %d", i, blk.Number(), s.want)
+		}
+		if hint := fm.freeHints["data.db"]; hint != s.wantHint {
+			t.Errorf("step %d: hint = %d, want %d", i, hint, s.wantHint)
+		}
+	}
+
+	if err := fm.Remove("data.db"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, ok := fm.freeHints["data.db"]; ok {
+		t.Errorf("hint outlives Remove()")
+	}
+}
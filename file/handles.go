@@ -0,0 +1,119 @@
+package file
+
+import (
+	"container/list"
+	"errors"
+)
+
+// handle is an open file kept by a handleCache. refs counts the
+// operations currently using it; only idle handles are ever evicted.
+type handle struct {
+	name string
+	f    StorageFile
+	refs int
+}
+
+// handleCache holds the open file handles of a FileMgr. When a limit is
+// set, opening a file beyond it syncs and closes the least recently used
+// idle handle; the file is reopened on its next use. If every handle is
+// busy the limit is exceeded until one is released.
+type handleCache struct {
+	limit   int // 0 means unlimited
+	files   map[string]*list.Element
+	lru     *list.List // front is most recently used
+	closing bool
+}
+
+func newHandleCache(limit int) *handleCache {
+	return &handleCache{limit: limit, files: make(map[string]*list.Element), lru: list.New()}
+}
+
+// acquire returns the open handle of name, pinned and marked as recently used.
+func (c *handleCache) acquire(name string) (*handle, bool) {
+	e, ok := c.files[name]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	h := e.Value.(*handle)
+	h.refs++
+	return h, true
+}
+
+// add records a newly opened handle, pinned, first evicting idle handles
+// to make room.
+func (c *handleCache) add(name string, f StorageFile) (*handle, error) {
+	var err error
+	for c.limit > 0 && c.lru.Len() >= c.limit {
+		evicted, cerr := c.evictIdle()
+		err = errors.Join(err, cerr)
+		if !evicted {
+			break
+		}
+	}
+	h := &handle{name: name, f: f, refs: 1}
+	c.files[name] = c.lru.PushFront(h)
+	return h, err
+}
+
+// release unpins a handle, closing it if the cache is over its limit or
+// being shut down.
+func (c *handleCache) release(h *handle) error {
+	h.refs--
+	if h.refs > 0 {
+		return nil
+	}
+	if c.closing {
+		return c.close(h.name)
+	}
+	var err error
+	for c.limit > 0 && c.lru.Len() > c.limit {
+		evicted, cerr := c.evictIdle()
+		err = errors.Join(err, cerr)
+		if !evicted {
+			break
+		}
+	}
+	return err
+}
+
+// evictIdle closes the least recently used idle handle and reports
+// whether there was one.
+func (c *handleCache) evictIdle() (bool, error) {
+	for e := c.lru.Back(); e != nil; e = e.Prev() {
+		if h := e.Value.(*handle); h.refs == 0 {
+			return true, c.close(h.name)
+		}
+	}
+	return false, nil
+}
+
+// close syncs and closes the handle of name, if it is open. The caller
+// must make sure the handle is idle.
+func (c *handleCache) close(name string) error {
+	e, ok := c.files[name]
+	if !ok {
+		return nil
+	}
+	c.lru.Remove(e)
+	delete(c.files, name)
+	f := e.Value.(*handle).f
+	return errors.Join(f.Sync(), f.Close())
+}
+
+// closeAll syncs and closes every idle handle; busy handles are closed
+// as they are released.
+func (c *handleCache) closeAll() error {
+	c.closing = true
+	var err error
+	for {
+		evicted, cerr := c.evictIdle()
+		err = errors.Join(err, cerr)
+		if !evicted {
+			return err
+		}
+	}
+}
+
+// len returns the number of open handles.
+func (c *handleCache) len() int { return c.lru.Len() }
@@ -0,0 +1,130 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandleCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_handles_lru")
+	if err := os.MkdirAll(testDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	c := newHandleCache(2)
+	open := func(name string) *os.File {
+		f, err := os.Create(filepath.Join(testDir, name))
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		h, err := c.add(name, osFile{f})
+		if err != nil {
+			t.Fatalf("add(%s) error = %v", name, err)
+		}
+		if err := c.release(h); err != nil {
+			t.Fatalf("release(%s) error = %v", name, err)
+		}
+		return f
+	}
+	touch := func(name string) bool {
+		h, ok := c.acquire(name)
+		if ok {
+			c.release(h)
+		}
+		return ok
+	}
+
+	a := open("a")
+	open("b")
+	if !touch("a") { // a is now the most recently used
+		t.Fatalf("acquire(a) = false, want true")
+	}
+	open("c")
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", true},
+	}
+	for _, tt := range tests {
+		if ok := touch(tt.name); ok != tt.want {
+			t.Errorf("acquire(%s) = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+	if c.len() != 2 {
+		t.Errorf("len() = %d, want 2", c.len())
+	}
+
+	if err := c.closeAll(); err != nil {
+		t.Fatalf("closeAll() error = %v", err)
+	}
+	if c.len() != 0 {
+		t.Errorf("len() after closeAll() = %d, want 0", c.len())
+	}
+	if _, err := a.Write([]byte("x")); err == nil {
+		t.Errorf("Write() on closed handle error = nil, want error")
+	}
+}
+
+func TestHandleCache_KeepsBusyHandles(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_handles_busy")
+	if err := os.MkdirAll(testDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	c := newHandleCache(1)
+	add := func(name string) *handle {
+		f, err := os.Create(filepath.Join(testDir, name))
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		h, err := c.add(name, osFile{f})
+		if err != nil {
+			t.Fatalf("add(%s) error = %v", name, err)
+		}
+		return h
+	}
+
+	// Both handles are busy, so the limit is exceeded rather than
+	// closing a file in use.
+	a := add("a")
+	b := add("b")
+	if c.len() != 2 {
+		t.Fatalf("len() with two busy handles = %d, want 2", c.len())
+	}
+	if _, err := a.f.WriteAt([]byte("x"), 0); err != nil {
+		t.Errorf("WriteAt() on busy handle error = %v", err)
+	}
+
+	// Releasing one brings the cache back within its limit.
+	if err := c.release(a); err != nil {
+		t.Fatalf("release(a) error = %v", err)
+	}
+	if c.len() != 1 {
+		t.Errorf("len() after release = %d, want 1", c.len())
+	}
+
+	// Shutting down waits for the busy handle to be released.
+	if err := c.closeAll(); err != nil {
+		t.Fatalf("closeAll() error = %v", err)
+	}
+	if c.len() != 1 {
+		t.Errorf("len() after closeAll() with a busy handle = %d, want 1", c.len())
+	}
+	if err := c.release(b); err != nil {
+		t.Fatalf("release(b) error = %v", err)
+	}
+	if c.len() != 0 {
+		t.Errorf("len() after releasing the last handle = %d, want 0", c.len())
+	}
+}
@@ -0,0 +1,162 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrBlockNotAllocated is returned with WithStrictReads when reading a
+// block that lies past the end of its file, or inside a hole where the
+// file system can tell.
+var ErrBlockNotAllocated = errors.New("block not allocated")
+
+// WithStrictReads makes reads of blocks past the end of a file, or inside
+// a hole, return ErrBlockNotAllocated instead of a zero-filled page.
+// Telling a hole from a block of zeros costs a SEEK_DATA call for each
+// all-zero block read.
+func WithStrictReads() Option {
+	return func(fm *FileMgr) {
+		fm.strict = true
+	}
+}
+
+// PunchHole deallocates the storage of a block while keeping the file's
+// length. The block then reads as zeros, or as unallocated with
+// WithStrictReads. Where the block size is not a multiple of the file
+// system's, only the block's whole file-system blocks are deallocated
+// and the rest is zeroed, so the block reads as zeros in either mode.
+// It returns errors.ErrUnsupported where the platform or file system
+// cannot punch holes.
+func (fm *FileMgr) PunchHole(blk BlockId) error {
+	if err := checkName("PunchHole", blk.FileName()); err != nil {
+		return err
+	}
+	defer fm.lockFile(blk.FileName())()
+	if err := fm.dropDoubleWrites(func(b BlockId) bool { return b == blk }); err != nil {
+		return err
+	}
+	return fm.withFile(blk.FileName(), func(f StorageFile) error {
+		if fm.compress {
+			if err := fm.dropExtent(f, blk); err != nil {
+				return fmt.Errorf("PunchHole: %s: %w", blk, err)
+			}
+		} else {
+			offset := int64(blk.Number()) * int64(fm.diskBlockSize)
+			if err := fm.punchHole(f, offset, int64(fm.diskBlockSize)); err != nil {
+				return fmt.Errorf("PunchHole: %s: %w", blk, err)
+			}
+			if fm.diskBlockSize != fm.blocksize {
+				hole, err := fm.isHole(f, offset, int64(fm.diskBlockSize))
+				if err != nil {
+					return err
+				}
+				if !hole {
+					// The trailer was zeroed along with the data, and a
+					// zeroed trailer that is not a hole reads as damage,
+					// so seal the zeroed block again.
+					return fm.write(blk, make([]byte, fm.blocksize))
+				}
+			}
+		}
+		if fm.cache != nil {
+			fm.cache.remove(blk)
+		}
+		return fm.written(blk.FileName(), f)
+	})
+}
+
+// readBlock reads the block blk of f into buf, applying the FileMgr's
+// model of unallocated blocks and unsealing the image.
+func (fm *FileMgr) readBlock(f StorageFile, blk BlockId, buf []byte) error {
+	trailers := fm.diskBlockSize != fm.blocksize
+	img := buf
+	if trailers {
+		img = fm.newImage()
+	}
+	n, err := fm.readImage(f, blk, img)
+	if err == errHole {
+		return fm.unallocated(blk, buf)
+	}
+	if err == io.EOF {
+		if fm.strict {
+			return fmt.Errorf("Read: %s: %w", blk, ErrBlockNotAllocated)
+		}
+		if trailers {
+			n = 0 // a partial block cannot be verified
+		}
+		clear(buf[n:])
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// Only an all-zero block can be a hole, so the check costs nothing for
+	// blocks holding data. Without trailers it is only needed in strict
+	// mode; with them, an all-zero block that is not a hole was damaged,
+	// since a sealed image is never all zeros. A compressed block's holes
+	// are in its map.
+	if allZero(img) && (trailers || fm.strict) {
+		hole := false
+		if !fm.compress {
+			offset := int64(blk.Number()) * int64(fm.diskBlockSize)
+			if hole, err = fm.isHole(f, offset, int64(fm.diskBlockSize)); err != nil {
+				return err
+			}
+		}
+		switch {
+		case hole:
+			return fm.unallocated(blk, buf)
+		case !trailers:
+		case fm.enc != nil:
+			return fmt.Errorf("Read: %s: %w", blk, ErrAuthenticationFailed)
+		default:
+			return &ChecksumMismatchError{Blk: blk}
+		}
+	}
+	if !trailers {
+		return nil
+	}
+	if err := fm.unseal(blk, img); err != nil {
+		return err
+	}
+	copy(buf, img)
+	return nil
+}
+
+// unallocated zero-fills buf for a read of an unallocated block, or
+// returns ErrBlockNotAllocated in strict mode.
+func (fm *FileMgr) unallocated(blk BlockId, buf []byte) error {
+	if fm.strict {
+		return fmt.Errorf("Read: %s: %w", blk, ErrBlockNotAllocated)
+	}
+	clear(buf)
+	return nil
+}
+
+// allZero reports whether every byte of b is zero.
+func allZero(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// punchHole deallocates length bytes of f at offset, if the storage can.
+func (fm *FileMgr) punchHole(f StorageFile, offset, length int64) error {
+	if hp, ok := f.(holePuncher); ok {
+		return hp.PunchHole(offset, length)
+	}
+	return errors.ErrUnsupported
+}
+
+// isHole reports whether the length bytes of f at offset hold no data.
+// Storage that cannot punch holes reports none.
+func (fm *FileMgr) isHole(f StorageFile, offset, length int64) (bool, error) {
+	if hp, ok := f.(holePuncher); ok {
+		return hp.IsHole(offset, length)
+	}
+	return false, nil
+}
@@ -0,0 +1,39 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Linux values not exported by the syscall package.
+const (
+	fallocKeepSize  = 0x1 // FALLOC_FL_KEEP_SIZE
+	fallocPunchHole = 0x2 // FALLOC_FL_PUNCH_HOLE
+	seekData        = 3   // SEEK_DATA
+)
+
+// punchHole deallocates length bytes of f at offset.
+func punchHole(f *os.File, offset, length int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocKeepSize|fallocPunchHole, offset, length)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return errors.ErrUnsupported
+	}
+	return err
+}
+
+// isHole reports whether the length bytes of f at offset hold no data.
+// File systems without SEEK_DATA support report no holes.
+func isHole(f *os.File, offset, length int64) (bool, error) {
+	next, err := syscall.Seek(int(f.Fd()), offset, seekData)
+	switch {
+	case errors.Is(err, syscall.ENXIO):
+		// No data at or after offset.
+		return true, nil
+	case errors.Is(err, syscall.EINVAL):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return next >= offset+length, nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package file
+
+import (
+	"errors"
+	"os"
+)
+
+// punchHole is not supported on this platform.
+func punchHole(f *os.File, offset, length int64) error {
+	return errors.ErrUnsupported
+}
+
+// isHole cannot detect holes on this platform; they read as zeros.
+func isHole(f *os.File, offset, length int64) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,203 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFileMgr_UnallocatedReads(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 4096 // a whole file-system block, so holes are real
+	tests := []struct {
+		name     string
+		opts     []Option
+		zeroFill bool
+	}{
+		{"zero fill", nil, true},
+		{"strict", []Option{WithStrictReads()}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_unallocated", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, blocksize, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			defer fm.Close()
+			if _, err := fm.Append("data.tbl"); err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+			// Writing block 5 leaves blocks 1-4 as a hole.
+			p := NewPage(blocksize)
+			if err := p.SetInt(0, 9); err != nil {
+				t.Fatalf("SetInt() error = %v", err)
+			}
+			if err := fm.Write(NewBlockId("data.tbl", 5), p); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			// A torn append leaves a partial block at the end.
+			f, err := os.OpenFile(filepath.Join(testDir, "data.tbl"), os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatalf("OpenFile() error = %v", err)
+			}
+			if _, err := f.Write([]byte{1, 2, 3}); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			f.Close()
+
+			reads := []struct {
+				name      string
+				blknum    int
+				allocated bool
+				holeCheck bool // only detectable where the platform reports holes
+			}{
+				{"appended zero block", 0, true, false},
+				{"hole", 2, false, true},
+				{"written block", 5, true, false},
+				{"partial block", 6, false, false},
+				{"past end of file", 50, false, false},
+			}
+			for _, r := range reads {
+				if r.holeCheck && runtime.GOOS != "linux" {
+					continue
+				}
+				p := NewPage(blocksize)
+				p.Buffer()[100] = 0xff
+				err := fm.Read(NewBlockId("data.tbl", r.blknum), p)
+				switch {
+				case r.allocated || tt.zeroFill:
+					if err != nil {
+						t.Errorf("%s: Read() error = %v", r.name, err)
+					}
+					if p.Buffer()[100] != 0 {
+						t.Errorf("%s: Read() left stale bytes in the page", r.name)
+					}
+				case !errors.Is(err, ErrBlockNotAllocated):
+					t.Errorf("%s: Read() error = %v, want ErrBlockNotAllocated", r.name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFileMgr_PunchHole(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 4096
+	testDir := filepath.Join(os.TempDir(), "testdb_punchhole")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, blocksize, WithBlockCache(4, NewLRUPolicy()))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	p := NewPage(blocksize)
+	if err := p.SetString(0, "data"); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+	for i := range 3 {
+		if err := fm.Write(NewBlockId("data.tbl", i), p); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	blk := NewBlockId("data.tbl", 1)
+	if err := fm.Read(blk, p); err != nil { // now cached
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	err = fm.PunchHole(blk)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skipf("PunchHole() not supported here: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("PunchHole() error = %v", err)
+	}
+	if n, _ := fm.Length("data.tbl"); n != 3 {
+		t.Errorf("Length() after PunchHole() = %d, want 3", n)
+	}
+	if err := fm.Read(blk, p); err != nil {
+		t.Fatalf("Read() of punched block error = %v", err)
+	}
+	if !allZero(p.Buffer()) {
+		t.Errorf("punched block is not zero-filled")
+	}
+	if err := fm.Read(NewBlockId("data.tbl", 2), p); err != nil {
+		t.Errorf("Read() of neighbouring block error = %v", err)
+	}
+	if s, _ := p.GetString(0); s != "data" {
+		t.Errorf("neighbouring block holds %q, want %q", s, "data")
+	}
+
+	sfm, err := NewFileMgr(testDir, blocksize, WithStrictReads())
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer sfm.Close()
+	if err := sfm.Read(blk, p); !errors.Is(err, ErrBlockNotAllocated) {
+		t.Errorf("Read() of punched block in strict mode error = %v, want ErrBlockNotAllocated", err)
+	}
+}
+
+func TestFileMgr_PunchHoleUnaligned(t *testing.T) {
+	t.Parallel()
+
+	const blocksize = 1000 // not a multiple of any file-system block size
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"strict", []Option{WithStrictReads()}},
+		{"checksums", []Option{WithChecksums()}},
+		{"strict checksums", []Option{WithChecksums(), WithStrictReads()}},
+		{"encryption", []Option{WithEncryption(testKeys(1))}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			testDir := filepath.Join(os.TempDir(), "testdb_punchhole_unaligned", tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := NewFileMgr(testDir, blocksize, tt.opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			defer fm.Close()
+			for i := range 3 {
+				writeInt(t, fm, NewBlockId("data.tbl", i), i+1)
+			}
+			blk := NewBlockId("data.tbl", 1)
+			err = fm.PunchHole(blk)
+			if errors.Is(err, errors.ErrUnsupported) {
+				t.Skipf("PunchHole() not supported here: %v", err)
+			}
+			if err != nil {
+				t.Fatalf("PunchHole() error = %v", err)
+			}
+
+			// Only part of the block could be deallocated, so it reads as
+			// zeros whatever the mode.
+			p := NewPage(fm.BlockSize())
+			p.Buffer()[0] = 0xff
+			if err := fm.Read(blk, p); err != nil {
+				t.Errorf("Read() of punched block error = %v", err)
+			}
+			if !allZero(p.Buffer()) {
+				t.Errorf("punched block is not zero-filled")
+			}
+			for _, n := range []int{0, 2} {
+				if got := readInt(t, fm, NewBlockId("data.tbl", n)); got != n+1 {
+					t.Errorf("block %d = %d, want %d", n, got, n+1)
+				}
+			}
+		})
+	}
+}
@@ -19,6 +19,13 @@ func NewPage(blocksize int) *Page {
 	return &Page{buf: make([]byte, blocksize)}
 }
 
+// NewAlignedPage creates a new page with the specified block size whose
+// buffer is aligned in memory for direct I/O, so that a FileMgr using
+// WithDirectIO reads and writes it without an intermediate copy.
+func NewAlignedPage(blocksize int) *Page {
+	return &Page{buf: alignedBuffer(blocksize)}
+}
+
 // NewPageFromBytes creates a page that wraps the given byte slice.
 func NewPageFromBytes(b []byte) *Page {
 	return &Page{buf: b}
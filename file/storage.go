@@ -0,0 +1,311 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Storage is where a FileMgr keeps its files, each known by a name. The
+// default, used unless WithStorage is given, is the database directory of
+// the operating system's file system.
+type Storage interface {
+	// Open opens a file for reading and writing, creating it empty if it
+	// does not exist.
+	Open(name string) (StorageFile, error)
+	// Remove deletes a file. Removing a missing file returns an error
+	// matching fs.ErrNotExist.
+	Remove(name string) error
+	// Rename atomically replaces the file newname with oldname.
+	Rename(oldname, newname string) error
+	// List returns the names of all files, sorted.
+	List() ([]string, error)
+	// SyncDir makes earlier creations, removals and renames durable.
+	SyncDir() error
+}
+
+// StorageFile is an open file of a Storage. ReadAt and WriteAt follow the
+// io.ReaderAt and io.WriterAt contracts, writing past the end extends the
+// file with zeros, and calls on one file may be concurrent.
+//
+// A StorageFile may also provide these methods, which a FileMgr uses when
+// present:
+//
+//	DataSync() error                              // for WithFdatasync; Sync otherwise
+//	PunchHole(offset, length int64) error         // for PunchHole; unsupported otherwise
+//	IsHole(offset, length int64) (bool, error)    // to tell holes from zeroed blocks
+type StorageFile interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	Size() (int64, error)
+	Sync() error
+	Truncate(size int64) error
+}
+
+// Optional StorageFile methods.
+type (
+	dataSyncer interface {
+		DataSync() error
+	}
+	holePuncher interface {
+		PunchHole(offset, length int64) error
+		IsHole(offset, length int64) (bool, error)
+	}
+)
+
+// WithStorage makes the FileMgr keep its files in s rather than in the
+// database directory, which is then not used. The database is new if s
+// holds no files.
+func WithStorage(s Storage) Option {
+	return func(fm *FileMgr) {
+		fm.storage = s
+	}
+}
+
+// dirStorage keeps files in a directory of the operating system.
+type dirStorage struct {
+	dir string
+}
+
+// NewDirStorage returns a Storage keeping its files in dir, which is
+// created if it does not exist.
+func NewDirStorage(dir string) (Storage, error) {
+	s, _, err := openDirStorage(dir)
+	return s, err
+}
+
+// openDirStorage returns the Storage of dir, creating dir if necessary,
+// and reports whether it was created.
+func openDirStorage(dir string) (*dirStorage, bool, error) {
+	fi, err := os.Stat(dir)
+	isNew := os.IsNotExist(err)
+	if isNew {
+		if mkErr := os.MkdirAll(dir, 0o755); mkErr != nil {
+			return nil, false, mkErr
+		}
+	} else if err == nil && !fi.IsDir() {
+		return nil, false, fmt.Errorf("%s exists and is not a directory", dir)
+	}
+	return &dirStorage{dir: dir}, isNew, nil
+}
+
+func (s *dirStorage) Open(name string) (StorageFile, error) {
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return osFile{f}, nil
+}
+
+func (s *dirStorage) Remove(name string) error {
+	return os.Remove(filepath.Join(s.dir, name))
+}
+
+func (s *dirStorage) Rename(oldname, newname string) error {
+	return os.Rename(filepath.Join(s.dir, oldname), filepath.Join(s.dir, newname))
+}
+
+func (s *dirStorage) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// SyncDir flushes directory metadata so that renames and removals are durable.
+func (s *dirStorage) SyncDir() error {
+	d, err := os.Open(s.dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// osFile is a StorageFile backed by an operating system file.
+type osFile struct {
+	*os.File
+}
+
+func (f osFile) Size() (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (f osFile) DataSync() error { return fdatasync(f.File) }
+
+func (f osFile) PunchHole(offset, length int64) error { return punchHole(f.File, offset, length) }
+
+func (f osFile) IsHole(offset, length int64) (bool, error) { return isHole(f.File, offset, length) }
+
+// MemStorage is a Storage holding its files in memory, for tests and
+// ephemeral databases. Its contents last as long as the MemStorage, so a
+// FileMgr closed and reopened on the same MemStorage finds its files.
+// Sync does nothing.
+type MemStorage struct {
+	mu    sync.Mutex
+	files map[string]*memData
+}
+
+// memData is the contents of a file of a MemStorage. It outlives the
+// file's removal while the file is open.
+type memData struct {
+	mu   sync.RWMutex
+	data []byte
+}
+
+// NewMemStorage returns an empty MemStorage.
+func NewMemStorage() *MemStorage {
+	return &MemStorage{files: make(map[string]*memData)}
+}
+
+// Open opens a file, creating it empty if it does not exist.
+func (s *MemStorage) Open(name string) (StorageFile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.files[name]
+	if !ok {
+		d = new(memData)
+		s.files[name] = d
+	}
+	return &memFile{d: d}, nil
+}
+
+// Remove deletes a file.
+func (s *MemStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(s.files, name)
+	return nil
+}
+
+// Rename replaces the file newname with oldname.
+func (s *MemStorage) Rename(oldname, newname string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.files[oldname]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	delete(s.files, oldname)
+	s.files[newname] = d
+	return nil
+}
+
+// List returns the names of all files, sorted.
+func (s *MemStorage) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.files))
+	for name := range s.files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// SyncDir does nothing.
+func (s *MemStorage) SyncDir() error { return nil }
+
+// errFileClosed is returned by operations on a closed memFile.
+var errFileClosed = errors.New("file already closed")
+
+// memFile is an open file of a MemStorage.
+type memFile struct {
+	d      *memData
+	closed bool
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	f.d.mu.RLock()
+	defer f.d.mu.RUnlock()
+	if off >= int64(len(f.d.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.d.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) WriteAt(b []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if end := off + int64(len(b)); end > int64(len(f.d.data)) {
+		f.d.data = append(f.d.data, make([]byte, end-int64(len(f.d.data)))...)
+	}
+	return copy(f.d.data[off:], b), nil
+}
+
+func (f *memFile) Size() (int64, error) {
+	if f.closed {
+		return 0, errFileClosed
+	}
+	f.d.mu.RLock()
+	defer f.d.mu.RUnlock()
+	return int64(len(f.d.data)), nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	if f.closed {
+		return errFileClosed
+	}
+	if size < 0 {
+		return errors.New("negative size")
+	}
+	f.d.mu.Lock()
+	defer f.d.mu.Unlock()
+	if size <= int64(len(f.d.data)) {
+		f.d.data = f.d.data[:size]
+	} else {
+		f.d.data = append(f.d.data, make([]byte, size-int64(len(f.d.data)))...)
+	}
+	return nil
+}
+
+func (f *memFile) Sync() error {
+	if f.closed {
+		return errFileClosed
+	}
+	return nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return errFileClosed
+	}
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,173 @@
+package file
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_storage")
+	defer os.RemoveAll(testDir)
+	dir, err := NewDirStorage(testDir)
+	if err != nil {
+		t.Fatalf("NewDirStorage() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		s    Storage
+	}{
+		{"dir", dir},
+		{"memory", NewMemStorage()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.s
+			f, err := s.Open("a")
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if size, err := f.Size(); err != nil || size != 0 {
+				t.Errorf("Size() of a new file = %d, %v, want 0", size, err)
+			}
+			// Writing past the end fills the gap with zeros.
+			if _, err := f.WriteAt([]byte("xyz"), 5); err != nil {
+				t.Fatalf("WriteAt() error = %v", err)
+			}
+			buf := make([]byte, 10)
+			n, err := f.ReadAt(buf, 0)
+			if n != 8 || err != io.EOF || string(buf[:n]) != "\x00\x00\x00\x00\x00xyz" {
+				t.Errorf("ReadAt() = %d, %v, %q", n, err, buf[:n])
+			}
+			if _, err := f.ReadAt(buf, 8); err != io.EOF {
+				t.Errorf("ReadAt() at the end error = %v, want io.EOF", err)
+			}
+			if err := f.Truncate(6); err != nil {
+				t.Fatalf("Truncate() error = %v", err)
+			}
+			if size, _ := f.Size(); size != 6 {
+				t.Errorf("Size() after Truncate() = %d, want 6", size)
+			}
+			if err := f.Sync(); err != nil {
+				t.Errorf("Sync() error = %v", err)
+			}
+			if err := f.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+
+			// Reopening finds the contents.
+			f, err = s.Open("a")
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if n, _ := f.ReadAt(buf, 0); string(buf[:n]) != "\x00\x00\x00\x00\x00x" {
+				t.Errorf("ReadAt() after reopening = %q", buf[:n])
+			}
+			f.Close()
+
+			if _, err := s.Open("b"); err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			if names, err := s.List(); err != nil || !slices.Equal(names, []string{"a", "b"}) {
+				t.Errorf("List() = %v, %v, want [a b]", names, err)
+			}
+			if err := s.Rename("a", "b"); err != nil {
+				t.Fatalf("Rename() error = %v", err)
+			}
+			if err := s.Remove("a"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("Remove() of a renamed file error = %v, want fs.ErrNotExist", err)
+			}
+			if err := s.Remove("b"); err != nil {
+				t.Errorf("Remove() error = %v", err)
+			}
+			if names, err := s.List(); err != nil || len(names) != 0 {
+				t.Errorf("List() = %v, %v, want none", names, err)
+			}
+			if err := s.SyncDir(); err != nil {
+				t.Errorf("SyncDir() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFileMgr_MemStorage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"checksums and double write", []Option{WithChecksums(), WithDoubleWrite(2)}},
+		{"compression", []Option{WithCompression()}},
+		{"encryption", []Option{WithEncryption(testKeys(1)), WithMaxOpenFiles(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s := NewMemStorage()
+			opts := append([]Option{WithStorage(s)}, tt.opts...)
+			dbDir := filepath.Join(os.TempDir(), "testdb_memstorage_unused")
+
+			fm, err := NewFileMgr(dbDir, 256, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			if !fm.IsNew() {
+				t.Errorf("IsNew() on empty storage = false, want true")
+			}
+			writeInt(t, fm, NewBlockId("a.tbl", 0), 1)
+			p := NewPage(fm.BlockSize())
+			p.SetInt(0, 2)
+			if err := fm.WriteBatch(map[BlockId]*Page{NewBlockId("b.tbl", 1): p}); err != nil {
+				t.Fatalf("WriteBatch() error = %v", err)
+			}
+			if _, err := fm.Append("tempsort1"); err != nil {
+				t.Fatalf("Append() error = %v", err)
+			}
+			if err := fm.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			fm, err = NewFileMgr(dbDir, 256, opts...)
+			if err != nil {
+				t.Fatalf("NewFileMgr() reopen failed: %v", err)
+			}
+			defer fm.Close()
+			if fm.IsNew() {
+				t.Errorf("IsNew() on reopened storage = true, want false")
+			}
+			if got := readInt(t, fm, NewBlockId("a.tbl", 0)); got != 1 {
+				t.Errorf("a.tbl block 0 = %d, want 1", got)
+			}
+			if got := readInt(t, fm, NewBlockId("b.tbl", 1)); got != 2 {
+				t.Errorf("b.tbl block 1 = %d, want 2", got)
+			}
+			names, _ := s.List()
+			for _, name := range names {
+				if name == "tempsort1" {
+					t.Errorf("temporary file survived reopening")
+				}
+			}
+			if err := fm.Remove("a.tbl"); err != nil {
+				t.Fatalf("Remove() error = %v", err)
+			}
+			if n, err := fm.Length("a.tbl"); err != nil || n != 0 {
+				t.Errorf("Length() after Remove() = %d, %v, want 0", n, err)
+			}
+			if err := fm.PunchHole(NewBlockId("b.tbl", 1)); tt.name != "compression" && !errors.Is(err, errors.ErrUnsupported) {
+				t.Errorf("PunchHole() error = %v, want errors.ErrUnsupported", err)
+			}
+			if _, err := os.Stat(dbDir); !os.IsNotExist(err) {
+				t.Errorf("database directory created with memory storage, stat error = %v", err)
+			}
+		})
+	}
+}
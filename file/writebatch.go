@@ -0,0 +1,297 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+)
+
+// Reserved files used by WriteBatch. The redo file holds the new block
+// images of a batch; the redo table maps each block of a committed batch
+// to its slot in the redo file.
+const (
+	redoFile        = "redo.dat"
+	redoTableFile   = "redotable.dat"
+	redoTableNewTmp = "redotable.new"
+)
+
+// ErrBatchNotCommitted is wrapped by the error of a WriteBatch that failed
+// before its commit point, in which case none of its pages is written.
+// After the commit point a failed batch is still applied, by the next
+// WriteBatch or NewFileMgr.
+var ErrBatchNotCommitted = errors.New("batch not committed")
+
+// redoEntry maps a block to the slot of the redo file holding its new image.
+type redoEntry struct {
+	blk  BlockId
+	slot int
+}
+
+// WriteBatch writes a group of pages atomically: after a crash either every
+// new image is visible or none is. The images are first written to the redo
+// file, then a table of their slots is swapped in with a rename, which is
+// the commit point. Only then are the images copied to their home blocks;
+// if that is interrupted, NewFileMgr finishes it.
+//
+// This is redo logging through a side file, not shadow paging: the table
+// only lives until the batch is applied, so every block of a batch is
+// written twice. In exchange blocks never move, and Read needs no table
+// lookup and sees no fragmentation.
+func (fm *FileMgr) WriteBatch(pages map[BlockId]*Page) error {
+	if len(pages) == 0 {
+		return nil
+	}
+	fm.batchMu.Lock()
+	defer fm.batchMu.Unlock()
+	entries, err := fm.commitBatch(pages)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBatchNotCommitted, err)
+	}
+	if err := fm.syncDir(); err != nil {
+		return err
+	}
+	return fm.applyRedo(entries)
+}
+
+// commitBatch writes the redo images and the redo table of a batch,
+// returning the table's entries. The caller must hold fm.batchMu.
+func (fm *FileMgr) commitBatch(pages map[BlockId]*Page) ([]redoEntry, error) {
+	// A committed batch that failed to apply still needs the redo slots
+	// this batch is about to overwrite, so finish it first.
+	if err := fm.applyCommittedBatch(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]redoEntry, 0, len(pages))
+	for blk, p := range pages {
+		if len(p.buf) != fm.blocksize {
+			return nil, errors.New("WriteBatch: page size != blocksize")
+		}
+		if err := checkName("WriteBatch", blk.FileName()); err != nil {
+			return nil, err
+		}
+		entries = append(entries, redoEntry{blk: blk})
+	}
+	slices.SortFunc(entries, func(a, b redoEntry) int {
+		if c := strings.Compare(a.blk.FileName(), b.blk.FileName()); c != 0 {
+			return c
+		}
+		return a.blk.Number() - b.blk.Number()
+	})
+
+	// The batch bypasses the double-write area, so no older record of its
+	// blocks may survive to be restored over it.
+	if err := fm.dropDoubleWrites(func(blk BlockId) bool { return pages[blk] != nil }); err != nil {
+		return nil, err
+	}
+
+	// Write the redo images.
+	err := fm.withFile(redoFile, func(sf StorageFile) error {
+		for i := range entries {
+			entries[i].slot = i
+			img, err := fm.seal(entries[i].blk, pages[entries[i].blk].buf)
+			if err != nil {
+				return err
+			}
+			if _, err := sf.WriteAt(img, int64(i*fm.diskBlockSize)); err != nil {
+				return err
+			}
+		}
+		return fm.flush(sf)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit by swapping in the new redo table.
+	if err := fm.writeRedoTable(entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// recoverRedo finishes applying a batch whose redo table was committed
+// before a crash, and discards an uncommitted redo table.
+func (fm *FileMgr) recoverRedo() error {
+	_ = fm.storage.Remove(redoTableNewTmp)
+	fm.batchMu.Lock()
+	defer fm.batchMu.Unlock()
+	return fm.applyCommittedBatch()
+}
+
+// applyCommittedBatch applies the batch of the committed redo table,
+// if there is one. The caller must hold fm.batchMu.
+func (fm *FileMgr) applyCommittedBatch() error {
+	b, err := fm.readFile(redoTableFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	entries, err := decodeRedoTable(b)
+	if err != nil {
+		return err
+	}
+	return fm.applyRedo(entries)
+}
+
+// applyRedo copies each redo image to its home block, syncs the
+// affected files and then retires the redo table. Each file is updated
+// under its own lock, so readers see either none or all of a batch's
+// blocks of a file. The caller must hold fm.batchMu.
+func (fm *FileMgr) applyRedo(entries []redoEntry) error {
+	for len(entries) > 0 {
+		name := entries[0].blk.FileName()
+		n := 1
+		for n < len(entries) && entries[n].blk.FileName() == name {
+			n++
+		}
+		if err := fm.applyRedoFile(name, entries[:n]); err != nil {
+			return err
+		}
+		entries = entries[n:]
+	}
+	if err := fm.storage.Remove(redoTableFile); err != nil {
+		return err
+	}
+	return fm.syncDir()
+}
+
+// applyRedoFile copies the redo images of entries, which all belong to
+// one file, to their home blocks and syncs the file.
+func (fm *FileMgr) applyRedoFile(filename string, entries []redoEntry) error {
+	defer fm.lockFile(filename)()
+	buf := fm.newImage()
+	return fm.withFile(filename, func(f StorageFile) error {
+		for _, e := range entries {
+			err := fm.withFile(redoFile, func(sf StorageFile) error {
+				_, err := sf.ReadAt(buf, int64(e.slot*fm.diskBlockSize))
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if err := fm.writeImage(f, e.blk, buf); err != nil {
+				return err
+			}
+			switch {
+			case fm.cache == nil:
+			case fm.enc != nil:
+				// The image is encrypted; the block is read back on next use.
+				fm.cache.remove(e.blk)
+			default:
+				fm.cache.update(e.blk, buf[:fm.blocksize])
+			}
+		}
+		return fm.flushBlocks(filename, f)
+	})
+}
+
+// writeRedoTable replaces the redo table with one listing entries. The
+// rename is made durable by the caller.
+func (fm *FileMgr) writeRedoTable(entries []redoEntry) error {
+	f, err := fm.storage.Open(redoTableNewTmp)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteAt(encodeRedoTable(entries), 0); err != nil {
+		f.Close()
+		return err
+	}
+	if err := fm.flush(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return fm.storage.Rename(redoTableNewTmp, redoTableFile)
+}
+
+// syncDir makes renames and removals durable.
+func (fm *FileMgr) syncDir() error {
+	return fm.storage.SyncDir()
+}
+
+// readFile returns the contents of a file without caching its handle. It
+// returns an error matching fs.ErrNotExist if there is no such file.
+func (fm *FileMgr) readFile(name string) ([]byte, error) {
+	names, err := fm.storage.List()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(names, name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := fm.storage.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size, err := f.Size()
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, size)
+	if _, err := f.ReadAt(b, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return b, nil
+}
+
+// encodeRedoTable lays out the redo table as an entry count followed by
+// (filename, block number, slot) for each entry.
+func encodeRedoTable(entries []redoEntry) []byte {
+	size := 4
+	for _, e := range entries {
+		size += MaxLength(len(e.blk.FileName())) + 8
+	}
+	p := NewPage(size)
+	p.SetInt(0, len(entries))
+	pos := 4
+	for _, e := range entries {
+		p.SetString(pos, e.blk.FileName())
+		pos += MaxLength(len(e.blk.FileName()))
+		p.SetInt(pos, e.blk.Number())
+		p.SetInt(pos+4, e.slot)
+		pos += 8
+	}
+	return p.buf
+}
+
+// decodeRedoTable parses a redo table written by encodeRedoTable.
+func decodeRedoTable(b []byte) ([]redoEntry, error) {
+	p := NewPageFromBytes(b)
+	n, err := p.GetInt(0)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]redoEntry, 0, n)
+	pos := 4
+	for range n {
+		filename, err := p.GetString(pos)
+		if err != nil {
+			return nil, err
+		}
+		pos += MaxLength(len(filename))
+		blknum, err := p.GetInt(pos)
+		if err != nil {
+			return nil, err
+		}
+		slot, err := p.GetInt(pos + 4)
+		if err != nil {
+			return nil, err
+		}
+		pos += 8
+		entries = append(entries, redoEntry{blk: NewBlockId(filename, blknum), slot: slot})
+	}
+	return entries, nil
+}
@@ -0,0 +1,199 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMgr_WriteBatch(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			blocks []BlockId
+		}
+	)
+
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "empty batch",
+			args: args{blocks: nil},
+		},
+		{
+			name: "single block",
+			args: args{blocks: []BlockId{NewBlockId("a.db", 0)}},
+		},
+		{
+			name: "several files",
+			args: args{blocks: []BlockId{
+				NewBlockId("a.db", 0),
+				NewBlockId("a.db", 3),
+				NewBlockId("b.db", 1),
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_writebatch_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			blocksize := 512
+			fm, err := NewFileMgr(testDir, blocksize)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+
+			pages := make(map[BlockId]*Page)
+			for i, blk := range tt.args.blocks {
+				p := NewPage(blocksize)
+				p.SetInt(0, 100+i)
+				pages[blk] = p
+			}
+			if err := fm.WriteBatch(pages); err != nil {
+				t.Fatalf("WriteBatch() error = %v", err)
+			}
+
+			for i, blk := range tt.args.blocks {
+				p := NewPage(blocksize)
+				if err := fm.Read(blk, p); err != nil {
+					t.Fatalf("Read(%v) failed: %v", blk, err)
+				}
+				if got, _ := p.GetInt(0); got != 100+i {
+					t.Errorf("Read(%v) = %v, want %v", blk, got, 100+i)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(testDir, redoTableFile)); !os.IsNotExist(err) {
+				t.Errorf("redo table still present after WriteBatch(): %v", err)
+			}
+		})
+	}
+}
+
+func TestFileMgr_WriteBatch_WrongPageSize(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_writebatch_pagesize")
+	defer os.RemoveAll(testDir)
+
+	fm, err := NewFileMgr(testDir, 512)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	pages := map[BlockId]*Page{
+		NewBlockId("a.db", 0): NewPage(512),
+		NewBlockId("a.db", 1): NewPage(256),
+	}
+	if err := fm.WriteBatch(pages); err == nil {
+		t.Errorf("WriteBatch() error = nil, want error for wrong page size")
+	}
+	if n, _ := fm.Length("a.db"); n != 0 {
+		t.Errorf("Length() after failed batch = %v, want 0", n)
+	}
+}
+
+func TestFileMgr_WriteBatch_Recovery(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			committed bool
+		}
+		wants struct {
+			value int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "crash after commit applies batch",
+			args:  args{committed: true},
+			wants: wants{value: 2},
+		},
+		{
+			name:  "crash before commit discards batch",
+			args:  args{committed: false},
+			wants: wants{value: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_writebatch_recovery_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			blocksize := 512
+			fm, err := NewFileMgr(testDir, blocksize)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			blks := []BlockId{NewBlockId("a.db", 0), NewBlockId("b.db", 0)}
+			for _, blk := range blks {
+				p := NewPage(blocksize)
+				p.SetInt(0, 1)
+				if err := fm.Write(blk, p); err != nil {
+					t.Fatalf("Write() failed: %v", err)
+				}
+			}
+
+			// Simulate a crash after the redo images were written but
+			// before any image was copied home.
+			var entries []redoEntry
+			err = fm.withFile(redoFile, func(sf StorageFile) error {
+				for i, blk := range blks {
+					p := NewPage(blocksize)
+					p.SetInt(0, 2)
+					if _, err := sf.WriteAt(p.buf, int64(i*blocksize)); err != nil {
+						return err
+					}
+					entries = append(entries, redoEntry{blk: blk, slot: i})
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("writing redo images failed: %v", err)
+			}
+			if tt.args.committed {
+				if err := fm.writeRedoTable(entries); err != nil {
+					t.Fatalf("writeRedoTable() failed: %v", err)
+				}
+			} else {
+				tmp := filepath.Join(testDir, redoTableNewTmp)
+				if err := os.WriteFile(tmp, encodeRedoTable(entries), 0o644); err != nil {
+					t.Fatalf("WriteFile() failed: %v", err)
+				}
+			}
+
+			fm2, err := NewFileMgr(testDir, blocksize)
+			if err != nil {
+				t.Fatalf("NewFileMgr() after crash error = %v", err)
+			}
+			for _, blk := range blks {
+				p := NewPage(blocksize)
+				if err := fm2.Read(blk, p); err != nil {
+					t.Fatalf("Read() failed: %v", err)
+				}
+				if got, _ := p.GetInt(0); got != tt.wants.value {
+					t.Errorf("Read(%v) after restart = %v, want %v", blk, got, tt.wants.value)
+				}
+			}
+			for _, name := range []string{redoTableFile, redoTableNewTmp} {
+				if _, err := os.Stat(filepath.Join(testDir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s still present after restart: %v", name, err)
+				}
+			}
+		})
+	}
+}
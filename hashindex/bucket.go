@@ -0,0 +1,114 @@
+package hashindex
+
+import (
+	"bytes"
+	"fmt"
+
+	"simpledb-in-golang/file"
+)
+
+// bucketHeaderSize is the size of the count, next and depth fields that
+// start every bucket block.
+const bucketHeaderSize = 12
+
+// bucket is the decoded contents of a bucket block: a list of key/value
+// pairs, the next block of the bucket's overflow chain (-1 at the end),
+// and, for extendible hashing, the local depth of the bucket.
+type bucket struct {
+	blknum int
+	next   int
+	depth  int
+	keys   [][]byte
+	vals   [][]byte
+}
+
+// entrySize returns the encoded size of a key/value pair.
+func entrySize(key, val []byte) int {
+	return file.MaxLength(len(key)) + file.MaxLength(len(val))
+}
+
+// size returns the number of bytes needed to encode the bucket.
+func (b *bucket) size() int {
+	sz := bucketHeaderSize
+	for i := range b.keys {
+		sz += entrySize(b.keys[i], b.vals[i])
+	}
+	return sz
+}
+
+// remove deletes the first pair equal to key/val and reports whether there was one.
+func (b *bucket) remove(key, val []byte) bool {
+	for i := range b.keys {
+		if bytes.Equal(b.keys[i], key) && bytes.Equal(b.vals[i], val) {
+			b.keys = append(b.keys[:i], b.keys[i+1:]...)
+			b.vals = append(b.vals[:i], b.vals[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Number returns the block number of the bucket.
+func (b *bucket) Number() int { return b.blknum }
+
+// Encode writes the bucket into p.
+func (b *bucket) Encode(p *file.Page) error {
+	clear(p.Buffer())
+	if err := p.SetInt(0, len(b.keys)); err != nil {
+		return err
+	}
+	if err := p.SetInt(4, b.next); err != nil {
+		return err
+	}
+	if err := p.SetInt(8, b.depth); err != nil {
+		return err
+	}
+	pos := bucketHeaderSize
+	for i := range b.keys {
+		if err := p.SetBytes(pos, b.keys[i]); err != nil {
+			return err
+		}
+		pos += file.MaxLength(len(b.keys[i]))
+		if err := p.SetBytes(pos, b.vals[i]); err != nil {
+			return err
+		}
+		pos += file.MaxLength(len(b.vals[i]))
+	}
+	return nil
+}
+
+// decodeBucket reads the bucket stored in p.
+func decodeBucket(p *file.Page, blknum int) (*bucket, error) {
+	count, err := p.GetInt(0)
+	if err != nil {
+		return nil, err
+	}
+	next, err := p.GetInt(4)
+	if err != nil {
+		return nil, err
+	}
+	depth, err := p.GetInt(8)
+	if err != nil {
+		return nil, err
+	}
+	if count > (len(p.Buffer())-bucketHeaderSize)/8 {
+		return nil, fmt.Errorf("block %d is not a hash bucket (count %d)", blknum, count)
+	}
+	b := &bucket{blknum: blknum, next: int(int32(next)), depth: depth}
+	pos := bucketHeaderSize
+	for range count {
+		k, err := p.GetBytes(pos)
+		if err != nil {
+			return nil, err
+		}
+		pos += file.MaxLength(len(k))
+		v, err := p.GetBytes(pos)
+		if err != nil {
+			return nil, err
+		}
+		pos += file.MaxLength(len(v))
+		b.keys = append(b.keys, k)
+		b.vals = append(b.vals, v)
+	}
+	return b, nil
+}
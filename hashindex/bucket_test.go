@@ -0,0 +1,68 @@
+package hashindex
+
+import (
+	"bytes"
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+func TestBucket_EncodeDecode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		b    *bucket
+	}{
+		{"empty", &bucket{blknum: 1, next: -1}},
+		{"entries", &bucket{
+			blknum: 2,
+			next:   7,
+			depth:  3,
+			keys:   [][]byte{[]byte("k1"), []byte("k2"), {}},
+			vals:   [][]byte{[]byte("v1"), {}, []byte("v3")},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := file.NewPage(100)
+			if err := tt.b.Encode(p); err != nil {
+				t.Fatalf("encode() error = %v", err)
+			}
+			got, err := decodeBucket(p, tt.b.blknum)
+			if err != nil {
+				t.Fatalf("decodeBucket() error = %v", err)
+			}
+			if got.next != tt.b.next || got.depth != tt.b.depth || len(got.keys) != len(tt.b.keys) {
+				t.Fatalf("decodeBucket() = %+v, want %+v", got, tt.b)
+			}
+			for i := range got.keys {
+				if !bytes.Equal(got.keys[i], tt.b.keys[i]) || !bytes.Equal(got.vals[i], tt.b.vals[i]) {
+					t.Errorf("entry %d = %q/%q, want %q/%q", i, got.keys[i], got.vals[i], tt.b.keys[i], tt.b.vals[i])
+				}
+			}
+			if got.size() != tt.b.size() {
+				t.Errorf("size() = %d, want %d", got.size(), tt.b.size())
+			}
+		})
+	}
+}
+
+func TestBucket_Remove(t *testing.T) {
+	t.Parallel()
+
+	b := &bucket{
+		keys: [][]byte{[]byte("a"), []byte("a"), []byte("b")},
+		vals: [][]byte{[]byte("1"), []byte("2"), []byte("1")},
+	}
+	if b.remove([]byte("a"), []byte("3")) {
+		t.Errorf("remove(absent value) = true, want false")
+	}
+	if !b.remove([]byte("a"), []byte("2")) {
+		t.Fatalf("remove(a, 2) = false, want true")
+	}
+	if len(b.keys) != 2 || string(b.vals[0]) != "1" || string(b.keys[1]) != "b" {
+		t.Errorf("after remove: keys %q vals %q", b.keys, b.vals)
+	}
+}
@@ -0,0 +1,244 @@
+package hashindex
+
+import (
+	"errors"
+	"sync"
+
+	"simpledb-in-golang/file"
+)
+
+// dirSuffix names the directory file kept alongside an extendible hash
+// index. It holds 2^depth bucket block numbers, packed bs/4 to a block.
+const dirSuffix = ".dir"
+
+// maxDepth bounds the global depth, and so the directory size. A bucket
+// that cannot split further, or whose entries all share a hash, grows an
+// overflow chain instead; the chain is redistributed when the bucket
+// next splits.
+const maxDepth = 16
+
+// ExtendibleHash is a hash index whose bucket for a key is found through
+// a directory indexed by the low depth bits of the key's hash. A full
+// bucket splits in two, doubling the directory when the bucket is already
+// as deep as the directory.
+type ExtendibleHash struct {
+	fm       *file.FileMgr
+	filename string
+
+	mu    sync.Mutex
+	depth int
+	dir   []int
+}
+
+var _ Index = (*ExtendibleHash)(nil)
+
+// OpenExtendible opens the extendible hash index stored in filename,
+// creating an index with a single bucket if the file is empty.
+func OpenExtendible(fm *file.FileMgr, filename string) (_ *ExtendibleHash, err error) {
+	h := &ExtendibleHash{fm: fm, filename: filename}
+	n, err := fm.Length(filename)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		s := newSession(fm, filename)
+		defer func() { err = errors.Join(err, s.Abort()) }()
+		if _, err := fm.Append(filename); err != nil {
+			return nil, err
+		}
+		b, err := s.alloc(0)
+		if err != nil {
+			return nil, err
+		}
+		h.dir = []int{b.blknum}
+		h.writeDir(s, 0, 0)
+		return h, s.Commit()
+	}
+	if h.depth, err = readMeta(fm, filename, extendibleKind); err != nil {
+		return nil, err
+	}
+	perBlock := fm.BlockSize() / 4
+	p := file.NewPage(fm.BlockSize())
+	h.dir = make([]int, 1<<h.depth)
+	for i := range h.dir {
+		if i%perBlock == 0 {
+			if err := fm.Read(file.NewBlockId(filename+dirSuffix, i/perBlock), p); err != nil {
+				return nil, err
+			}
+		}
+		h.dir[i], _ = p.GetInt(i % perBlock * 4)
+	}
+	return h, nil
+}
+
+// Depth returns the global depth of the directory.
+func (h *ExtendibleHash) Depth() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.depth
+}
+
+// bucketOf returns the primary bucket block of key.
+func (h *ExtendibleHash) bucketOf(key []byte) int {
+	return h.dir[hashKey(key)&(1<<h.depth-1)]
+}
+
+// Insert adds val to the values of key, splitting the key's bucket and
+// doubling the directory as needed.
+func (h *ExtendibleHash) Insert(key, val []byte) (err error) {
+	if err := checkEntry(h.fm, key, val); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := newSession(h.fm, h.filename)
+	defer func() { err = errors.Join(err, s.Abort()) }()
+	dir, depth := h.dir, h.depth
+	lo, hi := len(h.dir), 0
+	for {
+		head := h.bucketOf(key)
+		ok, err := s.insertChain(head, key, val)
+		if err != nil {
+			h.dir, h.depth = dir, depth
+			return err
+		}
+		if ok {
+			break
+		}
+		chain, err := s.chain(head)
+		if err != nil {
+			h.dir, h.depth = dir, depth
+			return err
+		}
+		if chain[0].depth >= maxDepth || !splittable(chain, key) {
+			if err := s.addOverflow(chain[0], key, val); err != nil {
+				h.dir, h.depth = dir, depth
+				return err
+			}
+			break
+		}
+		first, last, err := h.split(s, chain)
+		if err != nil {
+			h.dir, h.depth = dir, depth
+			return err
+		}
+		lo, hi = min(lo, first), max(hi, last)
+	}
+	if lo <= hi {
+		h.writeDir(s, lo, hi)
+	}
+	if err := s.Commit(); err != nil {
+		h.dir, h.depth = dir, depth
+		return err
+	}
+	return nil
+}
+
+// splittable reports whether splitting the bucket whose blocks are chain
+// can separate its entries and key, which it cannot when they all share
+// the same hash.
+func splittable(chain []*bucket, key []byte) bool {
+	hk := hashKey(key)
+	for _, b := range chain {
+		for _, k := range b.keys {
+			if hashKey(k) != hk {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// split moves the entries of the bucket whose blocks are chain and whose
+// next hash bit is set to a new bucket, doubling the directory first if
+// the bucket is as deep as it. The overflow blocks are freed and the
+// entries redistributed, each half chaining again only if it must. It
+// returns the range of directory slots that changed.
+func (h *ExtendibleHash) split(s *session, chain []*bucket) (int, int, error) {
+	b := chain[0]
+	nb, err := s.alloc(b.depth + 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	first, last := len(h.dir), 0
+	if b.depth == h.depth {
+		// Copy rather than append, so that a failed insert can restore
+		// the old directory.
+		dir := make([]int, 2*len(h.dir))
+		copy(dir, h.dir)
+		copy(dir[len(h.dir):], h.dir)
+		first, last = len(h.dir), len(dir)-1
+		h.dir = dir
+		h.depth++
+	} else {
+		h.dir = append([]int(nil), h.dir...)
+	}
+	bit := uint32(1) << b.depth
+	b.depth++
+	s.Touch(b)
+
+	var keys, vals [][]byte
+	for _, o := range chain {
+		keys = append(keys, o.keys...)
+		vals = append(vals, o.vals...)
+		if o != b {
+			s.Free(o)
+		}
+	}
+	b.keys, b.vals, b.next = nil, nil, -1
+	for i := range keys {
+		dst := b
+		if hashKey(keys[i])&bit != 0 {
+			dst = nb
+		}
+		ok, err := s.insertChain(dst.blknum, keys[i], vals[i])
+		if err == nil && !ok {
+			err = s.addOverflow(dst, keys[i], vals[i])
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	for i := range h.dir {
+		if h.dir[i] == b.blknum && uint32(i)&bit != 0 {
+			h.dir[i] = nb.blknum
+			first, last = min(first, i), max(last, i)
+		}
+	}
+	return first, last, nil
+}
+
+// writeDir adds the meta block and the directory blocks holding slots
+// lo..hi to the session.
+func (h *ExtendibleHash) writeDir(s *session, lo, hi int) {
+	bs := h.fm.BlockSize()
+	perBlock := bs / 4
+	s.SetPage(file.NewBlockId(h.filename, 0), metaPage(bs, extendibleKind, h.depth))
+	for blk := lo / perBlock; blk <= hi/perBlock; blk++ {
+		p := file.NewPage(bs)
+		for i := blk * perBlock; i < min((blk+1)*perBlock, len(h.dir)); i++ {
+			_ = p.SetInt(i%perBlock*4, h.dir[i])
+		}
+		s.SetPage(file.NewBlockId(h.filename+dirSuffix, blk), p)
+	}
+}
+
+// Lookup returns an iterator over the values of key.
+func (h *ExtendibleHash) Lookup(key []byte) (*Iterator, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return newIterator(h.fm, h.filename, &h.mu, key, h.bucketOf(key)), nil
+}
+
+// Delete removes one occurrence of val from the values of key and reports
+// whether it was present. Buckets are not merged when they empty.
+func (h *ExtendibleHash) Delete(key, val []byte) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := newSession(h.fm, h.filename)
+	found, err := s.deleteChain(h.bucketOf(key), key, val)
+	if err != nil || !found {
+		return false, err
+	}
+	return true, s.Commit()
+}
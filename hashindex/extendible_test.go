@@ -0,0 +1,226 @@
+package hashindex
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/file/filetest"
+)
+
+func TestExtendibleHash_SplitAndDouble(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_hash_extendible")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 128)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	h, err := OpenExtendible(fm, "e.idx")
+	if err != nil {
+		t.Fatalf("OpenExtendible() error = %v", err)
+	}
+	if h.Depth() != 0 {
+		t.Errorf("Depth() of new index = %d, want 0", h.Depth())
+	}
+
+	const n = 500
+	for i := range n {
+		if err := h.Insert([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Insert(%d) error = %v", i, err)
+		}
+	}
+	if h.Depth() < 4 {
+		t.Errorf("Depth() after %d inserts = %d, want the directory to have doubled", n, h.Depth())
+	}
+	// Splits, not overflow chains, absorb distinct keys.
+	for _, blknum := range h.dir {
+		b, err := readBucket(fm, "e.idx", blknum)
+		if err != nil {
+			t.Fatalf("readBucket() error = %v", err)
+		}
+		if b.next >= 0 {
+			t.Errorf("bucket %d has an overflow chain", blknum)
+		}
+		if b.depth > h.depth {
+			t.Errorf("bucket %d local depth %d > global depth %d", blknum, b.depth, h.depth)
+		}
+	}
+
+	// Reopen and read everything back through the persisted directory.
+	h2, err := OpenExtendible(fm, "e.idx")
+	if err != nil {
+		t.Fatalf("OpenExtendible() reopen error = %v", err)
+	}
+	if h2.Depth() != h.Depth() || !slices.Equal(h2.dir, h.dir) {
+		t.Errorf("reopened directory differs: depth %d vs %d", h2.Depth(), h.Depth())
+	}
+	for i := range n {
+		got := collect(t, h2, fmt.Sprintf("key%d", i))
+		if !slices.Equal(got, []string{fmt.Sprint(i)}) {
+			t.Fatalf("Lookup(key%d) = %q, want [%d]", i, got, i)
+		}
+	}
+
+	for i := 0; i < n; i += 2 {
+		if ok, err := h2.Delete([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprint(i))); err != nil || !ok {
+			t.Fatalf("Delete(key%d) = %v, %v, want true, nil", i, ok, err)
+		}
+	}
+	if got := collect(t, h2, "key10"); got != nil {
+		t.Errorf("Lookup(deleted) = %q, want none", got)
+	}
+	if got := collect(t, h2, "key11"); !slices.Equal(got, []string{"11"}) {
+		t.Errorf("Lookup(key11) = %q, want [11]", got)
+	}
+}
+
+func TestExtendibleHash_Duplicates(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_hash_extendible_dup")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 128)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	h, err := OpenExtendible(fm, "e.idx")
+	if err != nil {
+		t.Fatalf("OpenExtendible() error = %v", err)
+	}
+
+	// Values of a single key cannot be split apart; they must chain
+	// without doubling the directory.
+	var want []string
+	for i := range 100 {
+		v := fmt.Sprintf("v%03d", i)
+		want = append(want, v)
+		if err := h.Insert([]byte("same"), []byte(v)); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+	if h.Depth() != 0 {
+		t.Errorf("Depth() = %d, want 0", h.Depth())
+	}
+	if got := collect(t, h, "same"); !slices.Equal(got, want) {
+		t.Errorf("Lookup(same) returned %d values, want %d", len(got), len(want))
+	}
+	for _, v := range want {
+		if ok, err := h.Delete([]byte("same"), []byte(v)); err != nil || !ok {
+			t.Fatalf("Delete(%s) = %v, %v", v, ok, err)
+		}
+	}
+	b, err := readBucket(fm, "e.idx", h.dir[0])
+	if err != nil {
+		t.Fatalf("readBucket() error = %v", err)
+	}
+	if b.next >= 0 || len(b.keys) != 0 {
+		t.Errorf("bucket after deleting all = %+v, want empty without chain", b)
+	}
+}
+
+func TestExtendibleHash_SplitChainedBucket(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_hash_extendible_chain")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 128)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	h, err := OpenExtendible(fm, "e.idx")
+	if err != nil {
+		t.Fatalf("OpenExtendible() error = %v", err)
+	}
+
+	// Values of one key give the only bucket an overflow chain, which
+	// must not stop it from splitting once distinct keys arrive.
+	for i := range 30 {
+		if err := h.Insert([]byte("same"), []byte(fmt.Sprintf("v%03d", i))); err != nil {
+			t.Fatalf("Insert(same) error = %v", err)
+		}
+	}
+	const n = 200
+	for i := range n {
+		if err := h.Insert([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Insert(%d) error = %v", i, err)
+		}
+	}
+	if h.Depth() == 0 {
+		t.Fatalf("Depth() = 0, want the chained bucket to have split")
+	}
+	same := h.bucketOf([]byte("same"))
+	for _, blknum := range h.dir {
+		b, err := readBucket(fm, "e.idx", blknum)
+		if err != nil {
+			t.Fatalf("readBucket() error = %v", err)
+		}
+		if b.next >= 0 && blknum != same {
+			t.Errorf("bucket %d without the duplicated key has an overflow chain", blknum)
+		}
+	}
+	if got := collect(t, h, "same"); len(got) != 30 {
+		t.Errorf("Lookup(same) returned %d values, want 30", len(got))
+	}
+	for i := range n {
+		got := collect(t, h, fmt.Sprintf("key%d", i))
+		if !slices.Equal(got, []string{fmt.Sprint(i)}) {
+			t.Fatalf("Lookup(key%d) = %q, want [%d]", i, got, i)
+		}
+	}
+}
+
+func TestExtendibleHash_FailedInsertFreesBlocks(t *testing.T) {
+	t.Parallel()
+
+	insertAll := func(t *testing.T, failFirst bool) *file.FileMgr {
+		s := filetest.NewFaultStorage(1)
+		fm, err := file.NewFileMgr("", 128, file.WithStorage(s))
+		if err != nil {
+			t.Fatalf("NewFileMgr() failed: %v", err)
+		}
+		h, err := OpenExtendible(fm, "e.idx")
+		if err != nil {
+			t.Fatalf("OpenExtendible() error = %v", err)
+		}
+		for i := range 200 {
+			key, val := []byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprint(i))
+			if failFirst {
+				// Fail the batch before it commits, after any splits
+				// have allocated their buckets.
+				s.Inject(filetest.Fault{Op: filetest.OpWriteAt, Name: "redo.dat", N: 1, Err: syscall.EIO})
+				if err := h.Insert(key, val); !errors.Is(err, syscall.EIO) {
+					t.Fatalf("Insert(%d) with failing batch error = %v, want EIO", i, err)
+				}
+				s.ClearFaults()
+			}
+			if err := h.Insert(key, val); err != nil {
+				t.Fatalf("Insert(%d) error = %v", i, err)
+			}
+		}
+		for i := range 200 {
+			if got := collect(t, h, fmt.Sprintf("key%d", i)); !slices.Equal(got, []string{fmt.Sprint(i)}) {
+				t.Fatalf("Lookup(key%d) = %q, want [%d]", i, got, i)
+			}
+		}
+		return fm
+	}
+
+	fm := insertAll(t, false)
+	defer fm.Close()
+	failed := insertAll(t, true)
+	defer failed.Close()
+	want, _ := fm.Length("e.idx")
+	if got, _ := failed.Length("e.idx"); got != want {
+		t.Errorf("file has %d blocks after failed inserts, want %d as without them", got, want)
+	}
+}
@@ -0,0 +1,178 @@
+// Package hashindex implements equality indexes whose buckets are blocks
+// of a single file managed by file.FileMgr: a static hash with a fixed
+// number of buckets, and extendible hashing whose directory doubles as
+// buckets split.
+package hashindex
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"simpledb-in-golang/batch"
+	"simpledb-in-golang/file"
+)
+
+// Index is an equality index mapping byte-slice keys to any number of
+// byte-slice values.
+type Index interface {
+	// Insert adds val to the values of key.
+	Insert(key, val []byte) error
+	// Lookup returns an iterator over the values of key.
+	Lookup(key []byte) (*Iterator, error)
+	// Delete removes one occurrence of val from the values of key and
+	// reports whether it was present.
+	Delete(key, val []byte) (bool, error)
+}
+
+// metaMagic identifies block 0 of an index file.
+const metaMagic = 0x48736878 // "Hshx"
+
+// Index kinds, stored in block 0 after the magic number.
+const (
+	staticKind = iota + 1
+	extendibleKind
+)
+
+// hashKey returns the hash of a key.
+func hashKey(key []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(key)
+	return h.Sum32()
+}
+
+// checkEntry returns an error if a key/value pair does not fit in a bucket.
+func checkEntry(fm *file.FileMgr, key, val []byte) error {
+	if entrySize(key, val) > fm.BlockSize()-bucketHeaderSize {
+		return fmt.Errorf("hashindex: entry of %d bytes is too large", len(key)+len(val))
+	}
+	return nil
+}
+
+// readMeta reads block 0 of an index file, checking that it holds an
+// index of the given kind, and returns the kind-specific parameter.
+func readMeta(fm *file.FileMgr, filename string, kind int) (int, error) {
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(file.NewBlockId(filename, 0), p); err != nil {
+		return 0, err
+	}
+	magic, _ := p.GetInt(0)
+	k, _ := p.GetInt(4)
+	param, _ := p.GetInt(8)
+	if magic != metaMagic {
+		return 0, fmt.Errorf("hashindex: %s is not a hash index file", filename)
+	}
+	if k != kind {
+		return 0, fmt.Errorf("hashindex: %s holds an index of kind %d, not %d", filename, k, kind)
+	}
+	return param, nil
+}
+
+// metaPage returns the contents of block 0 of an index file.
+func metaPage(blocksize, kind, param int) *file.Page {
+	p := file.NewPage(blocksize)
+	_ = p.SetInt(0, metaMagic)
+	_ = p.SetInt(4, kind)
+	_ = p.SetInt(8, param)
+	return p
+}
+
+// session collects the blocks changed by a single operation, so that they
+// can be written in one atomic batch.
+type session struct {
+	*batch.Batch[*bucket]
+	fm       *file.FileMgr
+	filename string
+}
+
+func newSession(fm *file.FileMgr, filename string) *session {
+	read := func(blknum int) (*bucket, error) { return readBucket(fm, filename, blknum) }
+	return &session{Batch: batch.New(fm, filename, read), fm: fm, filename: filename}
+}
+
+// alloc returns a new empty bucket.
+func (s *session) alloc(depth int) (*bucket, error) {
+	return s.Alloc(func(blknum int) *bucket { return &bucket{blknum: blknum, next: -1, depth: depth} })
+}
+
+// insertChain adds the pair to the first block of the chain starting at
+// head with room for it, and reports whether there was one.
+func (s *session) insertChain(head int, key, val []byte) (bool, error) {
+	for blknum := head; blknum >= 0; {
+		b, err := s.Get(blknum)
+		if err != nil {
+			return false, err
+		}
+		if b.size()+entrySize(key, val) <= s.fm.BlockSize() {
+			b.keys = append(b.keys, key)
+			b.vals = append(b.vals, val)
+			s.Touch(b)
+			return true, nil
+		}
+		blknum = b.next
+	}
+	return false, nil
+}
+
+// chain returns the blocks of the bucket whose primary block is head,
+// primary first.
+func (s *session) chain(head int) ([]*bucket, error) {
+	var chain []*bucket
+	for blknum := head; blknum >= 0; {
+		b, err := s.Get(blknum)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, b)
+		blknum = b.next
+	}
+	return chain, nil
+}
+
+// addOverflow links a new overflow block holding the pair right after the
+// primary bucket.
+func (s *session) addOverflow(primary *bucket, key, val []byte) error {
+	o, err := s.alloc(primary.depth)
+	if err != nil {
+		return err
+	}
+	o.keys = [][]byte{key}
+	o.vals = [][]byte{val}
+	o.next = primary.next
+	primary.next = o.blknum
+	s.Touch(primary)
+	return nil
+}
+
+// deleteChain removes one occurrence of the pair from the chain starting
+// at the primary bucket head, releasing any overflow block left empty.
+func (s *session) deleteChain(head int, key, val []byte) (bool, error) {
+	var prev *bucket
+	for blknum := head; blknum >= 0; {
+		b, err := s.Get(blknum)
+		if err != nil {
+			return false, err
+		}
+		if !b.remove(key, val) {
+			prev, blknum = b, b.next
+			continue
+		}
+		if prev != nil && len(b.keys) == 0 {
+			prev.next = b.next
+			s.Touch(prev)
+			s.Free(b)
+		} else {
+			s.Touch(b)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// readBucket reads and decodes the bucket stored in block blknum.
+func readBucket(fm *file.FileMgr, filename string, blknum int) (*bucket, error) {
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(file.NewBlockId(filename, blknum), p); err != nil {
+		return nil, err
+	}
+	return decodeBucket(p, blknum)
+}
@@ -0,0 +1,54 @@
+package hashindex
+
+import (
+	"bytes"
+	"sync"
+
+	"simpledb-in-golang/file"
+)
+
+// Iterator walks the values of one key, reading the bucket chain one block
+// at a time. The index must not be modified while an iterator is in use.
+type Iterator struct {
+	fm       *file.FileMgr
+	filename string
+	mu       *sync.Mutex
+	key      []byte
+	b        *bucket
+	next     int
+	pos      int
+}
+
+// newIterator returns an iterator over the values of key in the chain
+// starting at head.
+func newIterator(fm *file.FileMgr, filename string, mu *sync.Mutex, key []byte, head int) *Iterator {
+	return &Iterator{fm: fm, filename: filename, mu: mu, key: bytes.Clone(key), next: head}
+}
+
+// Next advances to the next value and reports whether there is one.
+func (it *Iterator) Next() (bool, error) {
+	for {
+		if it.b != nil {
+			for it.pos++; it.pos < len(it.b.keys); it.pos++ {
+				if bytes.Equal(it.b.keys[it.pos], it.key) {
+					return true, nil
+				}
+			}
+			it.next = it.b.next
+			it.b = nil
+		}
+		if it.next < 0 {
+			return false, nil
+		}
+		it.mu.Lock()
+		b, err := readBucket(it.fm, it.filename, it.next)
+		it.mu.Unlock()
+		if err != nil {
+			return false, err
+		}
+		it.b, it.pos = b, -1
+	}
+}
+
+// Value returns the current value.
+func (it *Iterator) Value() []byte { return it.b.vals[it.pos] }
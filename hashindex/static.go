@@ -0,0 +1,102 @@
+package hashindex
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"simpledb-in-golang/file"
+)
+
+// StaticHash is a hash index with a fixed number of buckets. Block 0 of
+// the file holds the bucket count and blocks 1..n the primary buckets;
+// a bucket that fills up grows a chain of overflow blocks.
+type StaticHash struct {
+	fm       *file.FileMgr
+	filename string
+	nbuckets int
+	mu       sync.Mutex
+}
+
+var _ Index = (*StaticHash)(nil)
+
+// OpenStatic opens the static hash index stored in filename, creating it
+// with nbuckets buckets if the file is empty. The bucket count of an
+// existing index is kept.
+func OpenStatic(fm *file.FileMgr, filename string, nbuckets int) (_ *StaticHash, err error) {
+	if nbuckets < 1 {
+		return nil, fmt.Errorf("hashindex: bucket count %d must be positive", nbuckets)
+	}
+	n, err := fm.Length(filename)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		if nbuckets, err = readMeta(fm, filename, staticKind); err != nil {
+			return nil, err
+		}
+		return &StaticHash{fm: fm, filename: filename, nbuckets: nbuckets}, nil
+	}
+	h := &StaticHash{fm: fm, filename: filename, nbuckets: nbuckets}
+	s := newSession(fm, filename)
+	defer func() { err = errors.Join(err, s.Abort()) }()
+	if _, err := fm.Append(filename); err != nil {
+		return nil, err
+	}
+	for range nbuckets {
+		if _, err := s.alloc(0); err != nil {
+			return nil, err
+		}
+	}
+	s.SetPage(file.NewBlockId(filename, 0), metaPage(fm.BlockSize(), staticKind, nbuckets))
+	return h, s.Commit()
+}
+
+// bucketOf returns the primary bucket block of key.
+func (h *StaticHash) bucketOf(key []byte) int {
+	return 1 + int(hashKey(key)%uint32(h.nbuckets))
+}
+
+// Insert adds val to the values of key.
+func (h *StaticHash) Insert(key, val []byte) (err error) {
+	if err := checkEntry(h.fm, key, val); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := newSession(h.fm, h.filename)
+	defer func() { err = errors.Join(err, s.Abort()) }()
+	head := h.bucketOf(key)
+	ok, err := s.insertChain(head, key, val)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		primary, err := s.Get(head)
+		if err != nil {
+			return err
+		}
+		if err := s.addOverflow(primary, key, val); err != nil {
+			return err
+		}
+	}
+	return s.Commit()
+}
+
+// Lookup returns an iterator over the values of key.
+func (h *StaticHash) Lookup(key []byte) (*Iterator, error) {
+	return newIterator(h.fm, h.filename, &h.mu, key, h.bucketOf(key)), nil
+}
+
+// Delete removes one occurrence of val from the values of key and reports
+// whether it was present.
+func (h *StaticHash) Delete(key, val []byte) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := newSession(h.fm, h.filename)
+	found, err := s.deleteChain(h.bucketOf(key), key, val)
+	if err != nil || !found {
+		return false, err
+	}
+	return true, s.Commit()
+}
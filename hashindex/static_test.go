@@ -0,0 +1,150 @@
+package hashindex
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+// collect returns the sorted values of key.
+func collect(t *testing.T, idx Index, key string) []string {
+	t.Helper()
+	it, err := idx.Lookup([]byte(key))
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	var out []string
+	for {
+		ok, err := it.Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if !ok {
+			break
+		}
+		out = append(out, string(it.Value()))
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestOpenStatic(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_hash_openstatic")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 128)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	if _, err := OpenStatic(fm, "s.idx", 0); err == nil {
+		t.Errorf("OpenStatic(0 buckets) error = nil, want error")
+	}
+	h, err := OpenStatic(fm, "s.idx", 5)
+	if err != nil {
+		t.Fatalf("OpenStatic() error = %v", err)
+	}
+	if n, _ := fm.Length("s.idx"); n != 6 {
+		t.Errorf("Length() = %d, want 6 (meta + 5 buckets)", n)
+	}
+	if err := h.Insert([]byte("k"), []byte("v")); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+
+	// The bucket count of an existing index wins.
+	h2, err := OpenStatic(fm, "s.idx", 9)
+	if err != nil {
+		t.Fatalf("OpenStatic() reopen error = %v", err)
+	}
+	if h2.nbuckets != 5 {
+		t.Errorf("reopened nbuckets = %d, want 5", h2.nbuckets)
+	}
+	if got := collect(t, h2, "k"); !slices.Equal(got, []string{"v"}) {
+		t.Errorf("Lookup(k) after reopen = %q, want [v]", got)
+	}
+	if _, err := OpenExtendible(fm, "s.idx"); err == nil {
+		t.Errorf("OpenExtendible(static index) error = nil, want error")
+	}
+}
+
+func TestStaticHash_InsertLookupDelete(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_hash_static")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 128)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	h, err := OpenStatic(fm, "s.idx", 3)
+	if err != nil {
+		t.Fatalf("OpenStatic() error = %v", err)
+	}
+
+	// Far more entries than three buckets hold, so chains must grow.
+	const n = 200
+	for i := range n {
+		key := []byte(fmt.Sprintf("key%d", i%50))
+		if err := h.Insert(key, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+	if blocks, _ := fm.Length("s.idx"); blocks <= 4 {
+		t.Errorf("Length() = %d, want overflow blocks beyond 4", blocks)
+	}
+	want := []string{"107", "157", "57", "7"}
+	if got := collect(t, h, "key7"); !slices.Equal(got, want) {
+		t.Errorf("Lookup(key7) = %q, want %q", got, want)
+	}
+	if got := collect(t, h, "absent"); got != nil {
+		t.Errorf("Lookup(absent) = %q, want none", got)
+	}
+
+	tests := []struct {
+		key, val string
+		want     bool
+	}{
+		{"key7", "57", true},
+		{"key7", "57", false},
+		{"key7", "8", false},
+		{"absent", "1", false},
+	}
+	for _, tt := range tests {
+		got, err := h.Delete([]byte(tt.key), []byte(tt.val))
+		if err != nil || got != tt.want {
+			t.Errorf("Delete(%s, %s) = %v, %v, want %v, nil", tt.key, tt.val, got, err, tt.want)
+		}
+	}
+	if got := collect(t, h, "key7"); len(got) != 3 {
+		t.Errorf("Lookup(key7) after delete = %q, want 3 values", got)
+	}
+
+	// Emptying the index frees the overflow blocks for reuse.
+	for i := range n {
+		if i == 57 {
+			continue
+		}
+		if ok, err := h.Delete([]byte(fmt.Sprintf("key%d", i%50)), []byte(fmt.Sprint(i))); err != nil || !ok {
+			t.Fatalf("Delete(%d) = %v, %v", i, ok, err)
+		}
+	}
+	before, _ := fm.Length("s.idx")
+	for i := range n {
+		if err := h.Insert([]byte(fmt.Sprintf("key%d", i%50)), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+	if after, _ := fm.Length("s.idx"); after != before {
+		t.Errorf("Length() grew from %d to %d, want freed blocks reused", before, after)
+	}
+
+	if err := h.Insert([]byte("big"), make([]byte, 120)); err == nil {
+		t.Errorf("Insert(oversized entry) error = nil, want error")
+	}
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"errors"
+
+	"simpledb-in-golang/file"
+)
+
+// LogIterator moves through the records of the log file in reverse order,
+// from the most recently written record to the oldest one.
+type LogIterator struct {
+	fm         *file.FileMgr
+	blk        file.BlockId
+	p          *file.Page
+	currentpos int
+}
+
+// newLogIterator creates an iterator positioned after the last record of blk.
+func newLogIterator(fm *file.FileMgr, blk file.BlockId) (*LogIterator, error) {
+	it := &LogIterator{
+		fm: fm,
+		p:  file.NewPage(fm.BlockSize()),
+	}
+	if err := it.moveToBlock(blk); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// HasNext reports whether there are older log records remaining.
+func (it *LogIterator) HasNext() bool {
+	return it.currentpos < it.fm.BlockSize() || it.blk.Number() > 0
+}
+
+// Next returns the next older log record, moving to the previous
+// block of the log file when the current one is exhausted.
+func (it *LogIterator) Next() ([]byte, error) {
+	if !it.HasNext() {
+		return nil, errors.New("Next: no more log records")
+	}
+	if it.currentpos == it.fm.BlockSize() {
+		prev := file.NewBlockId(it.blk.FileName(), it.blk.Number()-1)
+		if err := it.moveToBlock(prev); err != nil {
+			return nil, err
+		}
+	}
+	rec, err := it.p.GetBytes(it.currentpos)
+	if err != nil {
+		return nil, err
+	}
+	it.currentpos += len(rec) + 4
+	return rec, nil
+}
+
+// moveToBlock reads the specified block and positions the iterator at its first record.
+func (it *LogIterator) moveToBlock(blk file.BlockId) error {
+	if err := it.fm.Read(blk, it.p); err != nil {
+		return err
+	}
+	boundary, err := it.p.GetInt(boundaryPos)
+	if err != nil {
+		return err
+	}
+	it.blk = blk
+	it.currentpos = boundary
+	return nil
+}
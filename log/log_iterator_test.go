@@ -0,0 +1,88 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+func TestLogIterator(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			numRecords int
+		}
+	)
+
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "empty log",
+			args: args{numRecords: 0},
+		},
+		{
+			name: "single block",
+			args: args{numRecords: 10},
+		},
+		{
+			name: "multiple blocks",
+			args: args{numRecords: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_logiter_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := file.NewFileMgr(testDir, 400)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			lm, err := NewLogMgr(fm, "simpledb.log")
+			if err != nil {
+				t.Fatalf("NewLogMgr() failed: %v", err)
+			}
+
+			for i := range tt.args.numRecords {
+				if _, err := lm.Append(fmt.Appendf(nil, "record%d", i)); err != nil {
+					t.Fatalf("Append() error = %v", err)
+				}
+			}
+
+			it, err := lm.Iterator()
+			if err != nil {
+				t.Fatalf("Iterator() error = %v", err)
+			}
+
+			// Records come back newest first.
+			for i := tt.args.numRecords - 1; i >= 0; i-- {
+				if !it.HasNext() {
+					t.Fatalf("HasNext() = false, want record%d", i)
+				}
+				rec, err := it.Next()
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				want := fmt.Sprintf("record%d", i)
+				if string(rec) != want {
+					t.Errorf("Next() = %q, want %q", rec, want)
+				}
+			}
+			if it.HasNext() {
+				t.Errorf("HasNext() = true after all records were read")
+			}
+			if _, err := it.Next(); err == nil {
+				t.Errorf("Next() error = nil past the oldest record")
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package log
+
+import (
+	"errors"
+	"sync"
+
+	"simpledb-in-golang/file"
+)
+
+// Layout of the header at the start of each log block.
+const (
+	boundaryPos = 0 // offset of the most recently added record
+	lsnPos      = 4 // LSN of the most recent record in the log
+	headerSize  = 8
+)
+
+// LogMgr is responsible for writing log records into a log file.
+// Records are appended to the current log page from right to left;
+// each block starts with the offset of the most recently added record
+// (the boundary) and the latest LSN, from which a reopened log resumes
+// numbering.
+type LogMgr struct {
+	fm           *file.FileMgr
+	logfile      string
+	logpage      *file.Page
+	currentblk   file.BlockId
+	latestLSN    int
+	lastSavedLSN int
+
+	mu sync.Mutex
+}
+
+// NewLogMgr creates the manager for the specified log file.
+// If the log file does not yet exist, it is created with an empty first block.
+func NewLogMgr(fm *file.FileMgr, logfile string) (*LogMgr, error) {
+	lm := &LogMgr{
+		fm:      fm,
+		logfile: logfile,
+		logpage: file.NewPage(fm.BlockSize()),
+	}
+	logsize, err := fm.Length(logfile)
+	if err != nil {
+		return nil, err
+	}
+	if logsize == 0 {
+		blk, err := lm.appendNewBlock()
+		if err != nil {
+			return nil, err
+		}
+		lm.currentblk = blk
+	} else {
+		lm.currentblk = file.NewBlockId(logfile, logsize-1)
+		if err := fm.Read(lm.currentblk, lm.logpage); err != nil {
+			return nil, err
+		}
+		boundary, err := lm.logpage.GetInt(boundaryPos)
+		if err != nil {
+			return nil, err
+		}
+		if boundary == 0 {
+			// A crash while the block was added left it without a header;
+			// resume from the previous block and give this one a header.
+			if logsize > 1 {
+				if err := fm.Read(file.NewBlockId(logfile, logsize-2), lm.logpage); err != nil {
+					return nil, err
+				}
+				if lm.latestLSN, err = lm.logpage.GetInt(lsnPos); err != nil {
+					return nil, err
+				}
+			}
+			if err := lm.initPage(); err != nil {
+				return nil, err
+			}
+			if err := fm.Write(lm.currentblk, lm.logpage); err != nil {
+				return nil, err
+			}
+		} else if lm.latestLSN, err = lm.logpage.GetInt(lsnPos); err != nil {
+			return nil, err
+		}
+		lm.lastSavedLSN = lm.latestLSN
+	}
+	return lm, nil
+}
+
+// Append adds a log record to the log buffer and returns its LSN.
+// The record is not guaranteed to be on disk until Flush is called
+// with an LSN at least as large as the returned one.
+func (lm *LogMgr) Append(rec []byte) (int, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	bytesneeded := len(rec) + 4
+	if bytesneeded+headerSize > lm.fm.BlockSize() {
+		return 0, errors.New("Append: log record too large for block")
+	}
+	boundary, err := lm.logpage.GetInt(boundaryPos)
+	if err != nil {
+		return 0, err
+	}
+	if boundary-bytesneeded < headerSize {
+		// The record doesn't fit, so move to the next block.
+		if err := lm.flush(); err != nil {
+			return 0, err
+		}
+		blk, err := lm.appendNewBlock()
+		if err != nil {
+			return 0, err
+		}
+		lm.currentblk = blk
+		if boundary, err = lm.logpage.GetInt(boundaryPos); err != nil {
+			return 0, err
+		}
+	}
+	recpos := boundary - bytesneeded
+	if err := lm.logpage.SetBytes(recpos, rec); err != nil {
+		return 0, err
+	}
+	if err := lm.logpage.SetInt(boundaryPos, recpos); err != nil {
+		return 0, err
+	}
+	if err := lm.logpage.SetInt(lsnPos, lm.latestLSN+1); err != nil {
+		return 0, err
+	}
+	lm.latestLSN++
+	return lm.latestLSN, nil
+}
+
+// Flush ensures that the log record corresponding to the specified LSN
+// is on stable storage. Records already saved are not written again.
+func (lm *LogMgr) Flush(lsn int) error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lsn <= lm.lastSavedLSN {
+		return nil
+	}
+	return lm.flush()
+}
+
+// Iterator flushes the log and returns an iterator over its records,
+// starting with the most recent one.
+func (lm *LogMgr) Iterator() (*LogIterator, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if err := lm.flush(); err != nil {
+		return nil, err
+	}
+	return newLogIterator(lm.fm, lm.currentblk)
+}
+
+// appendNewBlock initializes the log page as an empty block and appends it to the log file.
+// The block is added by a single Write of its header, so that the log
+// never ends in a block without one.
+func (lm *LogMgr) appendNewBlock() (file.BlockId, error) {
+	n, err := lm.fm.Length(lm.logfile)
+	if err != nil {
+		return file.BlockId{}, err
+	}
+	blk := file.NewBlockId(lm.logfile, n)
+	if err := lm.initPage(); err != nil {
+		return file.BlockId{}, err
+	}
+	if err := lm.fm.Write(blk, lm.logpage); err != nil {
+		return file.BlockId{}, err
+	}
+	return blk, nil
+}
+
+// initPage makes the log page an empty block carrying the latest LSN.
+func (lm *LogMgr) initPage() error {
+	clear(lm.logpage.Buffer())
+	if err := lm.logpage.SetInt(boundaryPos, lm.fm.BlockSize()); err != nil {
+		return err
+	}
+	return lm.logpage.SetInt(lsnPos, lm.latestLSN)
+}
+
+// flush writes the log page to disk and syncs the log file, whatever the
+// durability mode of the FileMgr. The caller must hold lm.mu.
+func (lm *LogMgr) flush() error {
+	if err := lm.fm.Write(lm.currentblk, lm.logpage); err != nil {
+		return err
+	}
+	if err := lm.fm.Sync(lm.logfile); err != nil {
+		return err
+	}
+	lm.lastSavedLSN = lm.latestLSN
+	return nil
+}
@@ -0,0 +1,301 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/file/filetest"
+)
+
+func TestNewLogMgr(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_logmgr_new")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+
+	if _, err := NewLogMgr(fm, "simpledb.log"); err != nil {
+		t.Fatalf("NewLogMgr() error = %v", err)
+	}
+
+	length, err := fm.Length("simpledb.log")
+	if err != nil {
+		t.Fatalf("Length() failed: %v", err)
+	}
+	if length != 1 {
+		t.Errorf("log file length = %v, want 1", length)
+	}
+}
+
+func TestLogMgr_Append(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			numRecords int
+		}
+		wants struct {
+			lastLSN   int
+			minBlocks int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "single record",
+			args:  args{numRecords: 1},
+			wants: wants{lastLSN: 1, minBlocks: 1},
+		},
+		{
+			name:  "records within one block",
+			args:  args{numRecords: 5},
+			wants: wants{lastLSN: 5, minBlocks: 1},
+		},
+		{
+			name:  "records spanning several blocks",
+			args:  args{numRecords: 70},
+			wants: wants{lastLSN: 70, minBlocks: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_logmgr_append_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := file.NewFileMgr(testDir, 400)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			lm, err := NewLogMgr(fm, "simpledb.log")
+			if err != nil {
+				t.Fatalf("NewLogMgr() failed: %v", err)
+			}
+
+			var lsn int
+			for i := range tt.args.numRecords {
+				got, err := lm.Append(fmt.Appendf(nil, "record%d", i))
+				if err != nil {
+					t.Fatalf("Append() error = %v", err)
+				}
+				if got != lsn+1 {
+					t.Errorf("Append() lsn = %v, want %v", got, lsn+1)
+				}
+				lsn = got
+			}
+			if lsn != tt.wants.lastLSN {
+				t.Errorf("last lsn = %v, want %v", lsn, tt.wants.lastLSN)
+			}
+
+			if err := lm.Flush(lsn); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			length, err := fm.Length("simpledb.log")
+			if err != nil {
+				t.Fatalf("Length() failed: %v", err)
+			}
+			if length < tt.wants.minBlocks {
+				t.Errorf("log file length = %v, want at least %v", length, tt.wants.minBlocks)
+			}
+		})
+	}
+}
+
+func TestLogMgr_Append_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_logmgr_toolarge")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	lm, err := NewLogMgr(fm, "simpledb.log")
+	if err != nil {
+		t.Fatalf("NewLogMgr() failed: %v", err)
+	}
+
+	if _, err := lm.Append(make([]byte, 400)); err == nil {
+		t.Errorf("Append() error = nil, want error for oversized record")
+	}
+}
+
+func TestLogMgr_Flush_Reopen(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_logmgr_reopen")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	lm, err := NewLogMgr(fm, "simpledb.log")
+	if err != nil {
+		t.Fatalf("NewLogMgr() failed: %v", err)
+	}
+
+	var lsn int
+	for i := range 40 {
+		if lsn, err = lm.Append(fmt.Appendf(nil, "record%d", i)); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if err := lm.Flush(lsn); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	// A second manager over the same directory must see every flushed record.
+	fm2, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	lm2, err := NewLogMgr(fm2, "simpledb.log")
+	if err != nil {
+		t.Fatalf("NewLogMgr() reopen failed: %v", err)
+	}
+	it, err := lm2.Iterator()
+	if err != nil {
+		t.Fatalf("Iterator() failed: %v", err)
+	}
+	count := 0
+	for it.HasNext() {
+		if _, err := it.Next(); err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		count++
+	}
+	if count != 40 {
+		t.Errorf("records after reopen = %v, want 40", count)
+	}
+
+	// Numbering resumes after the last record rather than restarting.
+	next, err := lm2.Append([]byte("after reopen"))
+	if err != nil {
+		t.Fatalf("Append() after reopen error = %v", err)
+	}
+	if next != lsn+1 {
+		t.Errorf("Append() after reopen = LSN %d, want %d", next, lsn+1)
+	}
+}
+
+func TestLogMgr_CrashWhileAddingBlock(t *testing.T) {
+	t.Parallel()
+
+	// fill appends records until the log has moved to a new block.
+	fill := func(lm *LogMgr) (int, error) {
+		start := lm.currentblk.Number()
+		for i := 0; ; i++ {
+			lsn, err := lm.Append(fmt.Appendf(nil, "record%d", i))
+			if err != nil || lm.currentblk.Number() > start {
+				return lsn, err
+			}
+		}
+	}
+
+	t.Run("zeroed last block", func(t *testing.T) {
+		t.Parallel()
+		s := filetest.NewFaultStorage(1)
+		fm, err := file.NewFileMgr("", 400, file.WithStorage(s))
+		if err != nil {
+			t.Fatalf("NewFileMgr() failed: %v", err)
+		}
+		lm, err := NewLogMgr(fm, "simpledb.log")
+		if err != nil {
+			t.Fatalf("NewLogMgr() failed: %v", err)
+		}
+		lsn, err := fill(lm)
+		if err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		if err := lm.Flush(lsn); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+		// Power is cut once the log has grown by a block but before the
+		// block's header reached the disk.
+		if _, err := fm.Append("simpledb.log"); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		if err := fm.Sync("simpledb.log"); err != nil {
+			t.Fatalf("Sync() error = %v", err)
+		}
+		fm, err = filetest.Reopen(s, filetest.PowerLoss{}, 400)
+		if err != nil {
+			t.Fatalf("Reopen() failed: %v", err)
+		}
+		lm, err = NewLogMgr(fm, "simpledb.log")
+		if err != nil {
+			t.Fatalf("NewLogMgr() reopen failed: %v", err)
+		}
+		if next, err := lm.Append([]byte("after crash")); err != nil || next != lsn+1 {
+			t.Errorf("Append() after crash = LSN %d, %v, want %d", next, err, lsn+1)
+		}
+	})
+
+	losses := []struct {
+		name string
+		loss filetest.PowerLoss
+	}{
+		{"no write lost", filetest.PowerLoss{KeepProbability: 1}},
+		{"torn writes", filetest.PowerLoss{KeepProbability: 0.5, Reorder: true, Tear: true}},
+	}
+	for _, tt := range losses {
+		t.Run("every crash point, "+tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := filetest.ExploreCrashPoints(filetest.Scenario{
+				BlockSize: 400,
+				Workload: func(fm *file.FileMgr, p *filetest.Progress) error {
+					lm, err := NewLogMgr(fm, "simpledb.log")
+					if err != nil {
+						return err
+					}
+					for range 3 {
+						lsn, err := fill(lm)
+						if err != nil {
+							return err
+						}
+						if err := lm.Flush(lsn); err != nil {
+							return err
+						}
+						for p.Count() < lsn {
+							p.Mark()
+						}
+					}
+					return nil
+				},
+				Check: func(fm *file.FileMgr, p *filetest.Progress) error {
+					lm, err := NewLogMgr(fm, "simpledb.log")
+					if err != nil {
+						return err
+					}
+					next, err := lm.Append([]byte("after crash"))
+					if err != nil {
+						return err
+					}
+					if next <= p.Count() {
+						return fmt.Errorf("Append() after crash = LSN %d, want more than %d", next, p.Count())
+					}
+					return nil
+				},
+				Loss: tt.loss,
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
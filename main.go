@@ -1,47 +1,92 @@
 package main
 
 import (
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+
 	"simpledb-in-golang/file"
 )
 
 func main() {
-	const (
-		dbName    = "filetest"
-		blockSize = 400
-	)
+	dir := flag.String("dir", "simpledb", "database directory")
+	blockSize := flag.Int("blocksize", 400, "block size in bytes")
+	command := flag.String("c", "", "run the given commands (newline-separated) and exit")
+	keyFile := flag.String("keys", "", "encrypt blocks with the keys in this file, one \"<id> <hex key>\" per line; the highest id is current")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [script]\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), helpText)
+	}
+	flag.Parse()
 
-	fm, err := file.NewFileMgr(dbName, blockSize)
+	var opts []file.Option
+	if *keyFile != "" {
+		keys, err := loadKeys(*keyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, file.WithEncryption(keys))
+	}
+	fm, err := file.NewFileMgr(*dir, *blockSize, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println("isNew:", fm.IsNew())
+	sh := &shell{fm: fm, out: os.Stdout}
 
-	blk := file.NewBlockId("testfile", 2)
-
-	// write
-	p1 := file.NewPage(fm.BlockSize())
-	pos1 := 88
-	if err := p1.SetString(pos1, "abcdefghijklm"); err != nil {
-		log.Fatal(err)
+	switch {
+	case *command != "":
+		err = sh.run(strings.NewReader(*command), false)
+	case flag.NArg() > 0:
+		var f *os.File
+		if f, err = os.Open(flag.Arg(0)); err == nil {
+			err = sh.run(f, false)
+			f.Close()
+		}
+	default:
+		err = sh.run(os.Stdin, true)
 	}
-	size := file.MaxLength(len("abcdefghijklm"))
-	pos2 := pos1 + size
-	if err := p1.SetInt(pos2, 345); err != nil {
-		log.Fatal(err)
+	if cerr := fm.Close(); err == nil {
+		err = cerr
 	}
-	if err := fm.Write(blk, p1); err != nil {
+	if err != nil {
 		log.Fatal(err)
 	}
+}
 
-	// read
-	p2 := file.NewPage(fm.BlockSize())
-	if err := fm.Read(blk, p2); err != nil {
-		log.Fatal(err)
+// loadKeys reads encryption keys from a file of "<id> <hex key>" lines.
+// Blank lines and lines starting with # are ignored.
+func loadKeys(path string) (file.StaticKeys, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(file.StaticKeys)
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want <id> <hex key>", path, i+1)
+		}
+		id, err := strconv.ParseUint(fields[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid key id %q", path, i+1, fields[0])
+		}
+		key, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid hex key", path, i+1)
+		}
+		keys[uint32(id)] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no keys", path)
 	}
-	ival, _ := p2.GetInt(pos2)
-	str, _ := p2.GetString(pos1)
-	fmt.Printf("offset %d contains %d\n", pos2, ival)
-	fmt.Printf("offset %d contains %q\n", pos1, str)
+	return keys, nil
 }
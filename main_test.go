@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+func TestLoadKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    file.StaticKeys
+		wantErr bool
+	}{
+		{
+			name:    "keys and comments",
+			content: "# rotated 2026-10\n1 00112233445566778899aabbccddeeff\n\n2 ff" + strings.Repeat("00", 15) + "\n",
+			want: file.StaticKeys{
+				1: {0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff},
+				2: append([]byte{0xff}, make([]byte, 15)...),
+			},
+		},
+		{name: "empty", content: "# none\n", wantErr: true},
+		{name: "bad id", content: "x 00\n", wantErr: true},
+		{name: "bad hex", content: "1 zz\n", wantErr: true},
+		{name: "missing key", content: "1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(os.TempDir(), "testdb_keys_"+tt.name)
+			defer os.Remove(path)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			got, err := loadKeys(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("loadKeys() = %d keys, want %d", len(got), len(tt.want))
+			}
+			for id, key := range tt.want {
+				if !bytes.Equal(got[id], key) {
+					t.Errorf("key %d = %x, want %x", id, got[id], key)
+				}
+			}
+		})
+	}
+}
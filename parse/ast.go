@@ -0,0 +1,252 @@
+package parse
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"simpledb-in-golang/file"
+)
+
+// Field and constant types, matching the file.Page accessors used to store them.
+const (
+	Integer = iota + 1 // GetInt/SetInt
+	Varchar            // GetString/SetString
+	Blob               // GetBytes/SetBytes
+)
+
+// Statement is implemented by the data of every parsed statement.
+type Statement interface {
+	String() string
+}
+
+// Constant is an integer, string or byte-string value.
+type Constant struct {
+	Type  int
+	Int   int
+	Str   string
+	Bytes []byte
+}
+
+// NewIntConstant creates an integer constant.
+func NewIntConstant(v int) Constant { return Constant{Type: Integer, Int: v} }
+
+// NewStringConstant creates a string constant.
+func NewStringConstant(s string) Constant { return Constant{Type: Varchar, Str: s} }
+
+// NewBytesConstant creates a byte-string constant.
+func NewBytesConstant(b []byte) Constant { return Constant{Type: Blob, Bytes: b} }
+
+// String returns the constant as it would be written in a statement.
+func (c Constant) String() string {
+	switch c.Type {
+	case Integer:
+		return strconv.Itoa(c.Int)
+	case Blob:
+		return "X'" + strings.ToUpper(hex.EncodeToString(c.Bytes)) + "'"
+	default:
+		return "'" + strings.ReplaceAll(c.Str, "'", "''") + "'"
+	}
+}
+
+// Expression is either a field name or a constant.
+type Expression struct {
+	Field string
+	Val   Constant
+}
+
+// IsFieldName reports whether the expression refers to a field.
+func (e Expression) IsFieldName() bool { return e.Field != "" }
+
+// String returns the expression as it would be written in a statement.
+func (e Expression) String() string {
+	if e.IsFieldName() {
+		return e.Field
+	}
+	return e.Val.String()
+}
+
+// Predicate is a condition in a WHERE clause: a *Term, an *And or an *Or.
+type Predicate interface {
+	String() string
+}
+
+// Term compares two expressions using one of =, <>, <, <=, > and >=.
+type Term struct {
+	LHS Expression
+	Op  string
+	RHS Expression
+}
+
+// String returns the term as it would be written in a statement.
+func (t *Term) String() string {
+	return t.LHS.String() + " " + t.Op + " " + t.RHS.String()
+}
+
+// And is satisfied when both operands are.
+type And struct {
+	Left, Right Predicate
+}
+
+// String returns the conjunction as it would be written in a statement.
+func (a *And) String() string {
+	return wrapOr(a.Left) + " and " + wrapOr(a.Right)
+}
+
+// Or is satisfied when either operand is.
+type Or struct {
+	Left, Right Predicate
+}
+
+// String returns the disjunction as it would be written in a statement.
+func (o *Or) String() string {
+	return o.Left.String() + " or " + o.Right.String()
+}
+
+// wrapOr parenthesizes a disjunction used as an operand of AND.
+func wrapOr(p Predicate) string {
+	if _, ok := p.(*Or); ok {
+		return "(" + p.String() + ")"
+	}
+	return p.String()
+}
+
+// QueryData holds the data for a SELECT statement.
+type QueryData struct {
+	Fields []string
+	Tables []string
+	Pred   Predicate // nil if there is no WHERE clause
+}
+
+// String returns the query as SQL text.
+func (q *QueryData) String() string {
+	s := "select " + strings.Join(q.Fields, ", ") + " from " + strings.Join(q.Tables, ", ")
+	return s + whereClause(q.Pred)
+}
+
+// InsertData holds the data for an INSERT statement.
+type InsertData struct {
+	Table  string
+	Fields []string
+	Vals   []Constant
+}
+
+// String returns the statement as SQL text.
+func (d *InsertData) String() string {
+	vals := make([]string, len(d.Vals))
+	for i, v := range d.Vals {
+		vals[i] = v.String()
+	}
+	return "insert into " + d.Table + " (" + strings.Join(d.Fields, ", ") +
+		") values (" + strings.Join(vals, ", ") + ")"
+}
+
+// DeleteData holds the data for a DELETE statement.
+type DeleteData struct {
+	Table string
+	Pred  Predicate // nil if there is no WHERE clause
+}
+
+// String returns the statement as SQL text.
+func (d *DeleteData) String() string {
+	return "delete from " + d.Table + whereClause(d.Pred)
+}
+
+// Assignment is a "field = expression" item of an UPDATE statement.
+type Assignment struct {
+	Field string
+	Expr  Expression
+}
+
+// ModifyData holds the data for an UPDATE statement.
+type ModifyData struct {
+	Table string
+	Set   []Assignment
+	Pred  Predicate // nil if there is no WHERE clause
+}
+
+// String returns the statement as SQL text.
+func (d *ModifyData) String() string {
+	set := make([]string, len(d.Set))
+	for i, a := range d.Set {
+		set[i] = a.Field + " = " + a.Expr.String()
+	}
+	return "update " + d.Table + " set " + strings.Join(set, ", ") + whereClause(d.Pred)
+}
+
+// FieldDef is a field declaration of a CREATE TABLE statement.
+// Length is the maximum number of characters or bytes of a varchar or blob field.
+type FieldDef struct {
+	Name   string
+	Type   int
+	Length int
+}
+
+// Size returns the number of bytes a file.Page needs to store the field.
+func (f FieldDef) Size() int {
+	if f.Type == Integer {
+		return 4
+	}
+	return file.MaxLength(f.Length)
+}
+
+// String returns the declaration as SQL text.
+func (f FieldDef) String() string {
+	switch f.Type {
+	case Integer:
+		return f.Name + " int"
+	case Blob:
+		return f.Name + " blob(" + strconv.Itoa(f.Length) + ")"
+	default:
+		return f.Name + " varchar(" + strconv.Itoa(f.Length) + ")"
+	}
+}
+
+// CreateTableData holds the data for a CREATE TABLE statement.
+type CreateTableData struct {
+	Table  string
+	Fields []FieldDef
+}
+
+// String returns the statement as SQL text.
+func (d *CreateTableData) String() string {
+	defs := make([]string, len(d.Fields))
+	for i, f := range d.Fields {
+		defs[i] = f.String()
+	}
+	return "create table " + d.Table + " (" + strings.Join(defs, ", ") + ")"
+}
+
+// CreateViewData holds the data for a CREATE VIEW statement.
+type CreateViewData struct {
+	View  string
+	Query *QueryData
+}
+
+// ViewDef returns the text of the query defining the view.
+func (d *CreateViewData) ViewDef() string { return d.Query.String() }
+
+// String returns the statement as SQL text.
+func (d *CreateViewData) String() string {
+	return "create view " + d.View + " as " + d.ViewDef()
+}
+
+// CreateIndexData holds the data for a CREATE INDEX statement.
+type CreateIndexData struct {
+	Index string
+	Table string
+	Field string
+}
+
+// String returns the statement as SQL text.
+func (d *CreateIndexData) String() string {
+	return "create index " + d.Index + " on " + d.Table + " (" + d.Field + ")"
+}
+
+// whereClause renders an optional predicate.
+func whereClause(p Predicate) string {
+	if p == nil {
+		return ""
+	}
+	return " where " + p.String()
+}
@@ -0,0 +1,82 @@
+package parse
+
+import (
+	"testing"
+)
+
+func TestConstant_String(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		c    Constant
+		want string
+	}{
+		{"int", NewIntConstant(-5), "-5"},
+		{"string", NewStringConstant("it's"), "'it''s'"},
+		{"bytes", NewBytesConstant([]byte{0xde, 0xad}), "X'DEAD'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.c.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldDef_Size(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		fd   FieldDef
+		want int
+	}{
+		{"int", FieldDef{Name: "a", Type: Integer}, 4},
+		{"varchar", FieldDef{Name: "s", Type: Varchar, Length: 10}, 14},
+		{"blob", FieldDef{Name: "b", Type: Blob, Length: 32}, 36},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.fd.Size(); got != tt.want {
+				t.Errorf("Size() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPredicate_String(t *testing.T) {
+	t.Parallel()
+
+	a := &Term{LHS: Expression{Field: "a"}, Op: "=", RHS: Expression{Val: NewIntConstant(1)}}
+	b := &Term{LHS: Expression{Field: "b"}, Op: "<", RHS: Expression{Field: "c"}}
+	c := &Term{LHS: Expression{Field: "d"}, Op: "<>", RHS: Expression{Val: NewStringConstant("x")}}
+
+	tests := []struct {
+		name string
+		pred Predicate
+		want string
+	}{
+		{"term", a, "a = 1"},
+		{"and", &And{Left: a, Right: b}, "a = 1 and b < c"},
+		{"or of ands", &Or{Left: &And{Left: a, Right: b}, Right: c}, "a = 1 and b < c or d <> 'x'"},
+		{"and of or", &And{Left: &Or{Left: a, Right: b}, Right: c}, "(a = 1 or b < c) and d <> 'x'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.pred.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
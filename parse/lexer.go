@@ -0,0 +1,296 @@
+package parse
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError reports a lexical or grammatical error and where it occurred.
+type SyntaxError struct {
+	Line int
+	Col  int
+	Msg  string
+}
+
+// Error implements the error interface.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Col, e.Msg)
+}
+
+// Token kinds.
+const (
+	tokEOF = iota
+	tokDelim
+	tokInt
+	tokString
+	tokBytes
+	tokKeyword
+	tokID
+)
+
+// token is a lexical unit together with its position in the input.
+type token struct {
+	kind int
+	text string // keyword or identifier (lower case), delimiter, or string contents
+	ival int
+	bval []byte
+	line int
+	col  int
+}
+
+// describe returns a human-readable description of the token for error messages.
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of input"
+	case tokInt:
+		return strconv.Itoa(t.ival)
+	case tokString:
+		return "'" + t.text + "'"
+	case tokBytes:
+		return "X'" + strings.ToUpper(hex.EncodeToString(t.bval)) + "'"
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// keywords are the reserved words of the language.
+var keywords = map[string]bool{
+	"select": true, "from": true, "where": true, "and": true, "or": true,
+	"insert": true, "into": true, "values": true, "delete": true, "update": true,
+	"set": true, "create": true, "table": true, "int": true, "varchar": true,
+	"blob": true, "view": true, "as": true, "index": true, "on": true,
+}
+
+// Lexer is the lexical analyzer. Keywords and identifiers are case-insensitive
+// and are reported in lower case. String constants are enclosed in single
+// quotes, a doubled quote standing for one quote; byte constants are written X'0A1B'.
+type Lexer struct {
+	tokens []token
+	pos    int
+}
+
+// NewLexer tokenizes s, returning an error for any malformed token.
+func NewLexer(s string) (*Lexer, error) {
+	sc := &scanner{src: []rune(s), line: 1, col: 1}
+	var tokens []token
+	for {
+		tok, err := sc.next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		if tok.kind == tokEOF {
+			return &Lexer{tokens: tokens}, nil
+		}
+	}
+}
+
+// MatchDelim reports whether the current token is the specified delimiter.
+func (lx *Lexer) MatchDelim(d string) bool {
+	t := lx.current()
+	return t.kind == tokDelim && t.text == d
+}
+
+// MatchIntConstant reports whether the current token is an integer.
+func (lx *Lexer) MatchIntConstant() bool { return lx.current().kind == tokInt }
+
+// MatchStringConstant reports whether the current token is a string.
+func (lx *Lexer) MatchStringConstant() bool { return lx.current().kind == tokString }
+
+// MatchBytesConstant reports whether the current token is a byte constant.
+func (lx *Lexer) MatchBytesConstant() bool { return lx.current().kind == tokBytes }
+
+// MatchKeyword reports whether the current token is the specified keyword.
+func (lx *Lexer) MatchKeyword(w string) bool {
+	t := lx.current()
+	return t.kind == tokKeyword && t.text == w
+}
+
+// MatchId reports whether the current token is an identifier.
+func (lx *Lexer) MatchId() bool { return lx.current().kind == tokID }
+
+// MatchEOF reports whether all tokens have been consumed.
+func (lx *Lexer) MatchEOF() bool { return lx.current().kind == tokEOF }
+
+// EatDelim consumes the specified delimiter.
+func (lx *Lexer) EatDelim(d string) error {
+	if !lx.MatchDelim(d) {
+		return lx.errorf("expected %q", d)
+	}
+	lx.pos++
+	return nil
+}
+
+// EatIntConstant consumes an integer and returns its value.
+func (lx *Lexer) EatIntConstant() (int, error) {
+	if !lx.MatchIntConstant() {
+		return 0, lx.errorf("expected integer")
+	}
+	v := lx.current().ival
+	lx.pos++
+	return v, nil
+}
+
+// EatStringConstant consumes a string and returns its contents.
+func (lx *Lexer) EatStringConstant() (string, error) {
+	if !lx.MatchStringConstant() {
+		return "", lx.errorf("expected string constant")
+	}
+	s := lx.current().text
+	lx.pos++
+	return s, nil
+}
+
+// EatBytesConstant consumes a byte constant and returns its value.
+func (lx *Lexer) EatBytesConstant() ([]byte, error) {
+	if !lx.MatchBytesConstant() {
+		return nil, lx.errorf("expected byte constant")
+	}
+	b := lx.current().bval
+	lx.pos++
+	return b, nil
+}
+
+// EatKeyword consumes the specified keyword.
+func (lx *Lexer) EatKeyword(w string) error {
+	if !lx.MatchKeyword(w) {
+		return lx.errorf("expected %s", strings.ToUpper(w))
+	}
+	lx.pos++
+	return nil
+}
+
+// EatId consumes an identifier and returns it.
+func (lx *Lexer) EatId() (string, error) {
+	if !lx.MatchId() {
+		return "", lx.errorf("expected identifier")
+	}
+	s := lx.current().text
+	lx.pos++
+	return s, nil
+}
+
+// current returns the token at the current position.
+func (lx *Lexer) current() token { return lx.tokens[lx.pos] }
+
+// errorf returns a SyntaxError located at the current token.
+func (lx *Lexer) errorf(format string, args ...any) error {
+	t := lx.current()
+	return &SyntaxError{
+		Line: t.line,
+		Col:  t.col,
+		Msg:  fmt.Sprintf(format, args...) + ", found " + t.describe(),
+	}
+}
+
+// scanner splits the input into tokens while tracking line and column.
+type scanner struct {
+	src  []rune
+	pos  int
+	line int
+	col  int
+}
+
+// peek returns the rune at offset i from the current position, or 0 past the end.
+func (sc *scanner) peek(i int) rune {
+	if sc.pos+i < len(sc.src) {
+		return sc.src[sc.pos+i]
+	}
+	return 0
+}
+
+// advance consumes one rune.
+func (sc *scanner) advance() rune {
+	r := sc.src[sc.pos]
+	sc.pos++
+	if r == '\n' {
+		sc.line++
+		sc.col = 1
+	} else {
+		sc.col++
+	}
+	return r
+}
+
+// next scans the next token.
+func (sc *scanner) next() (token, error) {
+	for sc.pos < len(sc.src) && unicode.IsSpace(sc.peek(0)) {
+		sc.advance()
+	}
+	tok := token{line: sc.line, col: sc.col}
+	if sc.pos >= len(sc.src) {
+		tok.kind = tokEOF
+		return tok, nil
+	}
+	r := sc.peek(0)
+	switch {
+	case (r == 'x' || r == 'X') && sc.peek(1) == '\'':
+		sc.advance()
+		s, err := sc.quoted(tok)
+		if err != nil {
+			return tok, err
+		}
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return tok, &SyntaxError{Line: tok.line, Col: tok.col, Msg: "malformed byte constant"}
+		}
+		tok.kind, tok.bval = tokBytes, b
+	case unicode.IsLetter(r) || r == '_':
+		start := sc.pos
+		for sc.pos < len(sc.src) && (unicode.IsLetter(sc.peek(0)) || unicode.IsDigit(sc.peek(0)) || sc.peek(0) == '_') {
+			sc.advance()
+		}
+		tok.text = strings.ToLower(string(sc.src[start:sc.pos]))
+		tok.kind = tokID
+		if keywords[tok.text] {
+			tok.kind = tokKeyword
+		}
+	case unicode.IsDigit(r) || (r == '-' && unicode.IsDigit(sc.peek(1))):
+		start := sc.pos
+		sc.advance()
+		for sc.pos < len(sc.src) && unicode.IsDigit(sc.peek(0)) {
+			sc.advance()
+		}
+		v, err := strconv.ParseInt(string(sc.src[start:sc.pos]), 10, 32)
+		if err != nil {
+			return tok, &SyntaxError{Line: tok.line, Col: tok.col, Msg: "integer constant out of range"}
+		}
+		tok.kind, tok.ival = tokInt, int(v)
+	case r == '\'':
+		s, err := sc.quoted(tok)
+		if err != nil {
+			return tok, err
+		}
+		tok.kind, tok.text = tokString, s
+	case r == '<' && (sc.peek(1) == '=' || sc.peek(1) == '>'), r == '>' && sc.peek(1) == '=':
+		tok.kind, tok.text = tokDelim, string([]rune{sc.advance(), sc.advance()})
+	case strings.ContainsRune(",()=<>*;", r):
+		tok.kind, tok.text = tokDelim, string(sc.advance())
+	default:
+		return tok, &SyntaxError{Line: tok.line, Col: tok.col, Msg: fmt.Sprintf("unexpected character %q", r)}
+	}
+	return tok, nil
+}
+
+// quoted consumes a single-quoted string and returns its contents.
+func (sc *scanner) quoted(start token) (string, error) {
+	sc.advance() // opening quote
+	var sb strings.Builder
+	for {
+		if sc.pos >= len(sc.src) {
+			return "", &SyntaxError{Line: start.line, Col: start.col, Msg: "unterminated string constant"}
+		}
+		r := sc.advance()
+		if r == '\'' {
+			if sc.peek(0) != '\'' {
+				return sb.String(), nil
+			}
+			sc.advance()
+		}
+		sb.WriteRune(r)
+	}
+}
@@ -0,0 +1,121 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestLexer(t *testing.T) {
+	t.Parallel()
+
+	lx, err := NewLexer("SELECT Name, -12 FROM t WHERE x <= 'it''s' AND b <> X'0aFF';")
+	if err != nil {
+		t.Fatalf("NewLexer() error = %v", err)
+	}
+
+	steps := []struct {
+		name string
+		eat  func() (any, error)
+		want any
+	}{
+		{"keyword", func() (any, error) { return nil, lx.EatKeyword("select") }, nil},
+		{"identifier is lower-cased", func() (any, error) { return lx.EatId() }, "name"},
+		{"comma", func() (any, error) { return nil, lx.EatDelim(",") }, nil},
+		{"negative int", func() (any, error) { return lx.EatIntConstant() }, -12},
+		{"from", func() (any, error) { return nil, lx.EatKeyword("from") }, nil},
+		{"table", func() (any, error) { return lx.EatId() }, "t"},
+		{"where", func() (any, error) { return nil, lx.EatKeyword("where") }, nil},
+		{"field", func() (any, error) { return lx.EatId() }, "x"},
+		{"two-character operator", func() (any, error) { return nil, lx.EatDelim("<=") }, nil},
+		{"escaped quote", func() (any, error) { return lx.EatStringConstant() }, "it's"},
+		{"and", func() (any, error) { return nil, lx.EatKeyword("and") }, nil},
+		{"field b", func() (any, error) { return lx.EatId() }, "b"},
+		{"not equal", func() (any, error) { return nil, lx.EatDelim("<>") }, nil},
+		{"bytes", func() (any, error) { return lx.EatBytesConstant() }, []byte{0x0a, 0xff}},
+		{"semicolon", func() (any, error) { return nil, lx.EatDelim(";") }, nil},
+	}
+
+	for _, st := range steps {
+		got, err := st.eat()
+		if err != nil {
+			t.Fatalf("%s: error = %v", st.name, err)
+		}
+		if b, ok := st.want.([]byte); ok {
+			if !bytes.Equal(got.([]byte), b) {
+				t.Errorf("%s: got %v, want %v", st.name, got, b)
+			}
+		} else if got != st.want {
+			t.Errorf("%s: got %v, want %v", st.name, got, st.want)
+		}
+	}
+	if !lx.MatchEOF() {
+		t.Errorf("MatchEOF() = false at end of input")
+	}
+}
+
+func TestLexer_Errors(t *testing.T) {
+	t.Parallel()
+
+	type (
+		wants struct {
+			line int
+			col  int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		input string
+		wants wants
+	}{
+		{
+			name:  "unterminated string",
+			input: "select a\nfrom t where a = 'abc",
+			wants: wants{line: 2, col: 18},
+		},
+		{
+			name:  "unexpected character",
+			input: "select a ? b",
+			wants: wants{line: 1, col: 10},
+		},
+		{
+			name:  "odd hex digits",
+			input: "  X'ABC'",
+			wants: wants{line: 1, col: 3},
+		},
+		{
+			name:  "integer overflow",
+			input: "\n\n 99999999999",
+			wants: wants{line: 3, col: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewLexer(tt.input)
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("NewLexer() error = %v, want *SyntaxError", err)
+			}
+			if se.Line != tt.wants.line || se.Col != tt.wants.col {
+				t.Errorf("error at %d:%d, want %d:%d (%v)", se.Line, se.Col, tt.wants.line, tt.wants.col, se)
+			}
+		})
+	}
+}
+
+func TestLexer_EatMismatch(t *testing.T) {
+	t.Parallel()
+
+	lx, err := NewLexer("  123")
+	if err != nil {
+		t.Fatalf("NewLexer() error = %v", err)
+	}
+	_, err = lx.EatId()
+	if err == nil || err.Error() != "line 1, column 3: expected identifier, found 123" {
+		t.Errorf("EatId() error = %v", err)
+	}
+}
@@ -0,0 +1,459 @@
+package parse
+
+// Parser is a recursive-descent parser for the SQL subset understood by the database.
+type Parser struct {
+	lex *Lexer
+}
+
+// NewParser creates a parser for the statement in s.
+func NewParser(s string) (*Parser, error) {
+	lex, err := NewLexer(s)
+	if err != nil {
+		return nil, err
+	}
+	return &Parser{lex: lex}, nil
+}
+
+// Parse parses a single statement, optionally terminated by a semicolon.
+func Parse(s string) (Statement, error) {
+	p, err := NewParser(s)
+	if err != nil {
+		return nil, err
+	}
+	return p.Statement()
+}
+
+// Statement parses a query or update command, which must make up the rest of the input.
+func (p *Parser) Statement() (Statement, error) {
+	var (
+		stmt Statement
+		err  error
+	)
+	if p.lex.MatchKeyword("select") {
+		stmt, err = p.Query()
+	} else {
+		stmt, err = p.UpdateCmd()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.lex.MatchDelim(";") {
+		p.lex.EatDelim(";")
+	}
+	if !p.lex.MatchEOF() {
+		return nil, p.lex.errorf("expected end of statement")
+	}
+	return stmt, nil
+}
+
+// Query parses a SELECT statement.
+func (p *Parser) Query() (*QueryData, error) {
+	if err := p.lex.EatKeyword("select"); err != nil {
+		return nil, err
+	}
+	fields, err := p.idList()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("from"); err != nil {
+		return nil, err
+	}
+	tables, err := p.idList()
+	if err != nil {
+		return nil, err
+	}
+	pred, err := p.optWhere()
+	if err != nil {
+		return nil, err
+	}
+	return &QueryData{Fields: fields, Tables: tables, Pred: pred}, nil
+}
+
+// UpdateCmd parses an INSERT, DELETE, UPDATE or CREATE statement.
+func (p *Parser) UpdateCmd() (Statement, error) {
+	switch {
+	case p.lex.MatchKeyword("insert"):
+		return p.insert()
+	case p.lex.MatchKeyword("delete"):
+		return p.delete()
+	case p.lex.MatchKeyword("update"):
+		return p.modify()
+	case p.lex.MatchKeyword("create"):
+		return p.create()
+	default:
+		return nil, p.lex.errorf("expected SELECT, INSERT, DELETE, UPDATE or CREATE")
+	}
+}
+
+// Predicate parses a condition: terms combined with AND and OR, where AND
+// binds more tightly and parentheses may be used for grouping.
+func (p *Parser) Predicate() (Predicate, error) {
+	left, err := p.conjunction()
+	if err != nil {
+		return nil, err
+	}
+	for p.lex.MatchKeyword("or") {
+		p.lex.EatKeyword("or")
+		right, err := p.conjunction()
+		if err != nil {
+			return nil, err
+		}
+		left = &Or{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// conjunction parses factors separated by AND.
+func (p *Parser) conjunction() (Predicate, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.lex.MatchKeyword("and") {
+		p.lex.EatKeyword("and")
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = &And{Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// factor parses a parenthesized predicate or a single term.
+func (p *Parser) factor() (Predicate, error) {
+	if !p.lex.MatchDelim("(") {
+		return p.term()
+	}
+	p.lex.EatDelim("(")
+	pred, err := p.Predicate()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatDelim(")"); err != nil {
+		return nil, err
+	}
+	return pred, nil
+}
+
+// term parses a comparison between two expressions.
+func (p *Parser) term() (*Term, error) {
+	lhs, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	var op string
+	for _, candidate := range []string{"=", "<>", "<=", ">=", "<", ">"} {
+		if p.lex.MatchDelim(candidate) {
+			op = candidate
+			break
+		}
+	}
+	if op == "" {
+		return nil, p.lex.errorf("expected comparison operator")
+	}
+	p.lex.EatDelim(op)
+	rhs, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	return &Term{LHS: lhs, Op: op, RHS: rhs}, nil
+}
+
+// expression parses a field name or a constant.
+func (p *Parser) expression() (Expression, error) {
+	if p.lex.MatchId() {
+		fld, err := p.lex.EatId()
+		return Expression{Field: fld}, err
+	}
+	c, err := p.constant()
+	return Expression{Val: c}, err
+}
+
+// constant parses an integer, string or byte constant.
+func (p *Parser) constant() (Constant, error) {
+	switch {
+	case p.lex.MatchStringConstant():
+		s, err := p.lex.EatStringConstant()
+		return NewStringConstant(s), err
+	case p.lex.MatchIntConstant():
+		v, err := p.lex.EatIntConstant()
+		return NewIntConstant(v), err
+	case p.lex.MatchBytesConstant():
+		b, err := p.lex.EatBytesConstant()
+		return NewBytesConstant(b), err
+	default:
+		return Constant{}, p.lex.errorf("expected field name or constant")
+	}
+}
+
+// optWhere parses an optional WHERE clause, returning nil if there is none.
+func (p *Parser) optWhere() (Predicate, error) {
+	if !p.lex.MatchKeyword("where") {
+		return nil, nil
+	}
+	p.lex.EatKeyword("where")
+	return p.Predicate()
+}
+
+// idList parses a comma-separated list of identifiers.
+func (p *Parser) idList() ([]string, error) {
+	var ids []string
+	for {
+		id, err := p.lex.EatId()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+		if !p.lex.MatchDelim(",") {
+			return ids, nil
+		}
+		p.lex.EatDelim(",")
+	}
+}
+
+// constList parses a comma-separated list of constants.
+func (p *Parser) constList() ([]Constant, error) {
+	var vals []Constant
+	for {
+		c, err := p.constant()
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, c)
+		if !p.lex.MatchDelim(",") {
+			return vals, nil
+		}
+		p.lex.EatDelim(",")
+	}
+}
+
+// insert parses INSERT INTO table (fields) VALUES (constants).
+func (p *Parser) insert() (*InsertData, error) {
+	if err := p.lex.EatKeyword("insert"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("into"); err != nil {
+		return nil, err
+	}
+	table, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatDelim("("); err != nil {
+		return nil, err
+	}
+	fields, err := p.idList()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatDelim(")"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("values"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatDelim("("); err != nil {
+		return nil, err
+	}
+	valpos := p.lex.current()
+	vals, err := p.constList()
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) != len(fields) {
+		return nil, &SyntaxError{Line: valpos.line, Col: valpos.col,
+			Msg: "number of values does not match number of fields"}
+	}
+	if err := p.lex.EatDelim(")"); err != nil {
+		return nil, err
+	}
+	return &InsertData{Table: table, Fields: fields, Vals: vals}, nil
+}
+
+// delete parses DELETE FROM table [WHERE predicate].
+func (p *Parser) delete() (*DeleteData, error) {
+	if err := p.lex.EatKeyword("delete"); err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("from"); err != nil {
+		return nil, err
+	}
+	table, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	pred, err := p.optWhere()
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteData{Table: table, Pred: pred}, nil
+}
+
+// modify parses UPDATE table SET field = expression, ... [WHERE predicate].
+func (p *Parser) modify() (*ModifyData, error) {
+	if err := p.lex.EatKeyword("update"); err != nil {
+		return nil, err
+	}
+	table, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("set"); err != nil {
+		return nil, err
+	}
+	var set []Assignment
+	for {
+		fld, err := p.lex.EatId()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.lex.EatDelim("="); err != nil {
+			return nil, err
+		}
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, Assignment{Field: fld, Expr: expr})
+		if !p.lex.MatchDelim(",") {
+			break
+		}
+		p.lex.EatDelim(",")
+	}
+	pred, err := p.optWhere()
+	if err != nil {
+		return nil, err
+	}
+	return &ModifyData{Table: table, Set: set, Pred: pred}, nil
+}
+
+// create parses the CREATE TABLE, CREATE VIEW and CREATE INDEX statements.
+func (p *Parser) create() (Statement, error) {
+	if err := p.lex.EatKeyword("create"); err != nil {
+		return nil, err
+	}
+	switch {
+	case p.lex.MatchKeyword("table"):
+		return p.createTable()
+	case p.lex.MatchKeyword("view"):
+		return p.createView()
+	case p.lex.MatchKeyword("index"):
+		return p.createIndex()
+	default:
+		return nil, p.lex.errorf("expected TABLE, VIEW or INDEX")
+	}
+}
+
+// createTable parses TABLE name (field type, ...).
+func (p *Parser) createTable() (*CreateTableData, error) {
+	p.lex.EatKeyword("table")
+	table, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatDelim("("); err != nil {
+		return nil, err
+	}
+	var fields []FieldDef
+	for {
+		fd, err := p.fieldDef()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, fd)
+		if !p.lex.MatchDelim(",") {
+			break
+		}
+		p.lex.EatDelim(",")
+	}
+	if err := p.lex.EatDelim(")"); err != nil {
+		return nil, err
+	}
+	return &CreateTableData{Table: table, Fields: fields}, nil
+}
+
+// fieldDef parses a field name followed by INT, VARCHAR(n) or BLOB(n).
+func (p *Parser) fieldDef() (FieldDef, error) {
+	name, err := p.lex.EatId()
+	if err != nil {
+		return FieldDef{}, err
+	}
+	switch {
+	case p.lex.MatchKeyword("int"):
+		p.lex.EatKeyword("int")
+		return FieldDef{Name: name, Type: Integer}, nil
+	case p.lex.MatchKeyword("varchar"):
+		p.lex.EatKeyword("varchar")
+		n, err := p.fieldLength()
+		return FieldDef{Name: name, Type: Varchar, Length: n}, err
+	case p.lex.MatchKeyword("blob"):
+		p.lex.EatKeyword("blob")
+		n, err := p.fieldLength()
+		return FieldDef{Name: name, Type: Blob, Length: n}, err
+	default:
+		return FieldDef{}, p.lex.errorf("expected INT, VARCHAR or BLOB")
+	}
+}
+
+// fieldLength parses the parenthesized, positive length of a varchar or blob field.
+func (p *Parser) fieldLength() (int, error) {
+	if err := p.lex.EatDelim("("); err != nil {
+		return 0, err
+	}
+	if p.lex.MatchIntConstant() && p.lex.current().ival <= 0 {
+		return 0, p.lex.errorf("expected positive length")
+	}
+	n, err := p.lex.EatIntConstant()
+	if err != nil {
+		return 0, err
+	}
+	if err := p.lex.EatDelim(")"); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// createView parses VIEW name AS query.
+func (p *Parser) createView() (*CreateViewData, error) {
+	p.lex.EatKeyword("view")
+	view, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("as"); err != nil {
+		return nil, err
+	}
+	q, err := p.Query()
+	if err != nil {
+		return nil, err
+	}
+	return &CreateViewData{View: view, Query: q}, nil
+}
+
+// createIndex parses INDEX name ON table (field).
+func (p *Parser) createIndex() (*CreateIndexData, error) {
+	p.lex.EatKeyword("index")
+	index, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatKeyword("on"); err != nil {
+		return nil, err
+	}
+	table, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatDelim("("); err != nil {
+		return nil, err
+	}
+	field, err := p.lex.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lex.EatDelim(")"); err != nil {
+		return nil, err
+	}
+	return &CreateIndexData{Index: index, Table: table, Field: field}, nil
+}
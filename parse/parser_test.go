@@ -0,0 +1,220 @@
+package parse
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		// want is the statement's String() form, which round-trips through the parser.
+		want string
+	}{
+		{
+			name:  "select",
+			input: "SELECT a, b FROM t1, t2",
+			want:  "select a, b from t1, t2",
+		},
+		{
+			name:  "select with and/or precedence",
+			input: "select a from t where a = 1 or b = 'x' and c > 3;",
+			want:  "select a from t where a = 1 or b = 'x' and c > 3",
+		},
+		{
+			name:  "select with parentheses",
+			input: "select a from t where (a = 1 or b = 2) and c >= d",
+			want:  "select a from t where (a = 1 or b = 2) and c >= d",
+		},
+		{
+			name:  "insert",
+			input: "insert into t (a, s, b) values (1, 'one', x'01ff')",
+			want:  "insert into t (a, s, b) values (1, 'one', X'01FF')",
+		},
+		{
+			name:  "delete",
+			input: "delete from t where a <> 5",
+			want:  "delete from t where a <> 5",
+		},
+		{
+			name:  "delete all",
+			input: "DELETE FROM t",
+			want:  "delete from t",
+		},
+		{
+			name:  "update",
+			input: "update t set a = 2, s = b where a <= 1",
+			want:  "update t set a = 2, s = b where a <= 1",
+		},
+		{
+			name:  "create table",
+			input: "create table student (id int, name varchar(20), photo blob(512))",
+			want:  "create table student (id int, name varchar(20), photo blob(512))",
+		},
+		{
+			name:  "create view",
+			input: "create view v as select a from t where a < 10",
+			want:  "create view v as select a from t where a < 10",
+		},
+		{
+			name:  "create index",
+			input: "create index idx on t (a)",
+			want:  "create index idx on t (a)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stmt, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := stmt.String(); got != tt.want {
+				t.Errorf("Parse().String() = %q, want %q", got, tt.want)
+			}
+			again, err := Parse(stmt.String())
+			if err != nil {
+				t.Fatalf("Parse() of round trip error = %v", err)
+			}
+			if again.String() != stmt.String() {
+				t.Errorf("round trip = %q, want %q", again.String(), stmt.String())
+			}
+		})
+	}
+}
+
+func TestParse_AST(t *testing.T) {
+	t.Parallel()
+
+	stmt, err := Parse("select a from t where a = 1 or b = 2 and c = 3")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	q, ok := stmt.(*QueryData)
+	if !ok {
+		t.Fatalf("Parse() = %T, want *QueryData", stmt)
+	}
+	or, ok := q.Pred.(*Or)
+	if !ok {
+		t.Fatalf("predicate = %T, want *Or", q.Pred)
+	}
+	if _, ok := or.Right.(*And); !ok {
+		t.Errorf("right operand of OR = %T, want *And", or.Right)
+	}
+
+	stmt, err = Parse("create table t (s varchar(9), n int)")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	ct := stmt.(*CreateTableData)
+	if ct.Fields[0].Type != Varchar || ct.Fields[0].Length != 9 || ct.Fields[0].Size() != 13 {
+		t.Errorf("field 0 = %+v", ct.Fields[0])
+	}
+	if ct.Fields[1].Type != Integer {
+		t.Errorf("field 1 = %+v", ct.Fields[1])
+	}
+
+	stmt, err = Parse("insert into t (b) values (X'00')")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if v := stmt.(*InsertData).Vals[0]; v.Type != Blob || len(v.Bytes) != 1 {
+		t.Errorf("value = %+v, want one-byte blob", v)
+	}
+
+	stmt, err = Parse("create view v as select a from t")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got := stmt.(*CreateViewData).ViewDef(); got != "select a from t" {
+		t.Errorf("ViewDef() = %q", got)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	t.Parallel()
+
+	type (
+		wants struct {
+			line int
+			col  int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		input string
+		wants wants
+	}{
+		{
+			name:  "unknown command",
+			input: "drop table t",
+			wants: wants{line: 1, col: 1},
+		},
+		{
+			name:  "missing from",
+			input: "select a\nwhere a = 1",
+			wants: wants{line: 2, col: 1},
+		},
+		{
+			name:  "missing operator",
+			input: "select a from t where a 1",
+			wants: wants{line: 1, col: 25},
+		},
+		{
+			name:  "value count mismatch",
+			input: "insert into t (a, b)\n  values (1)",
+			wants: wants{line: 2, col: 11},
+		},
+		{
+			name:  "zero length varchar",
+			input: "create table t (s varchar(0))",
+			wants: wants{line: 1, col: 27},
+		},
+		{
+			name:  "unknown type",
+			input: "create table t (s text)",
+			wants: wants{line: 1, col: 19},
+		},
+		{
+			name:  "trailing tokens",
+			input: "delete from t x",
+			wants: wants{line: 1, col: 15},
+		},
+		{
+			name:  "unbalanced parenthesis",
+			input: "select a from t where (a = 1",
+			wants: wants{line: 1, col: 29},
+		},
+		{
+			name:  "create what",
+			input: "create user u",
+			wants: wants{line: 1, col: 8},
+		},
+		{
+			name:  "lexical error",
+			input: "select a from t where a = 'x",
+			wants: wants{line: 1, col: 27},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse(tt.input)
+			var se *SyntaxError
+			if !errors.As(err, &se) {
+				t.Fatalf("Parse() error = %v, want *SyntaxError", err)
+			}
+			if se.Line != tt.wants.line || se.Col != tt.wants.col {
+				t.Errorf("error at %d:%d, want %d:%d (%v)", se.Line, se.Col, tt.wants.line, tt.wants.col, se)
+			}
+		})
+	}
+}
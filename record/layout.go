@@ -0,0 +1,45 @@
+package record
+
+import "simpledb-in-golang/file"
+
+// Layout describes the structure of a record: the offset of each field
+// within a slot and the size of the slot. Each slot begins with a 4-byte
+// empty/used flag.
+type Layout struct {
+	schema   *Schema
+	offsets  map[string]int
+	slotsize int
+}
+
+// NewLayout computes the physical layout of records having the given schema.
+func NewLayout(schema *Schema) *Layout {
+	offsets := make(map[string]int)
+	pos := 4 // space for the empty/used flag
+	for _, fldname := range schema.Fields() {
+		offsets[fldname] = pos
+		pos += lengthInBytes(schema, fldname)
+	}
+	return &Layout{schema: schema, offsets: offsets, slotsize: pos}
+}
+
+// NewLayoutFromMetadata creates a layout from previously computed offsets and slot size.
+func NewLayoutFromMetadata(schema *Schema, offsets map[string]int, slotsize int) *Layout {
+	return &Layout{schema: schema, offsets: offsets, slotsize: slotsize}
+}
+
+// Schema returns the schema of the records.
+func (l *Layout) Schema() *Schema { return l.schema }
+
+// Offset returns the offset of the specified field within a slot.
+func (l *Layout) Offset(fldname string) int { return l.offsets[fldname] }
+
+// SlotSize returns the size of a slot in bytes.
+func (l *Layout) SlotSize() int { return l.slotsize }
+
+// lengthInBytes returns the number of bytes needed to store the specified field.
+func lengthInBytes(schema *Schema, fldname string) int {
+	if schema.Type(fldname) == Integer {
+		return 4
+	}
+	return file.MaxLength(schema.Length(fldname))
+}
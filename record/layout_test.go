@@ -0,0 +1,82 @@
+package record
+
+import (
+	"testing"
+)
+
+func TestNewLayout(t *testing.T) {
+	t.Parallel()
+
+	type (
+		wants struct {
+			offsets  map[string]int
+			slotsize int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		build func() *Schema
+		wants wants
+	}{
+		{
+			name:  "no fields",
+			build: NewSchema,
+			wants: wants{offsets: map[string]int{}, slotsize: 4},
+		},
+		{
+			name: "int then string",
+			build: func() *Schema {
+				sch := NewSchema()
+				sch.AddIntField("A")
+				sch.AddStringField("B", 9)
+				return sch
+			},
+			wants: wants{offsets: map[string]int{"A": 4, "B": 8}, slotsize: 21},
+		},
+		{
+			name: "string then int",
+			build: func() *Schema {
+				sch := NewSchema()
+				sch.AddStringField("B", 9)
+				sch.AddIntField("A")
+				return sch
+			},
+			wants: wants{offsets: map[string]int{"B": 4, "A": 17}, slotsize: 21},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			layout := NewLayout(tt.build())
+			for fldname, want := range tt.wants.offsets {
+				if got := layout.Offset(fldname); got != want {
+					t.Errorf("Offset(%q) = %v, want %v", fldname, got, want)
+				}
+			}
+			if got := layout.SlotSize(); got != tt.wants.slotsize {
+				t.Errorf("SlotSize() = %v, want %v", got, tt.wants.slotsize)
+			}
+		})
+	}
+}
+
+func TestNewLayoutFromMetadata(t *testing.T) {
+	t.Parallel()
+
+	sch := NewSchema()
+	sch.AddIntField("A")
+	layout := NewLayoutFromMetadata(sch, map[string]int{"A": 8}, 16)
+
+	if layout.Schema() != sch {
+		t.Errorf("Schema() returned a different schema")
+	}
+	if got := layout.Offset("A"); got != 8 {
+		t.Errorf("Offset(A) = %v, want 8", got)
+	}
+	if got := layout.SlotSize(); got != 16 {
+		t.Errorf("SlotSize() = %v, want 16", got)
+	}
+}
@@ -0,0 +1,155 @@
+package record
+
+import (
+	"fmt"
+
+	"simpledb-in-golang/file"
+)
+
+// Slot flags.
+const (
+	empty = 0
+	used  = 1
+)
+
+// RecordPage stores records in the slots of a block. The caller is
+// responsible for reading the block into the page and writing it back.
+type RecordPage struct {
+	p      *file.Page
+	blk    file.BlockId
+	layout *Layout
+}
+
+// NewRecordPage wraps a page holding the contents of blk.
+func NewRecordPage(p *file.Page, blk file.BlockId, layout *Layout) *RecordPage {
+	return &RecordPage{p: p, blk: blk, layout: layout}
+}
+
+// Block returns the block whose contents the page holds.
+func (rp *RecordPage) Block() file.BlockId { return rp.blk }
+
+// GetInt returns the integer value of the specified field in the specified slot.
+func (rp *RecordPage) GetInt(slot int, fldname string) (int, error) {
+	pos, err := rp.fieldPos(slot, fldname)
+	if err != nil {
+		return 0, err
+	}
+	return rp.p.GetInt(pos)
+}
+
+// GetString returns the string value of the specified field in the specified slot.
+func (rp *RecordPage) GetString(slot int, fldname string) (string, error) {
+	pos, err := rp.fieldPos(slot, fldname)
+	if err != nil {
+		return "", err
+	}
+	return rp.p.GetString(pos)
+}
+
+// SetInt stores an integer in the specified field of the specified slot.
+func (rp *RecordPage) SetInt(slot int, fldname string, val int) error {
+	pos, err := rp.fieldPos(slot, fldname)
+	if err != nil {
+		return err
+	}
+	return rp.p.SetInt(pos, val)
+}
+
+// SetString stores a string in the specified field of the specified slot.
+// The string must not be longer than the field's declared length.
+func (rp *RecordPage) SetString(slot int, fldname string, val string) error {
+	pos, err := rp.fieldPos(slot, fldname)
+	if err != nil {
+		return err
+	}
+	if n := rp.layout.Schema().Length(fldname); len(val) > n {
+		return fmt.Errorf("SetString: %q exceeds length %d of field %s", val, n, fldname)
+	}
+	return rp.p.SetString(pos, val)
+}
+
+// Delete marks the specified slot as empty.
+func (rp *RecordPage) Delete(slot int) error {
+	return rp.setFlag(slot, empty)
+}
+
+// Format marks every slot of the page as empty and gives each field a zero value.
+func (rp *RecordPage) Format() error {
+	sch := rp.layout.Schema()
+	for slot := 0; rp.isValidSlot(slot); slot++ {
+		if err := rp.p.SetInt(rp.offset(slot), empty); err != nil {
+			return err
+		}
+		for _, fldname := range sch.Fields() {
+			pos := rp.offset(slot) + rp.layout.Offset(fldname)
+			var err error
+			if sch.Type(fldname) == Integer {
+				err = rp.p.SetInt(pos, 0)
+			} else {
+				err = rp.p.SetString(pos, "")
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// NextAfter returns the first used slot following the specified one, or -1 if there is none.
+func (rp *RecordPage) NextAfter(slot int) (int, error) {
+	return rp.searchAfter(slot, used)
+}
+
+// InsertAfter finds the first empty slot following the specified one, marks
+// it as used and returns it. It returns -1 if the page is full.
+func (rp *RecordPage) InsertAfter(slot int) (int, error) {
+	newslot, err := rp.searchAfter(slot, empty)
+	if err != nil || newslot < 0 {
+		return newslot, err
+	}
+	return newslot, rp.setFlag(newslot, used)
+}
+
+// setFlag sets the empty/used flag of the specified slot.
+func (rp *RecordPage) setFlag(slot, flag int) error {
+	if !rp.isValidSlot(slot) {
+		return fmt.Errorf("setFlag: slot %d out of range", slot)
+	}
+	return rp.p.SetInt(rp.offset(slot), flag)
+}
+
+// searchAfter returns the first slot after the specified one having the given flag, or -1.
+func (rp *RecordPage) searchAfter(slot, flag int) (int, error) {
+	for slot++; rp.isValidSlot(slot); slot++ {
+		f, err := rp.p.GetInt(rp.offset(slot))
+		if err != nil {
+			return -1, err
+		}
+		if f == flag {
+			return slot, nil
+		}
+	}
+	return -1, nil
+}
+
+// fieldPos returns the page offset of a field, validating the slot.
+func (rp *RecordPage) fieldPos(slot int, fldname string) (int, error) {
+	if !rp.isValidSlot(slot) {
+		return 0, fmt.Errorf("slot %d out of range", slot)
+	}
+	if !rp.layout.Schema().HasField(fldname) {
+		return 0, fmt.Errorf("unknown field %s", fldname)
+	}
+	return rp.offset(slot) + rp.layout.Offset(fldname), nil
+}
+
+// isValidSlot reports whether the slot lies entirely within the page.
+func (rp *RecordPage) isValidSlot(slot int) bool {
+	return slot >= 0 && rp.offset(slot+1) <= len(rp.p.Buffer())
+}
+
+// offset returns the page offset of the specified slot.
+func (rp *RecordPage) offset(slot int) int {
+	return slot * rp.layout.SlotSize()
+}
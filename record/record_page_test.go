@@ -0,0 +1,149 @@
+package record
+
+import (
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+func newTestRecordPage(blocksize int) *RecordPage {
+	sch := NewSchema()
+	sch.AddIntField("A")
+	sch.AddStringField("B", 9)
+	return NewRecordPage(file.NewPage(blocksize), file.NewBlockId("test.tbl", 0), NewLayout(sch))
+}
+
+func TestRecordPage_InsertAfter(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			blocksize int
+		}
+		wants struct {
+			slots int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "exact multiple of slot size",
+			args:  args{blocksize: 42},
+			wants: wants{slots: 2},
+		},
+		{
+			name:  "remainder left unused",
+			args:  args{blocksize: 400},
+			wants: wants{slots: 19},
+		},
+		{
+			name:  "block smaller than a slot",
+			args:  args{blocksize: 20},
+			wants: wants{slots: 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rp := newTestRecordPage(tt.args.blocksize)
+			if err := rp.Format(); err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+
+			slot := -1
+			for i := range tt.wants.slots {
+				next, err := rp.InsertAfter(slot)
+				if err != nil {
+					t.Fatalf("InsertAfter() error = %v", err)
+				}
+				if next != i {
+					t.Fatalf("InsertAfter(%d) = %v, want %v", slot, next, i)
+				}
+				slot = next
+			}
+			if next, err := rp.InsertAfter(slot); err != nil || next != -1 {
+				t.Errorf("InsertAfter() on full page = %v, %v, want -1", next, err)
+			}
+		})
+	}
+}
+
+func TestRecordPage_Fields(t *testing.T) {
+	t.Parallel()
+
+	rp := newTestRecordPage(400)
+	if err := rp.Format(); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	for i := range 5 {
+		slot, err := rp.InsertAfter(i - 1)
+		if err != nil {
+			t.Fatalf("InsertAfter() error = %v", err)
+		}
+		if err := rp.SetInt(slot, "A", i*10); err != nil {
+			t.Fatalf("SetInt() error = %v", err)
+		}
+		if err := rp.SetString(slot, "B", "rec"+string(rune('a'+i))); err != nil {
+			t.Fatalf("SetString() error = %v", err)
+		}
+	}
+
+	if err := rp.Delete(1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := rp.Delete(3); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	var got []int
+	for slot, err := rp.NextAfter(-1); slot >= 0; slot, err = rp.NextAfter(slot) {
+		if err != nil {
+			t.Fatalf("NextAfter() error = %v", err)
+		}
+		a, _ := rp.GetInt(slot, "A")
+		b, _ := rp.GetString(slot, "B")
+		if want := "rec" + string(rune('a'+slot)); b != want {
+			t.Errorf("GetString(%d) = %q, want %q", slot, b, want)
+		}
+		got = append(got, a)
+	}
+	if len(got) != 3 || got[0] != 0 || got[1] != 20 || got[2] != 40 {
+		t.Errorf("used slots hold %v, want [0 20 40]", got)
+	}
+
+	// A deleted slot is the first to be reused.
+	if slot, _ := rp.InsertAfter(-1); slot != 1 {
+		t.Errorf("InsertAfter(-1) = %v, want 1", slot)
+	}
+}
+
+func TestRecordPage_Errors(t *testing.T) {
+	t.Parallel()
+
+	rp := newTestRecordPage(400)
+
+	tests := []struct {
+		name string
+		op   func() error
+	}{
+		{"unknown field", func() error { return rp.SetInt(0, "Z", 1) }},
+		{"negative slot", func() error { _, err := rp.GetInt(-1, "A"); return err }},
+		{"slot past end", func() error { _, err := rp.GetString(19, "B"); return err }},
+		{"string too long", func() error { return rp.SetString(0, "B", "0123456789") }},
+		{"delete out of range", func() error { return rp.Delete(100) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.op(); err == nil {
+				t.Errorf("%s: error = nil, want error", tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package record
+
+import "fmt"
+
+// RID identifies a record by the number of its block and its slot within the block.
+type RID struct {
+	blknum int
+	slot   int
+}
+
+// NewRID creates a record identifier.
+func NewRID(blknum, slot int) RID {
+	return RID{blknum: blknum, slot: slot}
+}
+
+// BlockNumber returns the number of the block holding the record.
+func (r RID) BlockNumber() int { return r.blknum }
+
+// Slot returns the slot of the record within its block.
+func (r RID) Slot() int { return r.slot }
+
+// String returns a string representation of the RID.
+func (r RID) String() string {
+	return fmt.Sprintf("[%d, %d]", r.blknum, r.slot)
+}
@@ -0,0 +1,56 @@
+package record
+
+import (
+	"testing"
+)
+
+func TestRID(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			blknum int
+			slot   int
+		}
+		wants struct {
+			str string
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "first record",
+			args:  args{blknum: 0, slot: 0},
+			wants: wants{str: "[0, 0]"},
+		},
+		{
+			name:  "later record",
+			args:  args{blknum: 12, slot: 5},
+			wants: wants{str: "[12, 5]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rid := NewRID(tt.args.blknum, tt.args.slot)
+			if rid.BlockNumber() != tt.args.blknum {
+				t.Errorf("BlockNumber() = %v, want %v", rid.BlockNumber(), tt.args.blknum)
+			}
+			if rid.Slot() != tt.args.slot {
+				t.Errorf("Slot() = %v, want %v", rid.Slot(), tt.args.slot)
+			}
+			if rid.String() != tt.wants.str {
+				t.Errorf("String() = %v, want %v", rid.String(), tt.wants.str)
+			}
+			if rid != NewRID(tt.args.blknum, tt.args.slot) {
+				t.Errorf("equal RIDs compare unequal")
+			}
+		})
+	}
+}
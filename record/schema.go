@@ -0,0 +1,77 @@
+package record
+
+// Field types.
+const (
+	Integer = iota + 1
+	Varchar
+)
+
+// fieldInfo holds the type and declared length of a field.
+type fieldInfo struct {
+	typ    int
+	length int
+}
+
+// Schema is the record schema of a table: the name and type of each field,
+// and the length of each varchar field.
+type Schema struct {
+	fields []string
+	info   map[string]fieldInfo
+}
+
+// NewSchema creates an empty schema.
+func NewSchema() *Schema {
+	return &Schema{info: make(map[string]fieldInfo)}
+}
+
+// AddField adds a field with the specified name, type and length.
+// The length is only meaningful for varchar fields.
+func (s *Schema) AddField(fldname string, typ, length int) {
+	if _, ok := s.info[fldname]; !ok {
+		s.fields = append(s.fields, fldname)
+	}
+	s.info[fldname] = fieldInfo{typ: typ, length: length}
+}
+
+// AddIntField adds an integer field.
+func (s *Schema) AddIntField(fldname string) {
+	s.AddField(fldname, Integer, 0)
+}
+
+// AddStringField adds a varchar field holding at most length characters.
+func (s *Schema) AddStringField(fldname string, length int) {
+	s.AddField(fldname, Varchar, length)
+}
+
+// Add adds a field to the schema having the same type and length as the field in sch.
+func (s *Schema) Add(fldname string, sch *Schema) {
+	s.AddField(fldname, sch.Type(fldname), sch.Length(fldname))
+}
+
+// AddAll adds every field of sch to the schema.
+func (s *Schema) AddAll(sch *Schema) {
+	for _, fldname := range sch.Fields() {
+		s.Add(fldname, sch)
+	}
+}
+
+// Fields returns the field names in the order they were added.
+func (s *Schema) Fields() []string {
+	return append([]string(nil), s.fields...)
+}
+
+// HasField reports whether the schema has a field with the specified name.
+func (s *Schema) HasField(fldname string) bool {
+	_, ok := s.info[fldname]
+	return ok
+}
+
+// Type returns the type of the specified field, or 0 if there is no such field.
+func (s *Schema) Type(fldname string) int {
+	return s.info[fldname].typ
+}
+
+// Length returns the declared length of the specified field.
+func (s *Schema) Length(fldname string) int {
+	return s.info[fldname].length
+}
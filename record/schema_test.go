@@ -0,0 +1,89 @@
+package record
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	type (
+		wants struct {
+			fields  []string
+			types   []int
+			lengths []int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		build func() *Schema
+		wants wants
+	}{
+		{
+			name:  "empty schema",
+			build: NewSchema,
+			wants: wants{fields: nil},
+		},
+		{
+			name: "int and string fields",
+			build: func() *Schema {
+				sch := NewSchema()
+				sch.AddIntField("A")
+				sch.AddStringField("B", 9)
+				return sch
+			},
+			wants: wants{fields: []string{"A", "B"}, types: []int{Integer, Varchar}, lengths: []int{0, 9}},
+		},
+		{
+			name: "redefined field keeps its position",
+			build: func() *Schema {
+				sch := NewSchema()
+				sch.AddIntField("A")
+				sch.AddIntField("B")
+				sch.AddStringField("A", 4)
+				return sch
+			},
+			wants: wants{fields: []string{"A", "B"}, types: []int{Varchar, Integer}, lengths: []int{4, 0}},
+		},
+		{
+			name: "fields copied from another schema",
+			build: func() *Schema {
+				src := NewSchema()
+				src.AddIntField("X")
+				src.AddStringField("Y", 20)
+				sch := NewSchema()
+				sch.Add("Y", src)
+				sch.AddAll(src)
+				return sch
+			},
+			wants: wants{fields: []string{"Y", "X"}, types: []int{Varchar, Integer}, lengths: []int{20, 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sch := tt.build()
+			if got := sch.Fields(); !slices.Equal(got, tt.wants.fields) {
+				t.Fatalf("Fields() = %v, want %v", got, tt.wants.fields)
+			}
+			for i, fldname := range tt.wants.fields {
+				if !sch.HasField(fldname) {
+					t.Errorf("HasField(%q) = false, want true", fldname)
+				}
+				if got := sch.Type(fldname); got != tt.wants.types[i] {
+					t.Errorf("Type(%q) = %v, want %v", fldname, got, tt.wants.types[i])
+				}
+				if got := sch.Length(fldname); got != tt.wants.lengths[i] {
+					t.Errorf("Length(%q) = %v, want %v", fldname, got, tt.wants.lengths[i])
+				}
+			}
+			if sch.HasField("missing") {
+				t.Errorf("HasField(%q) = true, want false", "missing")
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package record
+
+import (
+	"fmt"
+
+	"simpledb-in-golang/file"
+)
+
+// TableScan iterates over the records of a table, which is stored in the
+// file named tblname + ".tbl". Blocks are read through the FileMgr one at a
+// time; changes are written back when the scan moves to another block or is
+// closed, so Close must be called after modifying records.
+type TableScan struct {
+	fm          *file.FileMgr
+	layout      *Layout
+	filename    string
+	rp          *RecordPage
+	currentslot int
+	dirty       bool
+}
+
+// NewTableScan opens a scan positioned before the first record of the table.
+// It returns an error if a record of the layout does not fit in a block.
+func NewTableScan(fm *file.FileMgr, tblname string, layout *Layout) (*TableScan, error) {
+	if layout.SlotSize() > fm.BlockSize() {
+		return nil, fmt.Errorf("NewTableScan: slot size %d exceeds block size %d", layout.SlotSize(), fm.BlockSize())
+	}
+	ts := &TableScan{
+		fm:       fm,
+		layout:   layout,
+		filename: tblname + ".tbl",
+	}
+	size, err := fm.Length(ts.filename)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		err = ts.moveToNewBlock()
+	} else {
+		err = ts.moveToBlock(0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// BeforeFirst positions the scan before the first record.
+func (ts *TableScan) BeforeFirst() error {
+	return ts.moveToBlock(0)
+}
+
+// Next moves to the next record, returning false if there is none.
+func (ts *TableScan) Next() (bool, error) {
+	for {
+		slot, err := ts.rp.NextAfter(ts.currentslot)
+		if err != nil {
+			return false, err
+		}
+		if slot >= 0 {
+			ts.currentslot = slot
+			return true, nil
+		}
+		last, err := ts.atLastBlock()
+		if err != nil || last {
+			return false, err
+		}
+		if err := ts.moveToBlock(ts.rp.Block().Number() + 1); err != nil {
+			return false, err
+		}
+	}
+}
+
+// GetInt returns the value of the specified integer field of the current record.
+func (ts *TableScan) GetInt(fldname string) (int, error) {
+	return ts.rp.GetInt(ts.currentslot, fldname)
+}
+
+// GetString returns the value of the specified string field of the current record.
+func (ts *TableScan) GetString(fldname string) (string, error) {
+	return ts.rp.GetString(ts.currentslot, fldname)
+}
+
+// HasField reports whether the records have the specified field.
+func (ts *TableScan) HasField(fldname string) bool {
+	return ts.layout.Schema().HasField(fldname)
+}
+
+// SetInt sets the specified integer field of the current record.
+func (ts *TableScan) SetInt(fldname string, val int) error {
+	if err := ts.rp.SetInt(ts.currentslot, fldname, val); err != nil {
+		return err
+	}
+	ts.dirty = true
+	return nil
+}
+
+// SetString sets the specified string field of the current record.
+func (ts *TableScan) SetString(fldname string, val string) error {
+	if err := ts.rp.SetString(ts.currentslot, fldname, val); err != nil {
+		return err
+	}
+	ts.dirty = true
+	return nil
+}
+
+// Insert makes room for a new record after the current one and moves to it.
+// If no block has an empty slot, a new block is appended to the file.
+func (ts *TableScan) Insert() error {
+	for {
+		slot, err := ts.rp.InsertAfter(ts.currentslot)
+		if err != nil {
+			return err
+		}
+		if slot >= 0 {
+			ts.currentslot = slot
+			ts.dirty = true
+			return nil
+		}
+		last, err := ts.atLastBlock()
+		if err != nil {
+			return err
+		}
+		if last {
+			err = ts.moveToNewBlock()
+		} else {
+			err = ts.moveToBlock(ts.rp.Block().Number() + 1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Delete removes the current record.
+func (ts *TableScan) Delete() error {
+	if err := ts.rp.Delete(ts.currentslot); err != nil {
+		return err
+	}
+	ts.dirty = true
+	return nil
+}
+
+// MoveToRID positions the scan at the specified record.
+func (ts *TableScan) MoveToRID(rid RID) error {
+	if err := ts.moveToBlock(rid.BlockNumber()); err != nil {
+		return err
+	}
+	ts.currentslot = rid.Slot()
+	return nil
+}
+
+// GetRID returns the identifier of the current record.
+func (ts *TableScan) GetRID() RID {
+	return NewRID(ts.rp.Block().Number(), ts.currentslot)
+}
+
+// Close writes back the current block if it was modified.
+func (ts *TableScan) Close() error {
+	return ts.flush()
+}
+
+// flush writes the current block if it has been modified.
+func (ts *TableScan) flush() error {
+	if ts.rp == nil || !ts.dirty {
+		return nil
+	}
+	if err := ts.fm.Write(ts.rp.Block(), ts.rp.p); err != nil {
+		return err
+	}
+	ts.dirty = false
+	return nil
+}
+
+// moveToBlock writes back the current block and reads the specified one.
+func (ts *TableScan) moveToBlock(blknum int) error {
+	if err := ts.flush(); err != nil {
+		return err
+	}
+	blk := file.NewBlockId(ts.filename, blknum)
+	p := file.NewPage(ts.fm.BlockSize())
+	if err := ts.fm.Read(blk, p); err != nil {
+		return err
+	}
+	ts.rp = NewRecordPage(p, blk, ts.layout)
+	ts.currentslot = -1
+	return nil
+}
+
+// moveToNewBlock writes back the current block, appends a formatted block and moves to it.
+func (ts *TableScan) moveToNewBlock() error {
+	if err := ts.flush(); err != nil {
+		return err
+	}
+	blk, err := ts.fm.Append(ts.filename)
+	if err != nil {
+		return err
+	}
+	ts.rp = NewRecordPage(file.NewPage(ts.fm.BlockSize()), blk, ts.layout)
+	if err := ts.rp.Format(); err != nil {
+		return err
+	}
+	ts.dirty = true
+	ts.currentslot = -1
+	return nil
+}
+
+// atLastBlock reports whether the scan is positioned at the last block of the file.
+func (ts *TableScan) atLastBlock() (bool, error) {
+	size, err := ts.fm.Length(ts.filename)
+	if err != nil {
+		return false, err
+	}
+	return ts.rp.Block().Number() == size-1, nil
+}
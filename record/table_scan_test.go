@@ -0,0 +1,229 @@
+package record
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+func newTestLayout() *Layout {
+	sch := NewSchema()
+	sch.AddIntField("A")
+	sch.AddStringField("B", 9)
+	return NewLayout(sch)
+}
+
+func TestTableScan_InsertAndScan(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			numRecords int
+		}
+		wants struct {
+			blocks int
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "empty table",
+			args:  args{numRecords: 0},
+			wants: wants{blocks: 1},
+		},
+		{
+			name:  "one block",
+			args:  args{numRecords: 19},
+			wants: wants{blocks: 1},
+		},
+		{
+			name:  "several blocks",
+			args:  args{numRecords: 50},
+			wants: wants{blocks: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_tablescan_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := file.NewFileMgr(testDir, 400)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			layout := newTestLayout()
+
+			ts, err := NewTableScan(fm, "T", layout)
+			if err != nil {
+				t.Fatalf("NewTableScan() error = %v", err)
+			}
+			for i := range tt.args.numRecords {
+				if err := ts.Insert(); err != nil {
+					t.Fatalf("Insert() error = %v", err)
+				}
+				if err := ts.SetInt("A", i); err != nil {
+					t.Fatalf("SetInt() error = %v", err)
+				}
+				if err := ts.SetString("B", fmt.Sprintf("rec%d", i)); err != nil {
+					t.Fatalf("SetString() error = %v", err)
+				}
+			}
+			if err := ts.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if n, _ := fm.Length("T.tbl"); n != tt.wants.blocks {
+				t.Errorf("Length() = %v, want %v", n, tt.wants.blocks)
+			}
+
+			// A fresh scan sees every record in insertion order.
+			ts, err = NewTableScan(fm, "T", layout)
+			if err != nil {
+				t.Fatalf("NewTableScan() error = %v", err)
+			}
+			count := 0
+			for {
+				ok, err := ts.Next()
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if !ok {
+					break
+				}
+				a, _ := ts.GetInt("A")
+				b, _ := ts.GetString("B")
+				if a != count || b != fmt.Sprintf("rec%d", count) {
+					t.Errorf("record %d = (%v, %q)", count, a, b)
+				}
+				count++
+			}
+			if count != tt.args.numRecords {
+				t.Errorf("scanned %v records, want %v", count, tt.args.numRecords)
+			}
+		})
+	}
+}
+
+func TestTableScan_DeleteAndRID(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_tablescan_delete")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	ts, err := NewTableScan(fm, "T", newTestLayout())
+	if err != nil {
+		t.Fatalf("NewTableScan() error = %v", err)
+	}
+	if !ts.HasField("A") || ts.HasField("Z") {
+		t.Errorf("HasField() does not match the schema")
+	}
+
+	var rids []RID
+	for i := range 40 {
+		if err := ts.Insert(); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+		ts.SetInt("A", i)
+		rids = append(rids, ts.GetRID())
+	}
+
+	// Delete the even records.
+	if err := ts.BeforeFirst(); err != nil {
+		t.Fatalf("BeforeFirst() error = %v", err)
+	}
+	for {
+		ok, err := ts.Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if !ok {
+			break
+		}
+		if a, _ := ts.GetInt("A"); a%2 == 0 {
+			if err := ts.Delete(); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+		}
+	}
+
+	if err := ts.MoveToRID(rids[21]); err != nil {
+		t.Fatalf("MoveToRID() error = %v", err)
+	}
+	if a, _ := ts.GetInt("A"); a != 21 {
+		t.Errorf("record at %v = %v, want 21", rids[21], a)
+	}
+	if ts.GetRID() != rids[21] {
+		t.Errorf("GetRID() = %v, want %v", ts.GetRID(), rids[21])
+	}
+
+	// Inserting reuses the first free slot rather than growing the file.
+	before, _ := fm.Length("T.tbl")
+	if err := ts.BeforeFirst(); err != nil {
+		t.Fatalf("BeforeFirst() error = %v", err)
+	}
+	if err := ts.Insert(); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if ts.GetRID() != rids[0] {
+		t.Errorf("Insert() reused %v, want %v", ts.GetRID(), rids[0])
+	}
+	if err := ts.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if after, _ := fm.Length("T.tbl"); after != before {
+		t.Errorf("Length() after reuse = %v, want %v", after, before)
+	}
+
+	ts, err = NewTableScan(fm, "T", newTestLayout())
+	if err != nil {
+		t.Fatalf("NewTableScan() error = %v", err)
+	}
+	count := 0
+	for {
+		ok, err := ts.Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if !ok {
+			break
+		}
+		count++
+	}
+	if count != 21 {
+		t.Errorf("records after delete = %v, want 21", count)
+	}
+}
+
+func TestNewTableScan_SlotTooLarge(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_tablescan_toolarge")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	sch := NewSchema()
+	sch.AddStringField("B", 500)
+	if _, err := NewTableScan(fm, "T", NewLayout(sch)); err == nil {
+		t.Errorf("NewTableScan() with a slot larger than a block error = nil, want error")
+	}
+	if n, _ := fm.Length("T.tbl"); n != 0 {
+		t.Errorf("Length() after rejected NewTableScan() = %d, want 0", n)
+	}
+}
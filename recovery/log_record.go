@@ -0,0 +1,344 @@
+package recovery
+
+import (
+	"fmt"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/log"
+)
+
+// Log record types. The type is stored as the first int of every record.
+const (
+	Checkpoint = iota
+	Start
+	Commit
+	Rollback
+	SetInt
+	SetString
+)
+
+// LogRecord is implemented by each type of recovery log record.
+type LogRecord interface {
+	// Op returns the record's type.
+	Op() int
+	// TxNumber returns the unit of work that wrote the record, or -1 if none.
+	TxNumber() int
+	// Undo restores the value saved in the record. Only update records do anything.
+	Undo(fm *file.FileMgr) error
+}
+
+// CreateLogRecord interprets the bytes returned by the log iterator.
+func CreateLogRecord(b []byte) (LogRecord, error) {
+	p := file.NewPageFromBytes(b)
+	op, err := p.GetInt(0)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case Checkpoint:
+		nextTxNum, err := p.GetInt(4)
+		return CheckpointRecord{nextTxNum: nextTxNum}, err
+	case Start:
+		txnum, err := p.GetInt(4)
+		return StartRecord{txnum: txnum}, err
+	case Commit:
+		txnum, err := p.GetInt(4)
+		return CommitRecord{txnum: txnum}, err
+	case Rollback:
+		txnum, err := p.GetInt(4)
+		return RollbackRecord{txnum: txnum}, err
+	case SetInt:
+		return newSetIntRecord(p)
+	case SetString:
+		return newSetStringRecord(p)
+	default:
+		return nil, fmt.Errorf("CreateLogRecord: unknown record type %d", op)
+	}
+}
+
+// CheckpointRecord marks a point before which every unit of work has
+// completed. It holds the next unit of work number, since recovery never
+// reads the records before it.
+type CheckpointRecord struct {
+	nextTxNum int
+}
+
+// Op implements LogRecord.
+func (CheckpointRecord) Op() int { return Checkpoint }
+
+// NextTxNum returns the number of the first unit of work begun after the checkpoint.
+func (r CheckpointRecord) NextTxNum() int { return r.nextTxNum }
+
+// TxNumber implements LogRecord.
+func (CheckpointRecord) TxNumber() int { return -1 }
+
+// Undo implements LogRecord.
+func (CheckpointRecord) Undo(*file.FileMgr) error { return nil }
+
+// String returns a string representation of the record.
+func (r CheckpointRecord) String() string { return fmt.Sprintf("<CHECKPOINT %d>", r.nextTxNum) }
+
+// WriteCheckpointToLog appends a checkpoint record to the log and returns its LSN.
+func WriteCheckpointToLog(lm *log.LogMgr, nextTxNum int) (int, error) {
+	return writeTxRecord(lm, Checkpoint, nextTxNum)
+}
+
+// StartRecord marks the beginning of a unit of work.
+type StartRecord struct {
+	txnum int
+}
+
+// Op implements LogRecord.
+func (StartRecord) Op() int { return Start }
+
+// TxNumber implements LogRecord.
+func (r StartRecord) TxNumber() int { return r.txnum }
+
+// Undo implements LogRecord.
+func (StartRecord) Undo(*file.FileMgr) error { return nil }
+
+// String returns a string representation of the record.
+func (r StartRecord) String() string { return fmt.Sprintf("<START %d>", r.txnum) }
+
+// WriteStartToLog appends a start record to the log and returns its LSN.
+func WriteStartToLog(lm *log.LogMgr, txnum int) (int, error) {
+	return writeTxRecord(lm, Start, txnum)
+}
+
+// CommitRecord marks the successful completion of a unit of work.
+type CommitRecord struct {
+	txnum int
+}
+
+// Op implements LogRecord.
+func (CommitRecord) Op() int { return Commit }
+
+// TxNumber implements LogRecord.
+func (r CommitRecord) TxNumber() int { return r.txnum }
+
+// Undo implements LogRecord.
+func (CommitRecord) Undo(*file.FileMgr) error { return nil }
+
+// String returns a string representation of the record.
+func (r CommitRecord) String() string { return fmt.Sprintf("<COMMIT %d>", r.txnum) }
+
+// WriteCommitToLog appends a commit record to the log and returns its LSN.
+func WriteCommitToLog(lm *log.LogMgr, txnum int) (int, error) {
+	return writeTxRecord(lm, Commit, txnum)
+}
+
+// RollbackRecord marks a unit of work whose changes have been undone.
+type RollbackRecord struct {
+	txnum int
+}
+
+// Op implements LogRecord.
+func (RollbackRecord) Op() int { return Rollback }
+
+// TxNumber implements LogRecord.
+func (r RollbackRecord) TxNumber() int { return r.txnum }
+
+// Undo implements LogRecord.
+func (RollbackRecord) Undo(*file.FileMgr) error { return nil }
+
+// String returns a string representation of the record.
+func (r RollbackRecord) String() string { return fmt.Sprintf("<ROLLBACK %d>", r.txnum) }
+
+// WriteRollbackToLog appends a rollback record to the log and returns its LSN.
+func WriteRollbackToLog(lm *log.LogMgr, txnum int) (int, error) {
+	return writeTxRecord(lm, Rollback, txnum)
+}
+
+// writeTxRecord appends a record consisting of a type and a unit of work number.
+func writeTxRecord(lm *log.LogMgr, op, txnum int) (int, error) {
+	p := file.NewPage(8)
+	if err := p.SetInt(0, op); err != nil {
+		return 0, err
+	}
+	if err := p.SetInt(4, txnum); err != nil {
+		return 0, err
+	}
+	return lm.Append(p.Buffer())
+}
+
+// SetIntRecord saves the old value of an int field before it is modified.
+type SetIntRecord struct {
+	txnum  int
+	blk    file.BlockId
+	offset int
+	val    int
+}
+
+// newSetIntRecord decodes a SETINT record laid out as
+// type, txnum, filename, block number, offset, old value.
+func newSetIntRecord(p *file.Page) (SetIntRecord, error) {
+	var r SetIntRecord
+	fields, err := readUpdateHeader(p)
+	if err != nil {
+		return r, err
+	}
+	val, err := p.GetInt(fields.valpos)
+	if err != nil {
+		return r, err
+	}
+	return SetIntRecord{txnum: fields.txnum, blk: fields.blk, offset: fields.offset, val: val}, nil
+}
+
+// Op implements LogRecord.
+func (SetIntRecord) Op() int { return SetInt }
+
+// TxNumber implements LogRecord.
+func (r SetIntRecord) TxNumber() int { return r.txnum }
+
+// Undo writes the saved value back to its block.
+func (r SetIntRecord) Undo(fm *file.FileMgr) error {
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(r.blk, p); err != nil {
+		return err
+	}
+	if err := p.SetInt(r.offset, r.val); err != nil {
+		return err
+	}
+	return fm.Write(r.blk, p)
+}
+
+// String returns a string representation of the record.
+func (r SetIntRecord) String() string {
+	return fmt.Sprintf("<SETINT %d %s %d %d>", r.txnum, r.blk, r.offset, r.val)
+}
+
+// WriteSetIntToLog appends a SETINT record to the log and returns its LSN.
+func WriteSetIntToLog(lm *log.LogMgr, txnum int, blk file.BlockId, offset, val int) (int, error) {
+	p, valpos, err := newUpdatePage(SetInt, txnum, blk, offset, 4)
+	if err != nil {
+		return 0, err
+	}
+	if err := p.SetInt(valpos, val); err != nil {
+		return 0, err
+	}
+	return lm.Append(p.Buffer())
+}
+
+// SetStringRecord saves the old value of a string field before it is modified.
+type SetStringRecord struct {
+	txnum  int
+	blk    file.BlockId
+	offset int
+	val    string
+}
+
+// newSetStringRecord decodes a SETSTRING record laid out as
+// type, txnum, filename, block number, offset, old value.
+func newSetStringRecord(p *file.Page) (SetStringRecord, error) {
+	var r SetStringRecord
+	fields, err := readUpdateHeader(p)
+	if err != nil {
+		return r, err
+	}
+	val, err := p.GetString(fields.valpos)
+	if err != nil {
+		return r, err
+	}
+	return SetStringRecord{txnum: fields.txnum, blk: fields.blk, offset: fields.offset, val: val}, nil
+}
+
+// Op implements LogRecord.
+func (SetStringRecord) Op() int { return SetString }
+
+// TxNumber implements LogRecord.
+func (r SetStringRecord) TxNumber() int { return r.txnum }
+
+// Undo writes the saved value back to its block.
+func (r SetStringRecord) Undo(fm *file.FileMgr) error {
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(r.blk, p); err != nil {
+		return err
+	}
+	if err := p.SetString(r.offset, r.val); err != nil {
+		return err
+	}
+	return fm.Write(r.blk, p)
+}
+
+// String returns a string representation of the record.
+func (r SetStringRecord) String() string {
+	return fmt.Sprintf("<SETSTRING %d %s %d %s>", r.txnum, r.blk, r.offset, r.val)
+}
+
+// WriteSetStringToLog appends a SETSTRING record to the log and returns its LSN.
+func WriteSetStringToLog(lm *log.LogMgr, txnum int, blk file.BlockId, offset int, val string) (int, error) {
+	p, valpos, err := newUpdatePage(SetString, txnum, blk, offset, file.MaxLength(len(val)))
+	if err != nil {
+		return 0, err
+	}
+	if err := p.SetString(valpos, val); err != nil {
+		return 0, err
+	}
+	return lm.Append(p.Buffer())
+}
+
+// updateHeader holds the fields shared by SETINT and SETSTRING records.
+type updateHeader struct {
+	txnum  int
+	blk    file.BlockId
+	offset int
+	valpos int
+}
+
+// newUpdatePage allocates a page for an update record, fills in the shared
+// fields and returns the position at which the old value must be stored.
+func newUpdatePage(op, txnum int, blk file.BlockId, offset, vallen int) (*file.Page, int, error) {
+	tpos := 4
+	fpos := tpos + 4
+	bpos := fpos + file.MaxLength(len(blk.FileName()))
+	opos := bpos + 4
+	vpos := opos + 4
+	p := file.NewPage(vpos + vallen)
+	if err := p.SetInt(0, op); err != nil {
+		return nil, 0, err
+	}
+	if err := p.SetInt(tpos, txnum); err != nil {
+		return nil, 0, err
+	}
+	if err := p.SetString(fpos, blk.FileName()); err != nil {
+		return nil, 0, err
+	}
+	if err := p.SetInt(bpos, blk.Number()); err != nil {
+		return nil, 0, err
+	}
+	if err := p.SetInt(opos, offset); err != nil {
+		return nil, 0, err
+	}
+	return p, vpos, nil
+}
+
+// readUpdateHeader decodes the fields shared by SETINT and SETSTRING records.
+func readUpdateHeader(p *file.Page) (updateHeader, error) {
+	var h updateHeader
+	tpos := 4
+	txnum, err := p.GetInt(tpos)
+	if err != nil {
+		return h, err
+	}
+	fpos := tpos + 4
+	filename, err := p.GetString(fpos)
+	if err != nil {
+		return h, err
+	}
+	bpos := fpos + file.MaxLength(len(filename))
+	blknum, err := p.GetInt(bpos)
+	if err != nil {
+		return h, err
+	}
+	opos := bpos + 4
+	offset, err := p.GetInt(opos)
+	if err != nil {
+		return h, err
+	}
+	return updateHeader{
+		txnum:  txnum,
+		blk:    file.NewBlockId(filename, blknum),
+		offset: offset,
+		valpos: opos + 4,
+	}, nil
+}
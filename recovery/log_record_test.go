@@ -0,0 +1,160 @@
+package recovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/log"
+)
+
+func TestCreateLogRecord(t *testing.T) {
+	t.Parallel()
+
+	blk := file.NewBlockId("data.db", 3)
+
+	type (
+		args struct {
+			write func(lm *log.LogMgr) (int, error)
+		}
+		wants struct {
+			op    int
+			txnum int
+			str   string
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "checkpoint",
+			args:  args{write: func(lm *log.LogMgr) (int, error) { return WriteCheckpointToLog(lm, 8) }},
+			wants: wants{op: Checkpoint, txnum: -1, str: "<CHECKPOINT 8>"},
+		},
+		{
+			name:  "start",
+			args:  args{write: func(lm *log.LogMgr) (int, error) { return WriteStartToLog(lm, 4) }},
+			wants: wants{op: Start, txnum: 4, str: "<START 4>"},
+		},
+		{
+			name:  "commit",
+			args:  args{write: func(lm *log.LogMgr) (int, error) { return WriteCommitToLog(lm, 5) }},
+			wants: wants{op: Commit, txnum: 5, str: "<COMMIT 5>"},
+		},
+		{
+			name:  "rollback",
+			args:  args{write: func(lm *log.LogMgr) (int, error) { return WriteRollbackToLog(lm, 6) }},
+			wants: wants{op: Rollback, txnum: 6, str: "<ROLLBACK 6>"},
+		},
+		{
+			name: "setint",
+			args: args{write: func(lm *log.LogMgr) (int, error) {
+				return WriteSetIntToLog(lm, 7, blk, 80, 42)
+			}},
+			wants: wants{op: SetInt, txnum: 7, str: "<SETINT 7 [file data.db, block 3] 80 42>"},
+		},
+		{
+			name: "setstring",
+			args: args{write: func(lm *log.LogMgr) (int, error) {
+				return WriteSetStringToLog(lm, 8, blk, 40, "old")
+			}},
+			wants: wants{op: SetString, txnum: 8, str: "<SETSTRING 8 [file data.db, block 3] 40 old>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_logrecord_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, err := file.NewFileMgr(testDir, 400)
+			if err != nil {
+				t.Fatalf("NewFileMgr() failed: %v", err)
+			}
+			lm, err := log.NewLogMgr(fm, "records.log")
+			if err != nil {
+				t.Fatalf("NewLogMgr() failed: %v", err)
+			}
+			if _, err := tt.args.write(lm); err != nil {
+				t.Fatalf("write record failed: %v", err)
+			}
+
+			it, err := lm.Iterator()
+			if err != nil {
+				t.Fatalf("Iterator() failed: %v", err)
+			}
+			b, err := it.Next()
+			if err != nil {
+				t.Fatalf("Next() failed: %v", err)
+			}
+			rec, err := CreateLogRecord(b)
+			if err != nil {
+				t.Fatalf("CreateLogRecord() error = %v", err)
+			}
+			if rec.Op() != tt.wants.op {
+				t.Errorf("Op() = %v, want %v", rec.Op(), tt.wants.op)
+			}
+			if rec.TxNumber() != tt.wants.txnum {
+				t.Errorf("TxNumber() = %v, want %v", rec.TxNumber(), tt.wants.txnum)
+			}
+			if s, ok := rec.(interface{ String() string }); !ok || s.String() != tt.wants.str {
+				t.Errorf("String() = %v, want %q", rec, tt.wants.str)
+			}
+		})
+	}
+}
+
+func TestCreateLogRecord_Unknown(t *testing.T) {
+	t.Parallel()
+
+	p := file.NewPage(4)
+	p.SetInt(0, 99)
+	if _, err := CreateLogRecord(p.Buffer()); err == nil {
+		t.Errorf("CreateLogRecord() error = nil, want error for unknown type")
+	}
+}
+
+func TestSetIntRecord_Undo(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_logrecord_undo")
+	defer os.RemoveAll(testDir)
+
+	fm, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	blk, err := fm.Append("data.db")
+	if err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	p := file.NewPage(fm.BlockSize())
+	p.SetInt(80, 1000)
+	p.SetString(120, "new")
+	if err := fm.Write(blk, p); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+
+	if err := (SetIntRecord{txnum: 1, blk: blk, offset: 80, val: 7}).Undo(fm); err != nil {
+		t.Fatalf("SetIntRecord.Undo() error = %v", err)
+	}
+	if err := (SetStringRecord{txnum: 1, blk: blk, offset: 120, val: "old"}).Undo(fm); err != nil {
+		t.Fatalf("SetStringRecord.Undo() error = %v", err)
+	}
+
+	if err := fm.Read(blk, p); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	if got, _ := p.GetInt(80); got != 7 {
+		t.Errorf("int after undo = %v, want 7", got)
+	}
+	if got, _ := p.GetString(120); got != "old" {
+		t.Errorf("string after undo = %q, want %q", got, "old")
+	}
+}
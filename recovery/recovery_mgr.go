@@ -0,0 +1,294 @@
+package recovery
+
+import (
+	"errors"
+	"sync"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/log"
+)
+
+// LogFile is the name of the recovery log inside the FileMgr directory.
+const LogFile = "recovery.log"
+
+// RecoveryMgr uses undo logging to make units of work atomic. Before a
+// value in a block is overwritten, its old value is appended to the log
+// and the log is flushed, so an interrupted unit of work can always be
+// rolled back by writing the old values back.
+type RecoveryMgr struct {
+	fm *file.FileMgr
+	lm *log.LogMgr
+
+	mu        sync.Mutex
+	nextTxNum int
+	active    map[int]bool
+}
+
+// NewRecoveryMgr opens (or creates) the recovery log in the directory of fm.
+func NewRecoveryMgr(fm *file.FileMgr) (*RecoveryMgr, error) {
+	lm, err := log.NewLogMgr(fm, LogFile)
+	if err != nil {
+		return nil, err
+	}
+	rm := &RecoveryMgr{
+		fm:     fm,
+		lm:     lm,
+		active: make(map[int]bool),
+	}
+	// Continue numbering after the units of work still relevant to recovery.
+	last, err := rm.lastTxNum()
+	if err != nil {
+		return nil, err
+	}
+	rm.nextTxNum = last + 1
+	return rm, nil
+}
+
+// Begin starts a new unit of work and writes its START record.
+func (rm *RecoveryMgr) Begin() (*Tx, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	txnum := rm.nextTxNum
+	if _, err := WriteStartToLog(rm.lm, txnum); err != nil {
+		return nil, err
+	}
+	rm.nextTxNum++
+	rm.active[txnum] = true
+	return &Tx{rm: rm, txnum: txnum, files: make(map[string]bool)}, nil
+}
+
+// Recover restores the database after a crash by undoing every unit of
+// work that neither committed nor rolled back, then writes a quiescent
+// checkpoint. It must not be called while units of work are active.
+func (rm *RecoveryMgr) Recover() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if len(rm.active) > 0 {
+		return errors.New("Recover: units of work are still active")
+	}
+	finished := make(map[int]bool)
+	err := rm.scan(func(rec LogRecord) (bool, error) {
+		switch rec.Op() {
+		case Checkpoint:
+			return false, nil
+		case Commit, Rollback:
+			finished[rec.TxNumber()] = true
+		default:
+			if !finished[rec.TxNumber()] {
+				if err := rec.Undo(rm.fm); err != nil {
+					return false, err
+				}
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	// The undone blocks must be durable before the checkpoint hides
+	// their log records from the next recovery.
+	if err := rm.fm.SyncAll(); err != nil {
+		return err
+	}
+	return rm.checkpoint()
+}
+
+// Checkpoint writes a quiescent checkpoint record. Recovery never looks
+// past it, so it must not be called while units of work are active.
+func (rm *RecoveryMgr) Checkpoint() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if len(rm.active) > 0 {
+		return errors.New("Checkpoint: units of work are still active")
+	}
+	return rm.checkpoint()
+}
+
+// checkpoint appends and flushes a checkpoint record. The caller must hold rm.mu.
+func (rm *RecoveryMgr) checkpoint() error {
+	lsn, err := WriteCheckpointToLog(rm.lm, rm.nextTxNum)
+	if err != nil {
+		return err
+	}
+	return rm.lm.Flush(lsn)
+}
+
+// lastTxNum returns the highest unit of work number logged since the
+// last checkpoint, or numbered before it.
+func (rm *RecoveryMgr) lastTxNum() (int, error) {
+	last := 0
+	err := rm.scan(func(rec LogRecord) (bool, error) {
+		if cp, ok := rec.(CheckpointRecord); ok {
+			last = max(last, cp.NextTxNum()-1)
+			return false, nil
+		}
+		last = max(last, rec.TxNumber())
+		return true, nil
+	})
+	return last, err
+}
+
+// scan calls fn for each log record, newest first, until fn returns false.
+func (rm *RecoveryMgr) scan(fn func(LogRecord) (bool, error)) error {
+	it, err := rm.lm.Iterator()
+	if err != nil {
+		return err
+	}
+	for it.HasNext() {
+		b, err := it.Next()
+		if err != nil {
+			return err
+		}
+		rec, err := CreateLogRecord(b)
+		if err != nil {
+			return err
+		}
+		more, err := fn(rec)
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tx is a unit of work whose modifications are undone if it does not commit.
+type Tx struct {
+	rm    *RecoveryMgr
+	txnum int
+	done  bool
+	files map[string]bool // files modified, synced when the unit of work ends
+}
+
+// TxNum returns the number identifying the unit of work in the log.
+func (tx *Tx) TxNum() int { return tx.txnum }
+
+// SetInt logs the current int at offset in blk and then stores val there.
+func (tx *Tx) SetInt(blk file.BlockId, offset, val int) error {
+	return tx.update(blk, func(p *file.Page) (int, error) {
+		oldval, err := p.GetInt(offset)
+		if err != nil {
+			return 0, err
+		}
+		lsn, err := WriteSetIntToLog(tx.rm.lm, tx.txnum, blk, offset, oldval)
+		if err != nil {
+			return 0, err
+		}
+		return lsn, p.SetInt(offset, val)
+	})
+}
+
+// SetString logs the current string at offset in blk and then stores val there.
+func (tx *Tx) SetString(blk file.BlockId, offset int, val string) error {
+	return tx.update(blk, func(p *file.Page) (int, error) {
+		oldval, err := p.GetString(offset)
+		if err != nil {
+			return 0, err
+		}
+		lsn, err := WriteSetStringToLog(tx.rm.lm, tx.txnum, blk, offset, oldval)
+		if err != nil {
+			return 0, err
+		}
+		return lsn, p.SetString(offset, val)
+	})
+}
+
+// Commit syncs the modified blocks, which every update writes through,
+// then writes a COMMIT record and flushes the log. Undo logging cannot
+// redo a committed change, so it must be durable before the commit.
+func (tx *Tx) Commit() error {
+	rm := tx.rm
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if tx.done {
+		return errors.New("Commit: unit of work already finished")
+	}
+	if err := tx.syncFiles(); err != nil {
+		return err
+	}
+	lsn, err := WriteCommitToLog(rm.lm, tx.txnum)
+	if err != nil {
+		return err
+	}
+	if err := rm.lm.Flush(lsn); err != nil {
+		return err
+	}
+	tx.finish()
+	return nil
+}
+
+// Rollback undoes every modification made by the unit of work and syncs
+// the restored blocks, then writes a ROLLBACK record and flushes the log.
+func (tx *Tx) Rollback() error {
+	rm := tx.rm
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if tx.done {
+		return errors.New("Rollback: unit of work already finished")
+	}
+	err := rm.scan(func(rec LogRecord) (bool, error) {
+		if rec.TxNumber() != tx.txnum {
+			return true, nil
+		}
+		if rec.Op() == Start {
+			return false, nil
+		}
+		return true, rec.Undo(rm.fm)
+	})
+	if err != nil {
+		return err
+	}
+	if err := tx.syncFiles(); err != nil {
+		return err
+	}
+	lsn, err := WriteRollbackToLog(rm.lm, tx.txnum)
+	if err != nil {
+		return err
+	}
+	if err := rm.lm.Flush(lsn); err != nil {
+		return err
+	}
+	tx.finish()
+	return nil
+}
+
+// update reads blk, lets modify log the old value and change the page,
+// then flushes the log record and writes the block back.
+func (tx *Tx) update(blk file.BlockId, modify func(*file.Page) (int, error)) error {
+	rm := tx.rm
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	if tx.done {
+		return errors.New("update: unit of work already finished")
+	}
+	p := file.NewPage(rm.fm.BlockSize())
+	if err := rm.fm.Read(blk, p); err != nil {
+		return err
+	}
+	lsn, err := modify(p)
+	if err != nil {
+		return err
+	}
+	// Write-ahead rule: the old value must be on disk before the new one.
+	if err := rm.lm.Flush(lsn); err != nil {
+		return err
+	}
+	tx.files[blk.FileName()] = true
+	return rm.fm.Write(blk, p)
+}
+
+// syncFiles flushes the files the unit of work modified to stable
+// storage. The caller must hold rm.mu.
+func (tx *Tx) syncFiles() error {
+	for name := range tx.files {
+		if err := tx.rm.fm.Sync(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish marks the unit of work as complete. The caller must hold rm.mu.
+func (tx *Tx) finish() {
+	tx.done = true
+	delete(tx.rm.active, tx.txnum)
+}
@@ -0,0 +1,321 @@
+package recovery
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"simpledb-in-golang/file"
+	"simpledb-in-golang/file/filetest"
+)
+
+// newTestDB creates a file manager with one zeroed block of data.db.
+func newTestDB(t *testing.T, dir string) (*file.FileMgr, file.BlockId) {
+	t.Helper()
+
+	fm, err := file.NewFileMgr(dir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	blk, err := fm.Append("data.db")
+	if err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+	return fm, blk
+}
+
+// readValues returns the int at offset 80 and the string at offset 40 of blk.
+func readValues(t *testing.T, fm *file.FileMgr, blk file.BlockId) (int, string) {
+	t.Helper()
+
+	p := file.NewPage(fm.BlockSize())
+	if err := fm.Read(blk, p); err != nil {
+		t.Fatalf("Read() failed: %v", err)
+	}
+	ival, _ := p.GetInt(80)
+	sval, _ := p.GetString(40)
+	return ival, sval
+}
+
+func TestTx_CommitRollback(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			commit bool
+		}
+		wants struct {
+			ival int
+			sval string
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name:  "commit keeps changes",
+			args:  args{commit: true},
+			wants: wants{ival: 200, sval: "two"},
+		},
+		{
+			name:  "rollback restores old values",
+			args:  args{commit: false},
+			wants: wants{ival: 100, sval: "one"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_recovery_tx_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			fm, blk := newTestDB(t, testDir)
+			rm, err := NewRecoveryMgr(fm)
+			if err != nil {
+				t.Fatalf("NewRecoveryMgr() failed: %v", err)
+			}
+
+			tx1, err := rm.Begin()
+			if err != nil {
+				t.Fatalf("Begin() failed: %v", err)
+			}
+			if err := tx1.SetInt(blk, 80, 100); err != nil {
+				t.Fatalf("SetInt() error = %v", err)
+			}
+			if err := tx1.SetString(blk, 40, "one"); err != nil {
+				t.Fatalf("SetString() error = %v", err)
+			}
+			if err := tx1.Commit(); err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+
+			tx2, err := rm.Begin()
+			if err != nil {
+				t.Fatalf("Begin() failed: %v", err)
+			}
+			if err := tx2.SetInt(blk, 80, 150); err != nil {
+				t.Fatalf("SetInt() error = %v", err)
+			}
+			if err := tx2.SetInt(blk, 80, 200); err != nil {
+				t.Fatalf("SetInt() error = %v", err)
+			}
+			if err := tx2.SetString(blk, 40, "two"); err != nil {
+				t.Fatalf("SetString() error = %v", err)
+			}
+			if tt.args.commit {
+				err = tx2.Commit()
+			} else {
+				err = tx2.Rollback()
+			}
+			if err != nil {
+				t.Fatalf("finishing tx2 error = %v", err)
+			}
+
+			ival, sval := readValues(t, fm, blk)
+			if ival != tt.wants.ival || sval != tt.wants.sval {
+				t.Errorf("values = (%v, %q), want (%v, %q)", ival, sval, tt.wants.ival, tt.wants.sval)
+			}
+
+			if err := tx2.SetInt(blk, 80, 1); err == nil {
+				t.Errorf("SetInt() after finish error = nil, want error")
+			}
+			if err := tx2.Commit(); err == nil {
+				t.Errorf("Commit() after finish error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestRecoveryMgr_Recover(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_recovery_recover")
+	defer os.RemoveAll(testDir)
+
+	fm, blk := newTestDB(t, testDir)
+	rm, err := NewRecoveryMgr(fm)
+	if err != nil {
+		t.Fatalf("NewRecoveryMgr() failed: %v", err)
+	}
+
+	committed, _ := rm.Begin()
+	if err := committed.SetInt(blk, 80, 100); err != nil {
+		t.Fatalf("SetInt() error = %v", err)
+	}
+	if err := committed.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	// This unit of work never finishes, as if the process crashed.
+	crashed, _ := rm.Begin()
+	if err := crashed.SetInt(blk, 80, 999); err != nil {
+		t.Fatalf("SetInt() error = %v", err)
+	}
+	if err := crashed.SetString(blk, 40, "partial"); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+	if err := rm.Recover(); err == nil {
+		t.Errorf("Recover() with active units of work error = nil, want error")
+	}
+
+	// Restart on the same directory.
+	fm2, err := file.NewFileMgr(testDir, 400)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	rm2, err := NewRecoveryMgr(fm2)
+	if err != nil {
+		t.Fatalf("NewRecoveryMgr() failed: %v", err)
+	}
+	if err := rm2.Recover(); err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+
+	ival, sval := readValues(t, fm2, blk)
+	if ival != 100 || sval != "" {
+		t.Errorf("values after recovery = (%v, %q), want (100, %q)", ival, sval, "")
+	}
+
+	// Numbering continues after the units of work found in the log.
+	tx, err := rm2.Begin()
+	if err != nil {
+		t.Fatalf("Begin() failed: %v", err)
+	}
+	if tx.TxNum() <= crashed.TxNum() {
+		t.Errorf("TxNum() after restart = %v, want > %v", tx.TxNum(), crashed.TxNum())
+	}
+	if err := rm2.Checkpoint(); err == nil {
+		t.Errorf("Checkpoint() with active units of work error = nil, want error")
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if err := rm2.Checkpoint(); err != nil {
+		t.Errorf("Checkpoint() error = %v", err)
+	}
+}
+
+func TestRecoveryMgr_NumberingSurvivesCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_recovery_numbering")
+	defer os.RemoveAll(testDir)
+
+	fm, blk := newTestDB(t, testDir)
+	last := 0
+	for restart := range 3 {
+		// Recover ends with a checkpoint, behind which the earlier units
+		// of work are never scanned again; restart twice so that the
+		// second restart finds nothing but checkpoints.
+		var rm *RecoveryMgr
+		for range 2 {
+			var err error
+			if rm, err = NewRecoveryMgr(fm); err != nil {
+				t.Fatalf("NewRecoveryMgr() failed: %v", err)
+			}
+			if err := rm.Recover(); err != nil {
+				t.Fatalf("Recover() error = %v", err)
+			}
+		}
+		tx, err := rm.Begin()
+		if err != nil {
+			t.Fatalf("Begin() failed: %v", err)
+		}
+		if tx.TxNum() <= last {
+			t.Errorf("restart %d: TxNum() = %d, want > %d", restart, tx.TxNum(), last)
+		}
+		last = tx.TxNum()
+		if err := tx.SetInt(blk, 80, restart); err != nil {
+			t.Fatalf("SetInt() error = %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatalf("Commit() error = %v", err)
+		}
+
+		if fm, err = file.NewFileMgr(testDir, 400); err != nil {
+			t.Fatalf("NewFileMgr() failed: %v", err)
+		}
+	}
+}
+
+func TestRecoveryMgr_RecoverAfterPowerLoss(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		durability file.Durability
+	}{
+		{"every write", file.SyncEveryWrite},
+		{"on demand", file.SyncOnDemand},
+		{"interval", file.SyncInterval(time.Hour)},
+	}
+	for _, tt := range tests {
+		for seed := range int64(8) {
+			t.Run(fmt.Sprintf("%s/seed %d", tt.name, seed), func(t *testing.T) {
+				t.Parallel()
+				s := filetest.NewFaultStorage(seed)
+				opts := []file.Option{file.WithDurability(tt.durability)}
+				fm, err := file.NewFileMgr("", 400, append(opts, file.WithStorage(s))...)
+				if err != nil {
+					t.Fatalf("NewFileMgr() failed: %v", err)
+				}
+				blk, err := fm.Append("data.db")
+				if err != nil {
+					t.Fatalf("Append() failed: %v", err)
+				}
+				rm, err := NewRecoveryMgr(fm)
+				if err != nil {
+					t.Fatalf("NewRecoveryMgr() failed: %v", err)
+				}
+
+				committed, _ := rm.Begin()
+				if err := committed.SetInt(blk, 80, 100); err != nil {
+					t.Fatalf("SetInt() error = %v", err)
+				}
+				if err := committed.SetString(blk, 40, "kept"); err != nil {
+					t.Fatalf("SetString() error = %v", err)
+				}
+				if err := committed.Commit(); err != nil {
+					t.Fatalf("Commit() error = %v", err)
+				}
+				rolledBack, _ := rm.Begin()
+				if err := rolledBack.SetString(blk, 40, "undone"); err != nil {
+					t.Fatalf("SetString() error = %v", err)
+				}
+				if err := rolledBack.Rollback(); err != nil {
+					t.Fatalf("Rollback() error = %v", err)
+				}
+				crashed, _ := rm.Begin()
+				if err := crashed.SetInt(blk, 80, 999); err != nil {
+					t.Fatalf("SetInt() error = %v", err)
+				}
+
+				// Unsynced writes may or may not reach the disk.
+				loss := filetest.PowerLoss{KeepProbability: 0.5, Reorder: true}
+				fm2, err := filetest.Reopen(s, loss, 400, opts...)
+				if err != nil {
+					t.Fatalf("Reopen() failed: %v", err)
+				}
+				defer fm2.Close()
+				rm2, err := NewRecoveryMgr(fm2)
+				if err != nil {
+					t.Fatalf("NewRecoveryMgr() failed: %v", err)
+				}
+				if err := rm2.Recover(); err != nil {
+					t.Fatalf("Recover() error = %v", err)
+				}
+				ival, sval := readValues(t, fm2, blk)
+				if ival != 100 || sval != "kept" {
+					t.Errorf("values after recovery = (%v, %q), want (100, %q)", ival, sval, "kept")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"simpledb-in-golang/file"
+)
+
+// errQuit is returned by exec when the user asks to leave the shell.
+var errQuit = errors.New("quit")
+
+const helpText = `commands:
+  ls                                   list files and their block counts
+  dump <file> <blk>                    show a block as hex
+  getint <file> <blk> <off>            read an int
+  getstring <file> <blk> <off>         read a string
+  setint <file> <blk> <off> <val>      write an int
+  setstring <file> <blk> <off> <str>   write a string (rest of line, or a "quoted" Go string)
+  append <file>                        append a zero-filled block
+  rekey [file]                         re-encrypt a file, or all files, under the current key
+  help                                 show this text
+  quit                                 leave the shell
+`
+
+// shell executes block-inspection commands against a database.
+type shell struct {
+	fm  *file.FileMgr
+	out io.Writer
+}
+
+// run executes the commands read from r. In interactive mode a prompt is
+// shown and errors are reported without stopping; otherwise the first
+// failing command ends the run and its error is returned.
+func (sh *shell) run(r io.Reader, interactive bool) error {
+	sc := bufio.NewScanner(r)
+	lineno := 0
+	for {
+		if interactive {
+			fmt.Fprint(sh.out, "simpledb> ")
+		}
+		if !sc.Scan() {
+			return sc.Err()
+		}
+		lineno++
+		err := sh.exec(sc.Text())
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+		if err != nil {
+			if !interactive {
+				return fmt.Errorf("line %d: %w", lineno, err)
+			}
+			fmt.Fprintln(sh.out, "error:", err)
+		}
+	}
+}
+
+// exec executes a single command line. Blank lines and lines starting with # are ignored.
+func (sh *shell) exec(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	cmd, rest := cutField(line)
+	args := strings.Fields(rest)
+	switch cmd {
+	case "ls":
+		return sh.ls()
+	case "dump":
+		return sh.dump(args)
+	case "getint":
+		return sh.getint(args)
+	case "getstring":
+		return sh.getstring(args)
+	case "setint":
+		return sh.setint(args)
+	case "setstring":
+		return sh.setstring(rest)
+	case "append":
+		return sh.append(args)
+	case "rekey":
+		return sh.rekey(args)
+	case "help":
+		fmt.Fprint(sh.out, helpText)
+		return nil
+	case "quit", "exit":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %q (try help)", cmd)
+	}
+}
+
+// ls lists each database file with its block count.
+func (sh *shell) ls() error {
+	names, err := sh.fm.Files()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		n, err := sh.fm.Length(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(sh.out, "%-24s %d\n", name, n)
+	}
+	return nil
+}
+
+// dump prints a block as hex.
+func (sh *shell) dump(args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: dump <file> <blk>")
+	}
+	blk, err := parseBlock(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	p, err := sh.read(blk)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(sh.out, hex.Dump(p.Buffer()))
+	return nil
+}
+
+// getint prints the int stored at an offset of a block.
+func (sh *shell) getint(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: getint <file> <blk> <off>")
+	}
+	blk, off, err := parseLocation(args)
+	if err != nil {
+		return err
+	}
+	p, err := sh.read(blk)
+	if err != nil {
+		return err
+	}
+	v, err := p.GetInt(off)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, v)
+	return nil
+}
+
+// getstring prints the string stored at an offset of a block.
+func (sh *shell) getstring(args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: getstring <file> <blk> <off>")
+	}
+	blk, off, err := parseLocation(args)
+	if err != nil {
+		return err
+	}
+	p, err := sh.read(blk)
+	if err != nil {
+		return err
+	}
+	s, err := p.GetString(off)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(sh.out, "%q\n", s)
+	return nil
+}
+
+// setint stores an int at an offset of a block.
+func (sh *shell) setint(args []string) error {
+	if len(args) != 4 {
+		return errors.New("usage: setint <file> <blk> <off> <val>")
+	}
+	blk, off, err := parseLocation(args[:3])
+	if err != nil {
+		return err
+	}
+	v, err := strconv.Atoi(args[3])
+	if err != nil || v < math.MinInt32 || v > math.MaxInt32 {
+		return fmt.Errorf("invalid value %q", args[3])
+	}
+	return sh.modify(blk, func(p *file.Page) error { return p.SetInt(off, v) })
+}
+
+// setstring stores a string at an offset of a block. The string is the
+// rest of the line, or a double-quoted Go string literal.
+func (sh *shell) setstring(rest string) error {
+	args := make([]string, 3)
+	for i := range args {
+		args[i], rest = cutField(rest)
+	}
+	s := strings.TrimLeftFunc(rest, unicode.IsSpace)
+	if args[2] == "" || s == "" {
+		return errors.New("usage: setstring <file> <blk> <off> <str>")
+	}
+	blk, off, err := parseLocation(args)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(s, `"`) {
+		quoted := s
+		if s, err = strconv.Unquote(quoted); err != nil {
+			return fmt.Errorf("invalid quoted string %s", quoted)
+		}
+	}
+	return sh.modify(blk, func(p *file.Page) error { return p.SetString(off, s) })
+}
+
+// append adds a zero-filled block to a file and prints its number.
+func (sh *shell) append(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: append <file>")
+	}
+	blk, err := sh.fm.Append(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, blk)
+	return nil
+}
+
+// rekey re-encrypts the blocks of a file, or of every file, that are
+// not under the current key.
+func (sh *shell) rekey(args []string) error {
+	switch len(args) {
+	case 0:
+		return sh.fm.RekeyAll()
+	case 1:
+		return sh.fm.Rekey(args[0])
+	default:
+		return errors.New("usage: rekey [file]")
+	}
+}
+
+// read returns the contents of a block.
+func (sh *shell) read(blk file.BlockId) (*file.Page, error) {
+	p := file.NewPage(sh.fm.BlockSize())
+	if err := sh.fm.Read(blk, p); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", blk, err)
+	}
+	return p, nil
+}
+
+// modify reads a block, applies set to it and writes it back.
+func (sh *shell) modify(blk file.BlockId, set func(*file.Page) error) error {
+	p, err := sh.read(blk)
+	if err != nil {
+		return err
+	}
+	if err := set(p); err != nil {
+		return err
+	}
+	return sh.fm.Write(blk, p)
+}
+
+// parseBlock builds a BlockId from a filename and block number argument.
+func parseBlock(filename, num string) (file.BlockId, error) {
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 0 {
+		return file.BlockId{}, fmt.Errorf("invalid block number %q", num)
+	}
+	return file.NewBlockId(filename, n), nil
+}
+
+// parseLocation parses <file> <blk> <off> arguments.
+func parseLocation(args []string) (file.BlockId, int, error) {
+	blk, err := parseBlock(args[0], args[1])
+	if err != nil {
+		return file.BlockId{}, 0, err
+	}
+	off, err := strconv.Atoi(args[2])
+	if err != nil || off < 0 {
+		return file.BlockId{}, 0, fmt.Errorf("invalid offset %q", args[2])
+	}
+	return blk, off, nil
+}
+
+// cutField splits s around its first whitespace-separated field,
+// returning the field and what follows it.
+func cutField(s string) (field, rest string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	i := strings.IndexFunc(s, unicode.IsSpace)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simpledb-in-golang/file"
+)
+
+func newTestShell(t *testing.T, dir string) (*shell, *bytes.Buffer) {
+	t.Helper()
+
+	fm, err := file.NewFileMgr(dir, 64)
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	out := &bytes.Buffer{}
+	return &shell{fm: fm, out: out}, out
+}
+
+func TestShell_Exec(t *testing.T) {
+	t.Parallel()
+
+	type (
+		args struct {
+			script string
+		}
+		wants struct {
+			output   string
+			hasError bool
+		}
+	)
+
+	tests := []struct {
+		name  string
+		args  args
+		wants wants
+	}{
+		{
+			name: "append and ls",
+			args: args{script: "append a.db\nappend a.db\nappend b.db\nls"},
+			wants: wants{output: "[file a.db, block 0]\n[file a.db, block 1]\n[file b.db, block 0]\n" +
+				"a.db                     2\nb.db                     1\n"},
+		},
+		{
+			name:  "set and get int",
+			args:  args{script: "append a.db\nsetint a.db 0 8 345\ngetint a.db 0 8"},
+			wants: wants{output: "[file a.db, block 0]\n345\n"},
+		},
+		{
+			name:  "set and get string",
+			args:  args{script: "append a.db\nsetstring a.db 0 4 hello  world\ngetstring a.db 0 4"},
+			wants: wants{output: "[file a.db, block 0]\n\"hello  world\"\n"},
+		},
+		{
+			name:  "string after extra spaces",
+			args:  args{script: "append a.db\nsetstring a.db  0\t 4   hi there\ngetstring a.db 0 4"},
+			wants: wants{output: "[file a.db, block 0]\n\"hi there\"\n"},
+		},
+		{
+			name:  "quoted string",
+			args:  args{script: "append a.db\nsetstring a.db 0 4 \"tab\\there\"\ngetstring a.db 0 4"},
+			wants: wants{output: "[file a.db, block 0]\n\"tab\\there\"\n"},
+		},
+		{
+			name:  "dump",
+			args:  args{script: "append a.db\nsetint a.db 0 0 258\ndump a.db 0"},
+			wants: wants{output: "[file a.db, block 0]\n00000000  00 00 01 02 00 00 00 00"},
+		},
+		{
+			name:  "comments, blank lines and quit",
+			args:  args{script: "# nothing\n\nquit\nappend a.db"},
+			wants: wants{output: ""},
+		},
+		{
+			name:  "unknown command stops a script",
+			args:  args{script: "frobnicate\nappend a.db"},
+			wants: wants{output: "", hasError: true},
+		},
+		{
+			name:  "bad arguments",
+			args:  args{script: "getint a.db x 0"},
+			wants: wants{hasError: true},
+		},
+		{
+			name:  "offset out of bounds",
+			args:  args{script: "append a.db\nsetint a.db 0 62 1"},
+			wants: wants{output: "[file a.db, block 0]\n", hasError: true},
+		},
+		{
+			name:  "int out of range",
+			args:  args{script: "append a.db\nsetint a.db 0 0 4294967297"},
+			wants: wants{output: "[file a.db, block 0]\n", hasError: true},
+		},
+		{
+			name:  "rekey without encryption",
+			args:  args{script: "append a.db\nrekey"},
+			wants: wants{output: "[file a.db, block 0]\n", hasError: true},
+		},
+		{
+			name:  "unwritten block",
+			args:  args{script: "getint a.db 5 0\nsetint a.db 3 0 7\ngetint a.db 3 0"},
+			wants: wants{output: "0\n7\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			testDir := filepath.Join(os.TempDir(), "testdb_shell_"+tt.name)
+			defer os.RemoveAll(testDir)
+
+			sh, out := newTestShell(t, testDir)
+			err := sh.run(strings.NewReader(tt.args.script), false)
+			if (err != nil) != tt.wants.hasError {
+				t.Errorf("run() error = %v, wantError %v", err, tt.wants.hasError)
+			}
+			if !strings.HasPrefix(out.String(), tt.wants.output) {
+				t.Errorf("output = %q, want prefix %q", out.String(), tt.wants.output)
+			}
+		})
+	}
+}
+
+func TestShell_Interactive(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_shell_interactive")
+	defer os.RemoveAll(testDir)
+
+	sh, out := newTestShell(t, testDir)
+	if err := sh.run(strings.NewReader("bogus\nappend a.db\n"), true); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	want := "simpledb> error: unknown command \"bogus\" (try help)\n" +
+		"simpledb> [file a.db, block 0]\nsimpledb> "
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestShell_Ls(t *testing.T) {
+	t.Parallel()
+
+	mem := file.NewMemStorage()
+	fm, err := file.NewFileMgr("", 64, file.WithStorage(mem), file.WithCompression())
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	out := &bytes.Buffer{}
+	sh := &shell{fm: fm, out: out}
+	script := "append a.db\nappend a.db\nsetint a.db 1 0 7\nappend b.db\nls"
+	if err := sh.run(strings.NewReader(script), false); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if err := fm.Free(file.NewBlockId("a.db", 0)); err != nil {
+		t.Fatalf("Free() error = %v", err)
+	}
+	out.Reset()
+	if err := sh.run(strings.NewReader("ls"), false); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	want := "a.db                     2\nb.db                     1\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	names, err := mem.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, ".zmap.zmap") {
+			t.Errorf("ls created %s", name)
+		}
+	}
+}
+
+func TestShell_Rekey(t *testing.T) {
+	t.Parallel()
+
+	testDir := filepath.Join(os.TempDir(), "testdb_shell_rekey")
+	defer os.RemoveAll(testDir)
+
+	old := file.StaticKeys{1: make([]byte, 16)}
+	fm, err := file.NewFileMgr(testDir, 128, file.WithEncryption(old))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	sh := &shell{fm: fm, out: &bytes.Buffer{}}
+	if err := sh.run(strings.NewReader("append a.db\nsetint a.db 0 0 42"), false); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	fm.Close()
+
+	rotated := file.StaticKeys{1: old[1], 2: bytes.Repeat([]byte{2}, 16)}
+	fm, err = file.NewFileMgr(testDir, 128, file.WithEncryption(rotated))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	sh = &shell{fm: fm, out: &bytes.Buffer{}}
+	if err := sh.run(strings.NewReader("rekey a.db\nrekey"), false); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	fm.Close()
+
+	fm, err = file.NewFileMgr(testDir, 128, file.WithEncryption(file.StaticKeys{2: rotated[2]}))
+	if err != nil {
+		t.Fatalf("NewFileMgr() failed: %v", err)
+	}
+	defer fm.Close()
+	out := &bytes.Buffer{}
+	sh = &shell{fm: fm, out: out}
+	if err := sh.run(strings.NewReader("getint a.db 0 0"), false); err != nil {
+		t.Fatalf("run() after retiring the old key error = %v", err)
+	}
+	if out.String() != "42\n" {
+		t.Errorf("output = %q, want %q", out.String(), "42\n")
+	}
+}